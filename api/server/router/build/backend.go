@@ -17,4 +17,8 @@ type Backend interface {
 	//
 	// TODO: make this return a reference instead of string
 	BuildFromContext(ctx context.Context, src io.ReadCloser, remote string, buildOptions *types.ImageBuildOptions, pg backend.ProgressWriter) (string, error)
+
+	// PruneCache removes the cache mounts of RUN instructions which are not
+	// in use.
+	PruneCache() (*types.BuildCachePruneReport, error)
 }
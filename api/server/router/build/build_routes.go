@@ -18,6 +18,7 @@ import (
 	"github.com/docker/docker/api/types/backend"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/builder/remotecache"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/progress"
 	"github.com/docker/docker/pkg/streamformatter"
@@ -55,6 +56,32 @@ func newImageBuildOptions(ctx context.Context, r *http.Request) (*types.ImageBui
 	options.Tags = r.Form["t"]
 	options.SecurityOpt = r.Form["securityopt"]
 	options.Squash = httputils.BoolValue(r, "squash")
+	options.Trace = httputils.BoolValue(r, "trace")
+	options.Target = r.FormValue("target")
+
+	if r.Form.Get("parallelism") != "" {
+		parallelism, err := strconv.Atoi(r.Form.Get("parallelism"))
+		if err != nil || parallelism < 0 {
+			return nil, fmt.Errorf("invalid parallelism: %s", r.Form.Get("parallelism"))
+		}
+		options.Parallelism = parallelism
+	}
+
+	options.Reproducible = httputils.BoolValue(r, "reproducible")
+	if r.Form.Get("sourcedateepoch") != "" {
+		epoch, err := strconv.ParseInt(r.Form.Get("sourcedateepoch"), 10, 64)
+		if err != nil || epoch < 0 {
+			return nil, fmt.Errorf("invalid sourcedateepoch: %s", r.Form.Get("sourcedateepoch"))
+		}
+		options.SourceDateEpoch = epoch
+	}
+
+	switch progress := r.Form.Get("progress"); progress {
+	case "", types.BuildProgressJSON:
+		options.Progress = progress
+	default:
+		return nil, fmt.Errorf("invalid progress format: %s", progress)
+	}
 
 	if r.Form.Get("shmsize") != "" {
 		shmSize, err := strconv.ParseInt(r.Form.Get("shmsize"), 10, 64)
@@ -121,9 +148,57 @@ func newImageBuildOptions(ctx context.Context, r *http.Request) (*types.ImageBui
 		if err := json.Unmarshal([]byte(cacheFromJSON), &cacheFrom); err != nil {
 			return nil, err
 		}
+		for _, s := range cacheFrom {
+			if remotecache.IsSpec(s) {
+				if _, err := remotecache.ParseSpec(s, false); err != nil {
+					return nil, err
+				}
+			}
+		}
 		options.CacheFrom = cacheFrom
 	}
 
+	if cacheToJSON := r.FormValue("cacheto"); cacheToJSON != "" {
+		var cacheTo = []string{}
+		if err := json.Unmarshal([]byte(cacheToJSON), &cacheTo); err != nil {
+			return nil, err
+		}
+		for _, s := range cacheTo {
+			if _, err := remotecache.ParseSpec(s, true); err != nil {
+				return nil, err
+			}
+		}
+		options.CacheTo = cacheTo
+	}
+
+	if imageLockJSON := r.FormValue("imagelock"); imageLockJSON != "" {
+		var imageLock = map[string]string{}
+		if err := json.Unmarshal([]byte(imageLockJSON), &imageLock); err != nil {
+			return nil, err
+		}
+		options.ImageLock = imageLock
+	}
+
+	if identityEncoded := r.Header.Get("X-Docker-Git-Identity"); identityEncoded != "" {
+		identityJSON := base64.NewDecoder(base64.URLEncoding, strings.NewReader(identityEncoded))
+		var identity types.GitIdentity
+		if err := json.NewDecoder(identityJSON).Decode(&identity); err != nil {
+			return nil, fmt.Errorf("invalid git identity: %v", err)
+		}
+		options.GitIdentity = &identity
+	}
+
+	// secrets are sent in a header rather than in the query, which may end up
+	// in logs
+	if secretsEncoded := r.Header.Get("X-Docker-Build-Secrets"); secretsEncoded != "" {
+		secretsJSON := base64.NewDecoder(base64.URLEncoding, strings.NewReader(secretsEncoded))
+		var secrets = map[string][]byte{}
+		if err := json.NewDecoder(secretsJSON).Decode(&secrets); err != nil {
+			return nil, fmt.Errorf("invalid build secrets: %v", err)
+		}
+		options.Secrets = secrets
+	}
+
 	return options, nil
 }
 
@@ -139,6 +214,14 @@ func (s *syncWriter) Write(b []byte) (count int, err error) {
 	return
 }
 
+func (br *buildRouter) postPrune(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	report, err := br.backend.PruneCache()
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, report)
+}
+
 func (br *buildRouter) postBuild(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	var (
 		authConfigs        = map[string]types.AuthConfig{}
@@ -95,10 +95,12 @@ func (s *containerRouter) getContainersLogs(ctx context.Context, w http.Response
 			Follow:     httputils.BoolValue(r, "follow"),
 			Timestamps: httputils.BoolValue(r, "timestamps"),
 			Since:      r.Form.Get("since"),
+			Until:      r.Form.Get("until"),
 			Tail:       r.Form.Get("tail"),
 			ShowStdout: stdout,
 			ShowStderr: stderr,
 			Details:    httputils.BoolValue(r, "details"),
+			Grep:       r.Form.Get("grep"),
 		},
 		OutStream: w,
 	}
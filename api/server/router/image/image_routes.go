@@ -277,6 +277,15 @@ func (s *imageRouter) getImagesHistory(ctx context.Context, w http.ResponseWrite
 	return httputils.WriteJSON(w, http.StatusOK, history)
 }
 
+func (s *imageRouter) getImagesTrace(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	trace, err := s.backend.ImageTrace(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, trace)
+}
+
 func (s *imageRouter) postImagesTag(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
@@ -233,10 +233,12 @@ func (sr *swarmRouter) getServiceLogs(ctx context.Context, w http.ResponseWriter
 			Follow:     httputils.BoolValue(r, "follow"),
 			Timestamps: httputils.BoolValue(r, "timestamps"),
 			Since:      r.Form.Get("since"),
+			Until:      r.Form.Get("until"),
 			Tail:       r.Form.Get("tail"),
 			ShowStdout: stdout,
 			ShowStderr: stderr,
 			Details:    httputils.BoolValue(r, "details"),
+			Grep:       r.Form.Get("grep"),
 		},
 		OutStream: w,
 	}
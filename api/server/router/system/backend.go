@@ -18,4 +18,6 @@ type Backend interface {
 	SubscribeToEvents(since, until time.Time, ef filters.Args) ([]events.Message, chan interface{})
 	UnsubscribeFromEvents(chan interface{})
 	AuthenticateToRegistry(ctx context.Context, authConfig *types.AuthConfig) (string, string, error)
+	TapconPrincipals(all bool) ([]types.TapconPrincipal, error)
+	TapconReconcile() (*types.TapconReconcileReport, error)
 }
@@ -28,6 +28,8 @@ func NewRouter(b Backend, c *cluster.Cluster) router.Router {
 		router.NewGetRoute("/version", r.getVersion),
 		router.NewGetRoute("/system/df", r.getDiskUsage),
 		router.NewPostRoute("/auth", r.postAuth),
+		router.NewGetRoute("/tapcon/principals", r.getTapconPrincipals),
+		router.NewPostRoute("/tapcon/principals/reconcile", r.postTapconReconcile),
 	}
 
 	return r
@@ -79,6 +79,25 @@ func (s *systemRouter) getDiskUsage(ctx context.Context, w http.ResponseWriter,
 	return httputils.WriteJSON(w, http.StatusOK, du)
 }
 
+func (s *systemRouter) getTapconPrincipals(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+	principals, err := s.backend.TapconPrincipals(httputils.BoolValue(r, "all"))
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, principals)
+}
+
+func (s *systemRouter) postTapconReconcile(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	report, err := s.backend.TapconReconcile()
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, report)
+}
+
 func (s *systemRouter) getEvents(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
@@ -3,6 +3,8 @@ package backend
 
 import (
 	"io"
+	"os"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/streamformatter"
@@ -71,8 +73,30 @@ type ExecProcessConfig struct {
 // transports configuration changes for a container.
 type ContainerCommitConfig struct {
 	types.ContainerCommitConfig
-	Changes    []string
+	Changes []string
+	// TapconData carries the provenance of the committed image in
+	// tapcon mode.
 	TapconData interface{}
+	// BaseImage is the reference of the image the build of the committed
+	// image started from, pinned to a digest.
+	BaseImage string
+	// Reproducible normalizes the layer and the timestamps of the image,
+	// so that committing the same changes gives the same image.
+	Reproducible bool
+	// SourceDateEpoch is the time the timestamps of a reproducible commit
+	// are clamped to.
+	SourceDateEpoch time.Time
+}
+
+// BuildSecret is a secret of a build, mounted into the container of a RUN
+// instruction which asks for it.
+type BuildSecret struct {
+	// Target is the name of the file holding the secret in /run/secrets.
+	Target string
+	UID    string
+	GID    string
+	Mode   os.FileMode
+	Data   []byte
 }
 
 // ProgressWriter is an interface
@@ -75,10 +75,12 @@ type ContainerLogsOptions struct {
 	ShowStdout bool
 	ShowStderr bool
 	Since      string
+	Until      string
 	Timestamps bool
 	Follow     bool
 	Tail       string
 	Details    bool
+	Grep       string // a regular expression the lines returned match
 }
 
 // ContainerRemoveOptions holds parameters to remove containers.
@@ -112,6 +114,12 @@ type NetworkListOptions struct {
 	Filters filters.Args
 }
 
+// TapconPrincipalListOptions holds parameters to list tapcon principals.
+type TapconPrincipalListOptions struct {
+	// All includes the principals which have been deleted.
+	All bool
+}
+
 // HijackedResponse holds connection information for a hijacked request.
 type HijackedResponse struct {
 	Conn   net.Conn
@@ -173,8 +181,70 @@ type ImageBuildOptions struct {
 	Squash bool
 	// CacheFrom specifies images that are used for matching cache. Images
 	// specified here do not need to have a valid parent chain to match cache.
-	CacheFrom   []string
+	// Entries starting with type= are caches exported by other builds, such
+	// as type=local,src=<dir> or type=registry,ref=<reference>.
+	CacheFrom []string
+	// CacheTo lists where to export the cache of the build, such as
+	// type=local,dest=<dir> or type=registry,ref=<reference>.
+	CacheTo     []string
 	SecurityOpt []string
+	// Trace runs the RUN steps under a tracer and attaches a report of the
+	// files, binaries and network endpoints they accessed to the image.
+	Trace bool
+	// GitIdentity describes the git checkout the context was taken from.
+	// The daemon trusts the context if its files match the identity.
+	GitIdentity *GitIdentity
+	// Target is the name of the stage to build in a multi-stage Dockerfile.
+	// The last stage is built if it is empty.
+	Target string
+	// ImageLock maps the references of the images the build uses to the
+	// digests they must resolve to.
+	ImageLock map[string]string
+	// Secrets holds the content of the build secrets by id. They are only
+	// mounted into the RUN instructions asking for them with
+	// --mount=type=secret, and are never committed to the image.
+	Secrets map[string][]byte
+	// Parallelism is the number of build stages which may run at once. The
+	// daemon picks it if it is 0, and 1 builds the stages one after the
+	// other.
+	Parallelism int
+	// Reproducible makes the images of the build depend only on its inputs:
+	// the timestamps of the images and of the files of their layers are
+	// set to SourceDateEpoch at most.
+	Reproducible bool
+	// SourceDateEpoch is the Unix time, in seconds, the timestamps of a
+	// reproducible build are clamped to.
+	SourceDateEpoch int64
+	// Progress is the format of the progress of the build: empty for the
+	// text output, or BuildProgressJSON to receive BuildEvent messages.
+	Progress string
+}
+
+// BuildProgressJSON is the progress format of the builds which send their
+// progress as BuildEvent messages.
+const BuildProgressJSON = "json"
+
+// GitIdentity identifies the git checkout a build context was taken from.
+// The commit object binds the commit hash to the tree hash, and the tree
+// objects bind the tree hash to the tree of the context, so the daemon can
+// check the content of the context. Nothing binds the remote to the commit.
+type GitIdentity struct {
+	// Remote is the URL of the repository the checkout was cloned from. It
+	// is only a claim of the client, which the daemon cannot verify.
+	Remote string
+	// Commit is the hash of HEAD, and CommitObject its raw content.
+	Commit       string
+	CommitObject []byte
+	// Tree is the hash of the tree of HEAD.
+	Tree string
+	// Subdir is the path of the context within the checkout, in the form
+	// printed by `git rev-parse --show-prefix`.
+	Subdir string
+	// Trees are the raw tree objects of HEAD and of each parent directory
+	// of the context within it.
+	Trees [][]byte
+	// Dirty is set if the context has uncommitted changes.
+	Dirty bool
 }
 
 // ImageBuildResponse holds information
@@ -0,0 +1,6 @@
+//go:generate protoc --gogofast_out=import_path=github.com/docker/docker/api/types/plugins/logdriver:. entry.proto
+
+// Package logdriver defines the messages the daemon sends to log driver
+// plugins and stores in the files of the local log driver, and how they are
+// framed on the FIFO the plugins read them from and in those files.
+package logdriver
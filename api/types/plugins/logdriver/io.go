@@ -0,0 +1,103 @@
+package logdriver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Each entry is framed as the length of its protobuf encoding, a 4-byte
+// unsigned big-endian integer, followed by the encoding itself.
+
+const (
+	frameHeaderLen = 4
+	// maxEntrySize is the largest entry a decoder accepts, so that a corrupt
+	// length does not make it allocate without bound.
+	maxEntrySize = 1 << 20
+)
+
+// ErrCorruptEntry is returned by a decoder for an entry which cannot be
+// decoded, or whose length is larger than the maximum.
+var ErrCorruptEntry = errors.New("corrupt log entry")
+
+// LogEntryEncoder writes framed log entries to a stream.
+type LogEntryEncoder interface {
+	Encode(*LogEntry) error
+}
+
+// NewLogEntryEncoder returns an encoder which writes the entries to w.
+func NewLogEntryEncoder(w io.Writer) LogEntryEncoder {
+	return &logEntryEncoder{w: w}
+}
+
+type logEntryEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// Encode writes the frame of entry with a single write, so that the entries
+// written to the stream from several encoders are not interleaved, and a
+// writer rotating files does not split an entry across them.
+func (e *logEntryEncoder) Encode(entry *LogEntry) error {
+	n := entry.Size()
+	if n > maxEntrySize {
+		return fmt.Errorf("log entry of %d bytes is larger than the maximum of %d bytes", n, maxEntrySize)
+	}
+	size := frameHeaderLen + n
+	if cap(e.buf) < size {
+		e.buf = make([]byte, size)
+	}
+	e.buf = e.buf[:size]
+	binary.BigEndian.PutUint32(e.buf, uint32(n))
+	if _, err := entry.MarshalTo(e.buf[frameHeaderLen:]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// LogEntryDecoder reads framed log entries from a stream.
+type LogEntryDecoder interface {
+	Decode(*LogEntry) error
+}
+
+// NewLogEntryDecoder returns a decoder which reads the entries from r.
+func NewLogEntryDecoder(r io.Reader) LogEntryDecoder {
+	return &logEntryDecoder{r: r}
+}
+
+type logEntryDecoder struct {
+	r   io.Reader
+	buf []byte
+}
+
+// Decode reads the next entry. It returns io.EOF when the stream ends
+// between two entries, io.ErrUnexpectedEOF when it ends within one, and
+// ErrCorruptEntry if the entry cannot be decoded. The entry does not refer
+// to the buffers of the decoder.
+func (d *logEntryDecoder) Decode(entry *LogEntry) error {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		return err
+	}
+	size := int(binary.BigEndian.Uint32(header[:]))
+	if size > maxEntrySize {
+		return ErrCorruptEntry
+	}
+	if cap(d.buf) < size {
+		d.buf = make([]byte, size)
+	}
+	d.buf = d.buf[:size]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	entry.Reset()
+	if err := entry.Unmarshal(d.buf); err != nil {
+		return ErrCorruptEntry
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package logdriver
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	entries := []LogEntry{
+		{Source: "stdout", TimeNano: 1, Line: []byte("hello")},
+		{Source: "stderr", TimeNano: 2, Line: []byte("partial"), Partial: true},
+		{Source: "stdout", TimeNano: 3},
+	}
+
+	buf := &bytes.Buffer{}
+	enc := NewLogEntryEncoder(buf)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewLogEntryDecoder(buf)
+	var entry LogEntry
+	for _, expected := range entries {
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(entry, expected) {
+			t.Fatalf("Expected %+v, got %+v", expected, entry)
+		}
+	}
+	if err := dec.Decode(&entry); err != io.EOF {
+		t.Fatalf("Expected EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := NewLogEntryEncoder(buf).Encode(&LogEntry{Line: []byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+	truncated := bytes.NewReader(buf.Bytes()[:buf.Len()-1])
+	if err := NewLogEntryDecoder(truncated).Decode(&LogEntry{}); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected an unexpected EOF, got %v", err)
+	}
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	header := []byte{0xff, 0xff, 0xff, 0xff}
+	if err := NewLogEntryDecoder(bytes.NewReader(header)).Decode(&LogEntry{}); err != ErrCorruptEntry {
+		t.Fatalf("Expected an entry larger than the maximum to be corrupt, got %v", err)
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	// the frame of an entry whose line is longer than the entry
+	frame := []byte{0, 0, 0, 2, 0x1a, 0x05}
+	if err := NewLogEntryDecoder(bytes.NewReader(frame)).Decode(&LogEntry{}); err != ErrCorruptEntry {
+		t.Fatalf("Expected a corrupt entry, got %v", err)
+	}
+}
@@ -77,6 +77,158 @@ type ImageInspect struct {
 	VirtualSize     int64
 	GraphDriver     GraphDriverData
 	RootFS          RootFS
+	Provenance      *ImageProvenance `json:",omitempty"`
+	// BaseImage is the reference of the image the build of this image
+	// started from, pinned to a digest.
+	BaseImage string `json:",omitempty"`
+}
+
+// ImageProvenance describes how an image was built in tapcon mode.
+type ImageProvenance struct {
+	Repo           string            `json:",omitempty"`
+	UnverifiedRepo string            `json:",omitempty"`
+	Revision       string            `json:",omitempty"`
+	Dir            string            `json:",omitempty"`
+	File           string            `json:",omitempty"`
+	FileDigest     string            `json:",omitempty"`
+	BuildArgs      map[string]string `json:",omitempty"`
+	BaseImage      string            `json:",omitempty"`
+	Images         []string          `json:",omitempty"`
+	Builder        string            `json:",omitempty"`
+	Time           string            `json:",omitempty"`
+	Git            []GitSource       `json:",omitempty"`
+}
+
+// GitSource is a git repository copied into an image by a GIT instruction
+// of its Dockerfile.
+type GitSource struct {
+	Repo   string
+	Ref    string `json:",omitempty"`
+	Commit string
+	Dir    string `json:",omitempty"`
+	Dest   string
+}
+
+// BuildTrace contains response of Engine API:
+// GET "/images/{name:.*}/trace"
+//
+// It reports what the RUN steps of a traced build accessed. The top level
+// lists are the sorted union of the lists of all steps.
+type BuildTrace struct {
+	ImageID   string
+	FilesRead []string
+	Executed  []string
+	Endpoints []string
+	Steps     []BuildTraceStep
+}
+
+// BuildTraceStep is the trace of a single RUN step of a build.
+type BuildTraceStep struct {
+	Step        int
+	Instruction string
+	// Cached is true if the step was taken from the build cache, in which
+	// case nothing was traced.
+	Cached    bool     `json:",omitempty"`
+	FilesRead []string `json:",omitempty"`
+	Executed  []string `json:",omitempty"`
+	Endpoints []string `json:",omitempty"`
+}
+
+// BuildEvent is an event of a build, sent as the aux data of the messages of
+// Engine API: POST "/build" when the build is started with the
+// BuildProgressJSON progress format.
+type BuildEvent struct {
+	// Type is the type of the event, one of the BuildEvent* constants.
+	Type string
+	// Step is the number of the step the event is about, starting at 1, or
+	// 0 for the events about the build as a whole.
+	Step int `json:",omitempty"`
+	// Steps is the number of steps of the build (step-start).
+	Steps int `json:",omitempty"`
+	// Instruction is the instruction of the step, as in the text output
+	// (step-start).
+	Instruction string `json:",omitempty"`
+	// Line is the line of the instruction in the Dockerfile (step-start,
+	// error).
+	Line int `json:",omitempty"`
+	// Cached is true if the step was found in the build cache (cache).
+	Cached bool `json:",omitempty"`
+	// CacheKey is the key the step was looked up with in the build cache,
+	// the digest of its parent image and configuration (cache).
+	CacheKey string `json:",omitempty"`
+	// ImageID is the image the step or the build produced (step-done, done).
+	ImageID string `json:",omitempty"`
+	// Size is the size in bytes of the layer the step added (step-done).
+	Size int64 `json:",omitempty"`
+	// Duration is how long the step took, in nanoseconds (step-done).
+	Duration time.Duration `json:",omitempty"`
+	// Stream is the stream, stdout or stderr, the output was written to
+	// (log, message).
+	Stream string `json:",omitempty"`
+	// Data is a chunk of the output (log, message).
+	Data string `json:",omitempty"`
+	// Error is the message of the error the step failed with (error).
+	Error string `json:",omitempty"`
+	// TimeNano is when the event happened, in nanoseconds since the Unix
+	// epoch.
+	TimeNano int64
+}
+
+// Types of BuildEvent.
+const (
+	// BuildEventStepStart is sent when a step starts.
+	BuildEventStepStart = "step-start"
+	// BuildEventCache is sent when a step is looked up in the build cache.
+	BuildEventCache = "cache"
+	// BuildEventStepDone is sent when a step is done.
+	BuildEventStepDone = "step-done"
+	// BuildEventLog is sent for the output of the command of a RUN step.
+	BuildEventLog = "log"
+	// BuildEventMessage is sent for the other output of the builder.
+	BuildEventMessage = "message"
+	// BuildEventError is sent when a step fails.
+	BuildEventError = "error"
+	// BuildEventDone is sent when the build is done.
+	BuildEventDone = "done"
+)
+
+// TapconPrincipal contains response of Engine API:
+// GET "/tapcon/principals"
+//
+// It is a principal the daemon registered with the attestation service for
+// one address of a tapcon container.
+type TapconPrincipal struct {
+	ContainerID string
+	PID         uint64
+	ImageID     string
+	// Config is the digest of the configuration the container runs with.
+	Config  string
+	Network string
+	IP      string
+	PortMin int
+	PortMax int
+	// Created and Deleted are RFC 3339 timestamps. Deleted is empty as long
+	// as the principal is active.
+	Created string
+	Deleted string `json:",omitempty"`
+	// Error is set when the last attempt to register the principal with
+	// the attestation service failed, in which case the service does not
+	// know about it.
+	Error string `json:",omitempty"`
+}
+
+// TapconReconcileReport contains response of Engine API:
+// POST "/tapcon/principals/reconcile"
+type TapconReconcileReport struct {
+	// Registered lists the principals registered again with the
+	// attestation service.
+	Registered []TapconPrincipal
+	// Removed lists the principals of containers which are no longer
+	// running, which were deleted from the attestation service.
+	Removed []TapconPrincipal
+	// Failed lists the principals which could not be reconciled, with
+	// their Error set.
+	Failed []TapconPrincipal
 }
 
 // Container contains response of Engine API:
@@ -364,6 +516,9 @@ type ContainerJSONBase struct {
 	GraphDriver     GraphDriverData
 	SizeRw          *int64 `json:",omitempty"`
 	SizeRootFs      *int64 `json:",omitempty"`
+	// TapconConfigDigest is the canonical digest of the configuration
+	// registered with the attestation service, if any.
+	TapconConfigDigest string `json:",omitempty"`
 }
 
 // ContainerJSON is newly used struct along with MountPoint
@@ -536,6 +691,13 @@ type NetworksPruneReport struct {
 	NetworksDeleted []string
 }
 
+// BuildCachePruneReport contains the response for Engine API:
+// POST "/build/prune"
+type BuildCachePruneReport struct {
+	CachesDeleted  []string
+	SpaceReclaimed uint64
+}
+
 // SecretCreateResponse contains the information returned to a client
 // on the creation of a new secret.
 type SecretCreateResponse struct {
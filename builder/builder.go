@@ -12,6 +12,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/backend"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/builder/remotecache"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/reference"
 	"golang.org/x/net/context"
@@ -48,7 +49,6 @@ type ModifiableContext interface {
 	// Remove deletes the entry specified by `path`.
 	// It is usual for directory entries to delete all its subentries.
 	Remove(path string) error
-	Add(path string) error
 }
 
 type TrustedGitContext interface {
@@ -119,6 +119,16 @@ type Backend interface {
 
 	// GetImageOnBuild looks up a Docker image referenced by `name`.
 	GetImageOnBuild(name string) (Image, error)
+	// GetRepoDigestsOnBuild returns the digests of the manifests the image
+	// `imageID` was pulled with from the repository of `name`.
+	GetRepoDigestsOnBuild(name string, imageID string) ([]string, error)
+	// LayerSizeOnBuild returns the size of the layer the last instruction
+	// of the image `imageID` added, 0 if it added none.
+	LayerSizeOnBuild(imageID string) (int64, error)
+	// MountImage mounts the filesystem of the image referenced by `name`
+	// read-only for the builder, and returns its path along with a function
+	// releasing it.
+	MountImage(name string) (string, func() error, error)
 	// TagImageWithReference tags an image with newTag
 	TagImageWithReference(image.ID, reference.Named) error
 	// PullOnBuild tells Docker to pull image referenced by `name`.
@@ -139,6 +149,17 @@ type Backend interface {
 	ContainerWait(containerID string, timeout time.Duration) (int, error)
 	// ContainerUpdateCmdOnBuild updates container.Path and container.Args
 	ContainerUpdateCmdOnBuild(containerID string, cmd []string) error
+	// ContainerSetBuildSecrets sets the secrets mounted into a container
+	// before it starts.
+	ContainerSetBuildSecrets(containerID string, secrets []backend.BuildSecret) error
+	// AcquireBuildCache returns the name of the volume backing the cache
+	// mount `id`, which no other build uses until release is called.
+	AcquireBuildCache(id string) (volume string, release func(), err error)
+	// BuildCachePrune removes the cache mounts which are not in use.
+	BuildCachePrune() (*types.BuildCachePruneReport, error)
+	// ExportBuildCache exports the steps of a build to the caches described
+	// by cacheTo, so that the builds importing them can use their images.
+	ExportBuildCache(ctx context.Context, steps []remotecache.Step, cacheTo []string, authConfigs map[string]types.AuthConfig, out io.Writer) error
 	// ContainerCreateWorkdir creates the workdir
 	ContainerCreateWorkdir(containerID string) error
 
@@ -159,6 +180,14 @@ type Backend interface {
 	// a certain party.
 	TapconModeOn() bool
 
+	// EndorseImage endorses a built image with the given property in the
+	// attestation service.
+	EndorseImage(imageID, config, property string) error
+
+	// AttachBuildTrace attaches the trace report of a build to the image it
+	// produced.
+	AttachBuildTrace(imageID string, trace *types.BuildTrace) error
+
 	// SquashImage squashes the fs layers from the provided image down to the specified `to` image
 	SquashImage(from string, to string) (string, error)
 }
@@ -171,8 +200,10 @@ type Image interface {
 
 // ImageCacheBuilder represents a generator for stateful image cache.
 type ImageCacheBuilder interface {
-	// MakeImageCache creates a stateful image cache.
-	MakeImageCache(cacheFrom []string) ImageCache
+	// MakeImageCache creates a stateful image cache. cacheFrom lists the
+	// images to match against, and the caches exported by other builds to
+	// import from.
+	MakeImageCache(ctx context.Context, cacheFrom []string, authConfigs map[string]types.AuthConfig) ImageCache
 }
 
 // ImageCache abstracts an image cache.
@@ -184,4 +184,28 @@ func TestBuilderFlags(t *testing.T) {
 	if !flBool1.IsTrue() {
 		t.Fatalf("Teset %s, bool1 should be true", bf.Args)
 	}
+
+	// ---
+
+	bf = NewBFlags()
+	flStrs := bf.AddStrings("strs")
+	bf.Args = []string{"--strs=a", "--strs=b,c"}
+
+	if err = bf.Parse(); err != nil {
+		t.Fatalf("Test %q was supposed to work: %s", bf.Args, err)
+	}
+
+	if len(flStrs.StringValues) != 2 || flStrs.StringValues[0] != "a" || flStrs.StringValues[1] != "b,c" {
+		t.Fatalf("Test %s, strs should be [a b,c], got %v", bf.Args, flStrs.StringValues)
+	}
+
+	// ---
+
+	bf = NewBFlags()
+	bf.AddStrings("strs")
+	bf.Args = []string{"--strs"}
+
+	if err = bf.Parse(); err == nil {
+		t.Fatalf("Test %q was supposed to fail", bf.Args)
+	}
 }
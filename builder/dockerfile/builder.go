@@ -2,16 +2,13 @@ package dockerfile
 
 import (
 	"bytes"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"sort"
 	"strings"
-	"unsafe"
 
 	"github.com/Sirupsen/logrus"
 	apierrors "github.com/docker/docker/api/errors"
@@ -21,18 +18,15 @@ import (
 	"github.com/docker/docker/builder"
 	"github.com/docker/docker/builder/dockerfile/command"
 	"github.com/docker/docker/builder/dockerfile/parser"
+	"github.com/docker/docker/builder/remotecache"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/reference"
+	"github.com/opencontainers/go-digest"
 	perrors "github.com/pkg/errors"
 	"golang.org/x/net/context"
 )
 
-// #cgo LDFLAGS: -lport
-// #include <stdlib.h>
-// #include "libport.h"
-import "C"
-
 var validCommitCommands = map[string]bool{
 	"cmd":         true,
 	"entrypoint":  true,
@@ -76,6 +70,7 @@ type Builder struct {
 	dockerfile       *parser.Node
 	runConfig        *container.Config // runconfig for cmd, run, entrypoint etc.
 	flags            *BFlags
+	heredocs         []parser.Heredoc // here-documents of the instruction being dispatched
 	tmpContainers    map[string]struct{}
 	image            string // imageID
 	noBaseImage      bool
@@ -85,20 +80,28 @@ type Builder struct {
 	cacheBusted      bool
 	allowedBuildArgs map[string]bool // list of build-time args that are allowed for expansion/substitution and passing to commands in 'run'.
 	directive        parser.Directive
+	dockerfileDigest string
 
 	// instruct the daemon to commit source information
 	commitSource bool
+	// repositories copied by the GIT instructions so far
+	gitSources []image.GitSource
 
 	// TODO: remove once docker.Commit can receive a tag
 	id string
 
-	imageCache builder.ImageCache
-	from       builder.Image
-	source     image.Source
-	traceKey   string
-	traceName  string
-	traceCmd   *exec.Cmd
-	traceId    int
+	imageCache    builder.ImageCache
+	from          builder.Image
+	imageContexts *imageContexts // helper for storing contexts from builds
+	stage         *imageMount    // the stage being built
+	stages        *parallelBuild // shared by the stages running concurrently
+	imageLock     map[string]digest.Digest
+	baseImage     string // pinned reference of the image the stage started from
+	tracer        *tracer
+	traceStep     int
+	step          stepProgress      // the step being dispatched
+	cacheExport   *cacheExport      // steps to export with --cache-to
+	pendingStep   *remotecache.Step // step being looked up in the cache
 }
 
 // BuildManager implements builder.Backend and is shared across all Builder objects.
@@ -117,7 +120,10 @@ func (bm *BuildManager) BuildFromContext(ctx context.Context, src io.ReadCloser,
 	if buildOptions.Squash && !bm.backend.HasExperimental() {
 		return "", apierrors.NewBadRequestError(errors.New("squash is only supported with experimental mode"))
 	}
-	buildContext, sourceCtx, dockerfileName, err := builder.DetectContextFromRemoteURL(src, remote, pg.ProgressReaderFunc, bm.backend)
+	if buildOptions.Squash && buildOptions.Reproducible {
+		return "", apierrors.NewBadRequestError(errors.New("squash is not supported for reproducible builds"))
+	}
+	buildContext, sourceCtx, dockerfileName, err := builder.DetectContextFromRemoteURL(src, remote, buildOptions.GitIdentity, pg.ProgressReaderFunc, bm.backend)
 	if err != nil {
 		return "", err
 	}
@@ -131,11 +137,6 @@ func (bm *BuildManager) BuildFromContext(ctx context.Context, src io.ReadCloser,
 		buildOptions.Dockerfile = dockerfileName
 	}
 
-	if bm.backend.TapconModeOn() && sourceCtx != nil {
-		/// insert a strace binary into the build context
-		buildContext.Add("/usr/bin/strace")
-	}
-
 	b, err := NewBuilder(ctx, buildOptions, bm.backend, builder.DockerIgnoreContext{ModifiableContext: buildContext}, sourceCtx, nil)
 	if err != nil {
 		return "", err
@@ -143,6 +144,12 @@ func (bm *BuildManager) BuildFromContext(ctx context.Context, src io.ReadCloser,
 	return b.build(pg.StdoutFormatter, pg.StderrFormatter, pg.Output)
 }
 
+// PruneCache removes the cache mounts of RUN instructions which no build
+// uses.
+func (bm *BuildManager) PruneCache() (*types.BuildCachePruneReport, error) {
+	return bm.backend.BuildCachePrune()
+}
+
 // NewBuilder creates a new Dockerfile builder from an optional dockerfile and a Config.
 // If dockerfile is nil, the Dockerfile specified by Config.DockerfileName,
 // will be read from the Context passed to Build().
@@ -173,8 +180,15 @@ func NewBuilder(clientCtx context.Context, config *types.ImageBuildOptions, back
 			LookingForDirectives: true,
 		},
 	}
+	b.imageContexts = &imageContexts{}
+	if b.imageLock, err = parseImageLock(config.ImageLock); err != nil {
+		return nil, err
+	}
 	if icb, ok := backend.(builder.ImageCacheBuilder); ok {
-		b.imageCache = icb.MakeImageCache(config.CacheFrom)
+		b.imageCache = icb.MakeImageCache(ctx, config.CacheFrom, config.AuthConfigs)
+	}
+	if len(config.CacheTo) > 0 {
+		b.cacheExport = &cacheExport{}
 	}
 
 	parser.SetEscapeToken(parser.DefaultEscapeToken, &b.directive) // Assume the default token for escape
@@ -253,6 +267,32 @@ func (b *Builder) processLabels() error {
 	return nil
 }
 
+// stopAtTarget drops the instructions following the build stage named
+// target, so that the build produces the image of that stage.
+func (b *Builder) stopAtTarget(target string) error {
+	target = strings.ToLower(target)
+	for i, n := range b.dockerfile.Children {
+		if n.Value != command.From {
+			continue
+		}
+		var args []string
+		for arg := n.Next; arg != nil; arg = arg.Next {
+			args = append(args, arg.Value)
+		}
+		if name, err := parseBuildStageName(args); err != nil || name != target {
+			continue
+		}
+		for j, next := range b.dockerfile.Children[i+1:] {
+			if next.Value == command.From {
+				b.dockerfile.Children = b.dockerfile.Children[:i+1+j]
+				break
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to reach build target %s in Dockerfile", target)
+}
+
 // build runs the Dockerfile builder from a context and a docker object that allows to make calls
 // to Docker.
 //
@@ -270,6 +310,7 @@ func (b *Builder) build(stdout io.Writer, stderr io.Writer, out io.Writer) (stri
 	b.Stdout = stdout
 	b.Stderr = stderr
 	b.Output = out
+	b.useEventWriters()
 
 	// If Dockerfile was not parsed yet, extract it from the Context
 	if b.dockerfile == nil {
@@ -283,23 +324,19 @@ func (b *Builder) build(stdout io.Writer, stderr io.Writer, out io.Writer) (stri
 		return "", err
 	}
 
+	if b.options.Target != "" {
+		if err := b.stopAtTarget(b.options.Target); err != nil {
+			return "", err
+		}
+	}
+	defer b.imageContexts.unmount()
+
 	if err := b.processLabels(); err != nil {
 		return "", err
 	}
 
 	fmt.Fprintf(b.Stdout, "Tapcon: adding source: %v\n", b.sourceCtx)
 	if b.docker.TapconModeOn() && b.sourceCtx != nil {
-		/// add tracing utility (FIXME: this could be overwritten somehow.. Prevent it)
-		_, straceNode, err := parser.ParseLine("COPY strace /usr/bin/", &b.directive, false)
-		tmpChildren := make([]*parser.Node, 0)
-		for _, n := range b.dockerfile.Children {
-			if n.Value != command.From {
-				tmpChildren = append(tmpChildren, straceNode)
-			}
-			tmpChildren = append(tmpChildren, n)
-		}
-		b.dockerfile.Children = tmpChildren
-
 		//// add a last node for committing source
 		_, node, err := parser.ParseLine("TAPCON add_source", &b.directive, false)
 		if err != nil {
@@ -307,41 +344,31 @@ func (b *Builder) build(stdout io.Writer, stderr io.Writer, out io.Writer) (stri
 			return "", err
 		}
 		b.dockerfile.Children = append(b.dockerfile.Children, node)
-		/// start tracing the build process
-		b.TraceInit("build")
 	}
 
-	var shortImgID string
-	total := len(b.dockerfile.Children)
-	for _, n := range b.dockerfile.Children {
-		if err := b.checkDispatch(n, false); err != nil {
+	// builds from trusted sources are always traced
+	if b.options.Trace || (b.docker.TapconModeOn() && b.sourceCtx != nil) {
+		if b.tracer, err = newTracer(); err != nil {
 			return "", err
 		}
+		defer func() {
+			if err := b.tracer.Close(); err != nil {
+				logrus.Debugf("[BUILDER] failed to remove trace directory: %v", err)
+			}
+		}()
 	}
 
-	for i, n := range b.dockerfile.Children {
-		select {
-		case <-b.clientCtx.Done():
-			logrus.Debug("Builder: build cancelled!")
-			fmt.Fprint(b.Stdout, "Build cancelled")
-			return "", errors.New("Build cancelled")
-		default:
-			// Not cancelled yet, keep going...
-		}
-
-		if err := b.dispatch(i, total, n); err != nil {
-			if b.options.ForceRemove {
-				b.clearTmp()
-			}
+	for _, n := range b.dockerfile.Children {
+		if err := b.checkDispatch(n, false); err != nil {
 			return "", err
 		}
+	}
 
-		shortImgID = stringid.TruncateID(b.image)
-		fmt.Fprintf(b.Stdout, " ---> %s\n", shortImgID)
-		if b.options.Remove {
-			b.clearTmp()
-		}
+	last, err := b.buildStages()
+	if err != nil {
+		return "", err
 	}
+	b.image, b.from, b.allowedBuildArgs = last.image, last.from, last.allowedBuildArgs
 
 	// check if there are any leftover build-args that were passed but not
 	// consumed during build. Return a warning, if there are any.
@@ -378,24 +405,28 @@ func (b *Builder) build(stdout io.Writer, stderr io.Writer, out io.Writer) (stri
 		}
 	}
 
+	if b.tracer != nil {
+		trace := b.tracer.report(b.image)
+		if err := b.docker.AttachBuildTrace(b.image, trace); err != nil {
+			return "", perrors.Wrap(err, "error attaching build trace")
+		}
+		fmt.Fprintf(b.Stdout, "Build trace: %d files read, %d binaries executed, %d network endpoints contacted\n",
+			len(trace.FilesRead), len(trace.Executed), len(trace.Endpoints))
+	}
+
+	if b.cacheExport != nil {
+		if err := b.docker.ExportBuildCache(b.clientCtx, b.cacheExport.list(), b.options.CacheTo, b.options.AuthConfigs, b.Stdout); err != nil {
+			return "", perrors.Wrap(err, "error exporting build cache")
+		}
+	}
+
 	if b.docker.TapconModeOn() && b.sourceCtx != nil {
-		// There is no problem to call stop trace twice, the command will
-		// be reset.
-		cimageID := C.CString(imageID.String())
-		url := b.sourceCtx.GitURL() + "#" + string(b.sourceCtx.IdentityHash()) + ":" +
-			hex.EncodeToString(b.sourceCtx.CwdHash())
-		curl := C.CString(url)
-		cconfig := C.CString("*")
-		ret, _ := C.liblatte_endorse_image(cimageID, cconfig, curl)
-		if ret != 0 {
-			logrus.Errorf("error creating image %s in metadata service", imageID.String())
+		if err := b.docker.EndorseImage(imageID.String(), "*", b.sourceIdentity().Endorsement()); err != nil {
+			logrus.Errorf("error creating image %s in metadata service: %v", imageID.String(), err)
 		}
-		C.free(unsafe.Pointer(cimageID))
-		C.free(unsafe.Pointer(curl))
-		C.free(unsafe.Pointer(cconfig))
 	}
 
-	fmt.Fprintf(b.Stdout, "Successfully built %s\n", shortImgID)
+	b.buildDone()
 	return b.image, nil
 }
 
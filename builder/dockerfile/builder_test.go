@@ -52,3 +52,42 @@ func TestBuildProcessLabels(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildStopAtTarget(t *testing.T) {
+	dockerfile := `FROM busybox AS build-env
+RUN make
+FROM build-env AS Test
+RUN make test
+FROM busybox
+COPY --from=build-env /app /app`
+	d := parser.Directive{}
+	parser.SetEscapeToken(parser.DefaultEscapeToken, &d)
+
+	expected := map[string]int{
+		"build-env": 2,
+		"test":      4,
+		"TEST":      4,
+	}
+	for target, length := range expected {
+		n, err := parser.Parse(strings.NewReader(dockerfile), &d)
+		if err != nil {
+			t.Fatalf("Error when parsing Dockerfile: %s", err)
+		}
+		b := &Builder{dockerfile: n}
+		if err := b.stopAtTarget(target); err != nil {
+			t.Fatalf("Error when stopping at target %s: %s", target, err)
+		}
+		if len(b.dockerfile.Children) != length {
+			t.Fatalf("Expect %d instructions for target %s, got %d", length, target, len(b.dockerfile.Children))
+		}
+	}
+
+	n, err := parser.Parse(strings.NewReader(dockerfile), &d)
+	if err != nil {
+		t.Fatalf("Error when parsing Dockerfile: %s", err)
+	}
+	b := &Builder{dockerfile: n}
+	if err := b.stopAtTarget("busybox"); err == nil {
+		t.Fatal("Expected an error for a target which is not a stage name")
+	}
+}
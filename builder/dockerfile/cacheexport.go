@@ -0,0 +1,51 @@
+package dockerfile
+
+import (
+	"sync"
+
+	"github.com/docker/docker/builder/remotecache"
+)
+
+// cacheExport collects the steps of a build, to export them with --cache-to
+// once the build succeeded. The stages of a build share it.
+type cacheExport struct {
+	mu    sync.Mutex
+	steps []remotecache.Step
+}
+
+func (e *cacheExport) add(step remotecache.Step) {
+	e.mu.Lock()
+	e.steps = append(e.steps, step)
+	e.mu.Unlock()
+}
+
+func (e *cacheExport) list() []remotecache.Step {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]remotecache.Step(nil), e.steps...)
+}
+
+// lookupStep keys the step about to be looked up in the build cache, if the
+// cache of the build is exported.
+func (b *Builder) lookupStep() error {
+	if b.cacheExport == nil {
+		return nil
+	}
+	key, err := remotecache.Key(b.image, b.runConfig)
+	if err != nil {
+		return err
+	}
+	b.pendingStep = &remotecache.Step{Key: key, Parent: b.image}
+	return nil
+}
+
+// recordStep records the image produced by the step last looked up.
+func (b *Builder) recordStep(imageID string) {
+	step := b.pendingStep
+	b.pendingStep = nil
+	if step == nil || imageID == step.Parent {
+		return
+	}
+	step.Image = imageID
+	b.cacheExport.add(*step)
+}
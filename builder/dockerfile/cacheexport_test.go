@@ -0,0 +1,40 @@
+package dockerfile
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestRecordStep(t *testing.T) {
+	b := &Builder{runConfig: &container.Config{Cmd: []string{"/bin/sh", "-c", "make"}}, image: "sha256:parent"}
+
+	// nothing is recorded if the cache is not exported
+	if err := b.lookupStep(); err != nil {
+		t.Fatal(err)
+	}
+	b.recordStep("sha256:child")
+	if b.pendingStep != nil {
+		t.Fatal("No step should be recorded without --cache-to")
+	}
+
+	b.cacheExport = &cacheExport{}
+	if err := b.lookupStep(); err != nil {
+		t.Fatal(err)
+	}
+	b.recordStep("sha256:child")
+	// a step which did not produce an image is not recorded
+	if err := b.lookupStep(); err != nil {
+		t.Fatal(err)
+	}
+	b.recordStep("sha256:parent")
+	b.recordStep("sha256:other")
+
+	steps := b.cacheExport.list()
+	if len(steps) != 1 {
+		t.Fatalf("Expected 1 step, got %v", steps)
+	}
+	if steps[0].Parent != "sha256:parent" || steps[0].Image != "sha256:child" || steps[0].Key == "" {
+		t.Fatalf("Unexpected step: %+v", steps[0])
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/dockerfile/parser"
 	"github.com/docker/docker/pkg/signal"
 	runconfigopts "github.com/docker/docker/runconfig/opts"
 	"github.com/docker/go-connections/nat"
@@ -168,7 +169,7 @@ func add(b *Builder, args []string, attributes map[string]bool, original string)
 		return err
 	}
 
-	return b.runContextCommand(args, true, true, "ADD")
+	return b.runContextCommand(args, true, true, "ADD", nil)
 }
 
 // COPY foo /path
@@ -180,11 +181,43 @@ func dispatchCopy(b *Builder, args []string, attributes map[string]bool, origina
 		return errAtLeastTwoArguments("COPY")
 	}
 
+	flFrom := b.flags.AddString("from", "")
+
 	if err := b.flags.Parse(); err != nil {
 		return err
 	}
 
-	return b.runContextCommand(args, false, false, "COPY")
+	var im *imageMount
+	if flFrom.IsUsed() {
+		if flFrom.Value == "" {
+			return errors.New("COPY --from requires the name or index of a build stage, or an image")
+		}
+		var err error
+		if im, err = b.imageContexts.get(b, flFrom.Value); err != nil {
+			return err
+		}
+		if b.stage != nil {
+			b.stage.use(im)
+		}
+	}
+
+	return b.runContextCommand(args, false, false, "COPY", im)
+}
+
+// GIT <url>[#ref[:subdir]] <dest>
+//
+// Clone a git repository and copy the checkout of ref, or of its sub
+// directory subdir, to dest.
+func git(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 2 {
+		return errExactlyTwoArguments("GIT")
+	}
+
+	if err := b.flags.Parse(); err != nil {
+		return err
+	}
+
+	return b.runGitCommand(args[0], args[1])
 }
 
 // FROM imagename
@@ -192,8 +225,9 @@ func dispatchCopy(b *Builder, args []string, attributes map[string]bool, origina
 // This sets the image the dockerfile will build on top of.
 //
 func from(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return errExactlyOneArgument("FROM")
+	stageName, err := parseBuildStageName(args)
+	if err != nil {
+		return err
 	}
 
 	if err := b.flags.Parse(); err != nil {
@@ -202,29 +236,51 @@ func from(b *Builder, args []string, attributes map[string]bool, original string
 
 	name := args[0]
 
-	var (
-		image builder.Image
-		err   error
-	)
+	// every stage starts from a clean state, with its own cache chain
+	b.image = ""
+	b.from = nil
+	b.baseImage = ""
+	b.noBaseImage = false
+	b.cacheBusted = false
+	b.cmdSet = false
+	b.maintainer = ""
+	b.runConfig = new(container.Config)
+
+	// the stages of a build are registered before it runs, a FROM
+	// dispatched on its own starts a new one
+	if b.stages == nil {
+		if b.stage, err = b.imageContexts.add(stageName); err != nil {
+			return err
+		}
+	}
 
-	// Windows cannot support a container with no base image.
-	if name == api.NoBaseImageSpecifier {
+	var image builder.Image
+
+	if stage := b.imageContexts.named(name, b.stage.index); stage != nil {
+		// FROM an earlier stage of the build
+		if err := b.waitStage(stage); err != nil {
+			return err
+		}
+		if stage.id == "" {
+			return fmt.Errorf("build stage %s did not produce an image", name)
+		}
+		if image, err = b.docker.GetImageOnBuild(stage.id); err != nil {
+			return err
+		}
+		b.baseImage = stage.id
+		b.stage.use(stage)
+	} else if name == api.NoBaseImageSpecifier {
+		// Windows cannot support a container with no base image.
 		if runtime.GOOS == "windows" {
 			return errors.New("Windows does not support FROM scratch")
 		}
-		b.image = ""
 		b.noBaseImage = true
-	} else {
-		// TODO: don't use `name`, instead resolve it to a digest
-		if !b.options.PullParent {
-			image, err = b.docker.GetImageOnBuild(name)
-			// TODO: shouldn't we error out if error is different from "not found" ?
-		}
-		if image == nil {
-			image, err = b.docker.PullOnBuild(b.clientCtx, name, b.options.AuthConfigs, b.Output)
-			if err != nil {
-				return err
-			}
+	} else if image, b.baseImage, err = b.getImage(name); err != nil {
+		return err
+	} else if image != nil {
+		b.stage.base = image.ImageID()
+		if b.baseImage != name && !isImageID(name, image) {
+			fmt.Fprintf(b.Stdout, " ---> Pinned %s to %s\n", name, b.baseImage)
 		}
 	}
 	b.from = image
@@ -232,6 +288,22 @@ func from(b *Builder, args []string, attributes map[string]bool, original string
 	return b.processImageFrom(image)
 }
 
+// parseBuildStageName returns the name given to a build stage with
+// FROM image AS name, or "" if the stage has no name.
+func parseBuildStageName(args []string) (string, error) {
+	switch {
+	case len(args) == 3 && strings.EqualFold(args[1], "as"):
+		stageName := strings.ToLower(args[2])
+		if !validStageName.MatchString(stageName) {
+			return "", fmt.Errorf("invalid name for build stage: %q, name can't start with a number or contain symbols", args[2])
+		}
+		return stageName, nil
+	case len(args) != 1:
+		return "", errors.New("FROM requires either one or three arguments")
+	}
+	return "", nil
+}
+
 // ONBUILD RUN echo yo
 //
 // ONBUILD triggers run when the image is used in a FROM statement.
@@ -337,18 +409,25 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 		return errors.New("Please provide a source image with `from` prior to run")
 	}
 
+	flMounts := b.flags.AddStrings("mount")
+
 	if err := b.flags.Parse(); err != nil {
 		return err
 	}
 
+	// the mounts are neither part of the cache key nor of the committed
+	// command
+	secrets, caches, err := b.runMounts(flMounts.StringValues)
+	if err != nil {
+		return err
+	}
+
 	args = handleJSONArgs(args, attributes)
 
-	/// when using tapcon, we trace run command, and do not use shell to run it
-	//if b.docker.TapconModeOn() && b.sourceCtx != nil {
-	//	args = append(b.TraceCmdPrefix(b.traceId), args...)
-	//	logrus.Debug("Tapcon trace cmd : ", args)
-	//} else
 	if !attributes["json"] {
+		if len(args) > 0 {
+			args[0] = withHeredocs(args[0], b.heredocs)
+		}
 		args = append(getShell(b.runConfig), args...)
 	}
 	config := &container.Config{
@@ -418,11 +497,19 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 		return err
 	}
 	if hit {
+		if b.tracer != nil {
+			b.tracer.skip(b.traceStep, original)
+		}
 		return nil
 	}
 
 	// set Cmd manually, this is special case only for Dockerfiles
 	b.runConfig.Cmd = config.Cmd
+	if b.tracer != nil {
+		// the tracer is only part of the command that runs, the committed
+		// command and the cache key are left untouched
+		b.runConfig.Cmd = strslice.StrSlice(b.tracer.command(b.traceStep, config.Cmd))
+	}
 	// set build-time environment for 'run'.
 	b.runConfig.Env = append(b.runConfig.Env, cmdBuildEnv...)
 	// set config as already being escaped, this prevents double escaping on windows
@@ -430,15 +517,33 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 
 	logrus.Debugf("[BUILDER] Command to be executed: %v", b.runConfig.Cmd)
 
-	cID, err := b.create()
+	mounts, releaseCaches, err := b.mountCaches(caches)
+	if err != nil {
+		return err
+	}
+	defer releaseCaches()
+
+	cID, err := b.create(mounts)
 	if err != nil {
 		return err
 	}
 
+	if len(secrets) > 0 {
+		if err := b.docker.ContainerSetBuildSecrets(cID, secrets); err != nil {
+			return err
+		}
+	}
+
 	if err := b.run(cID); err != nil {
 		return err
 	}
 
+	if b.tracer != nil {
+		if err := b.tracer.collect(b.traceStep, original); err != nil {
+			return err
+		}
+	}
+
 	// revert to original config environment and set the command string to
 	// have the build-time env vars in it (if any) so that future cache look-ups
 	// properly match it.
@@ -448,6 +553,24 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 	return b.commit(cID, cmd, "run")
 }
 
+// withHeredocs returns the command line of a RUN instruction in the shell
+// form followed by its here-documents, for the shell to read them. A RUN
+// instruction whose only argument is a here-document runs it as a script.
+// Either way the content of the here-documents is part of the command, and
+// of the cache key.
+func withHeredocs(cmdLine string, heredocs []parser.Heredoc) string {
+	if len(heredocs) == 0 {
+		return cmdLine
+	}
+	if h, ok := parser.ParseHeredocMarker(strings.TrimSpace(cmdLine)); ok && len(heredocs) == 1 && h.Name == heredocs[0].Name {
+		return heredocs[0].Content
+	}
+	for _, h := range heredocs {
+		cmdLine += "\n" + h.Content + h.Name
+	}
+	return cmdLine + "\n"
+}
+
 // CMD foo
 //
 // Set the default command to run in the container (which may be empty).
@@ -807,6 +930,10 @@ func errExactlyOneArgument(command string) error {
 	return fmt.Errorf("%s requires exactly one argument", command)
 }
 
+func errExactlyTwoArguments(command string) error {
+	return fmt.Errorf("%s requires exactly two arguments", command)
+}
+
 func errAtLeastTwoArguments(command string) error {
 	return fmt.Errorf("%s requires at least two arguments", command)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/docker/builder/dockerfile/parser"
 	"github.com/docker/go-connections/nat"
 )
 
@@ -20,7 +21,6 @@ type commandWithFunction struct {
 func TestCommandsExactlyOneArgument(t *testing.T) {
 	commands := []commandWithFunction{
 		{"MAINTAINER", func(args []string) error { return maintainer(nil, args, nil, "") }},
-		{"FROM", func(args []string) error { return from(nil, args, nil, "") }},
 		{"WORKDIR", func(args []string) error { return workdir(nil, args, nil, "") }},
 		{"USER", func(args []string) error { return user(nil, args, nil, "") }},
 		{"STOPSIGNAL", func(args []string) error { return stopSignal(nil, args, nil, "") }}}
@@ -84,6 +84,22 @@ func TestCommandsAtLeastTwoArguments(t *testing.T) {
 	}
 }
 
+func TestGitArguments(t *testing.T) {
+	for _, args := range [][]string{{"https://github.com/docker/docker.git"}, {"a", "b", "c"}} {
+		err := git(nil, args, nil, "")
+		expectedError := errExactlyTwoArguments("GIT")
+		if err == nil || err.Error() != expectedError.Error() {
+			t.Fatalf("Wrong error message for %v. Got: %v. Should be: %s", args, err, expectedError)
+		}
+	}
+
+	b := &Builder{flags: &BFlags{}, runConfig: &container.Config{}}
+	err := git(b, []string{"https://example.com/archive.tar.gz", "/src"}, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "requires the URL of a git repository") {
+		t.Fatalf("Expected an error for a URL which is not a git repository, got %v", err)
+	}
+}
+
 func TestCommandsTooManyArguments(t *testing.T) {
 	commands := []commandWithFunction{
 		{"ENV", func(args []string) error { return env(nil, args, nil, "") }},
@@ -191,6 +207,7 @@ func TestLabel(t *testing.T) {
 
 func TestFrom(t *testing.T) {
 	b := &Builder{flags: &BFlags{}, runConfig: &container.Config{}, disableCommit: true}
+	b.imageContexts = &imageContexts{}
 
 	err := from(b, []string{"scratch"}, nil, "")
 
@@ -219,6 +236,70 @@ func TestFrom(t *testing.T) {
 	}
 }
 
+func TestFromArguments(t *testing.T) {
+	invalid := []struct {
+		args          []string
+		expectedError string
+	}{
+		{[]string{}, "FROM requires either one or three arguments"},
+		{[]string{"busybox", "AS"}, "FROM requires either one or three arguments"},
+		{[]string{"busybox", "to", "base"}, "FROM requires either one or three arguments"},
+		{[]string{"busybox", "AS", "0base"}, "invalid name for build stage"},
+		{[]string{"busybox", "AS", "base:latest"}, "invalid name for build stage"},
+	}
+	for _, test := range invalid {
+		_, err := parseBuildStageName(test.args)
+		if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+			t.Fatalf("Expected error %q for %v, got %v", test.expectedError, test.args, err)
+		}
+	}
+
+	name, err := parseBuildStageName([]string{"busybox", "as", "Build-Env"})
+	if err != nil {
+		t.Fatalf("Error when parsing the stage name: %s", err.Error())
+	}
+	if name != "build-env" {
+		t.Fatalf("Stage name should be build-env, got %s", name)
+	}
+}
+
+func TestFromStages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not support FROM scratch")
+	}
+
+	b := &Builder{flags: &BFlags{}, runConfig: &container.Config{}, disableCommit: true}
+	b.imageContexts = &imageContexts{}
+
+	if err := from(b, []string{"scratch", "AS", "base"}, nil, ""); err != nil {
+		t.Fatalf("Error when executing from: %s", err.Error())
+	}
+	if _, err := b.imageContexts.get(b, "base"); err == nil || !strings.Contains(err.Error(), "from itself") {
+		t.Fatalf("Copying from the current stage should fail, got %v", err)
+	}
+	b.stage.id = "sha256:base"
+
+	if err := from(b, []string{"scratch", "AS", "BASE"}, nil, ""); err == nil || !strings.Contains(err.Error(), "duplicate name base") {
+		t.Fatalf("Reusing a stage name should fail, got %v", err)
+	}
+	if err := from(b, []string{"scratch"}, nil, ""); err != nil {
+		t.Fatalf("Error when executing from: %s", err.Error())
+	}
+
+	for _, name := range []string{"base", "BASE", "0"} {
+		im, err := b.imageContexts.get(b, name)
+		if err != nil {
+			t.Fatalf("Error when looking up stage %s: %s", name, err.Error())
+		}
+		if im.id != "sha256:base" {
+			t.Fatalf("Stage %s should be sha256:base, got %s", name, im.id)
+		}
+	}
+	if _, err := b.imageContexts.get(b, "5"); err == nil || !strings.Contains(err.Error(), "invalid build stage index") {
+		t.Fatalf("Looking up a stage which does not exist should fail, got %v", err)
+	}
+}
+
 func TestOnbuildIllegalTriggers(t *testing.T) {
 	triggers := []struct{ command, expectedError string }{
 		{"ONBUILD", "Chaining ONBUILD via `ONBUILD ONBUILD` isn't allowed"},
@@ -515,3 +596,23 @@ func TestShell(t *testing.T) {
 		t.Fatalf("Shell should be set to %s, got %s", expectedShell, b.runConfig.Shell)
 	}
 }
+
+func TestWithHeredocs(t *testing.T) {
+	script := parser.Heredoc{Name: "EOF", Content: "set -e\necho hello\n", Expand: true}
+	data := parser.Heredoc{Name: "DATA", Content: "a\nb\n"}
+	tests := []struct {
+		cmdLine  string
+		heredocs []parser.Heredoc
+		expected string
+	}{
+		{"echo hello", nil, "echo hello"},
+		{"<<EOF", []parser.Heredoc{script}, "set -e\necho hello\n"},
+		{"python3 <<EOF", []parser.Heredoc{script}, "python3 <<EOF\nset -e\necho hello\nEOF\n"},
+		{"<<EOF sh && cat <<'DATA' > /data", []parser.Heredoc{script, data}, "<<EOF sh && cat <<'DATA' > /data\nset -e\necho hello\nEOF\na\nb\nDATA\n"},
+	}
+	for _, test := range tests {
+		if cmdLine := withHeredocs(test.cmdLine, test.heredocs); cmdLine != test.expected {
+			t.Fatalf("%q: expected %q, got %q", test.cmdLine, test.expected, cmdLine)
+		}
+	}
+}
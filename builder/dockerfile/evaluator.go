@@ -34,6 +34,7 @@ var replaceEnvAllowed = map[string]bool{
 	command.Label:      true,
 	command.Add:        true,
 	command.Copy:       true,
+	command.Git:        true,
 	command.Workdir:    true,
 	command.Expose:     true,
 	command.Volume:     true,
@@ -67,6 +68,7 @@ func init() {
 		command.Env:         env,
 		command.Expose:      expose,
 		command.From:        from,
+		command.Git:         git,
 		command.Healthcheck: healthcheck,
 		command.Label:       label,
 		command.Maintainer:  maintainer,
@@ -98,7 +100,7 @@ func init() {
 func (b *Builder) dispatch(stepN int, stepTotal int, ast *parser.Node) error {
 	cmd := ast.Value
 	upperCasedCmd := strings.ToUpper(cmd)
-	b.traceId = stepN
+	b.traceStep = stepN
 
 	// To ensure the user is given a decent error message if the platform
 	// on which the daemon is running does not support a builder command.
@@ -109,8 +111,10 @@ func (b *Builder) dispatch(stepN int, stepTotal int, ast *parser.Node) error {
 	attrs := ast.Attributes
 	original := ast.Original
 	flags := ast.Flags
+	heredocs := ast.Heredocs
+	line := ast.StartLine
 	strList := []string{}
-	msg := fmt.Sprintf("Step %d/%d : %s", stepN+1, stepTotal, upperCasedCmd)
+	msg := upperCasedCmd
 
 	if len(ast.Flags) > 0 {
 		msg += " " + strings.Join(ast.Flags, " ")
@@ -192,13 +196,31 @@ func (b *Builder) dispatch(stepN int, stepTotal int, ast *parser.Node) error {
 	}
 
 	msg += " " + strings.Join(msgList, " ")
-	fmt.Fprintln(b.Stdout, msg)
+	b.stepStarted(stepN+1, stepTotal, line, msg)
+
+	// The here-documents of RUN are left to the shell, the ones of ADD and
+	// COPY are files whose variables are replaced like in words.
+	if cmd != command.Run && len(heredocs) > 0 {
+		expanded := make([]parser.Heredoc, len(heredocs))
+		for i, h := range heredocs {
+			if h.Expand {
+				content, err := ProcessHeredoc(h.Content, envs, b.directive.EscapeToken)
+				if err != nil {
+					return err
+				}
+				h.Content = content
+			}
+			expanded[i] = h
+		}
+		heredocs = expanded
+	}
 
 	// XXX yes, we skip any cmds that are not valid; the parser should have
 	// picked these out already.
 	if f, ok := evaluateTable[cmd]; ok {
 		b.flags = NewBFlags()
 		b.flags.Args = flags
+		b.heredocs = heredocs
 		fmt.Printf("debug: cmd %s, strList %v\n\n", cmd, strList)
 		return f(b, strList, attrs, original)
 	}
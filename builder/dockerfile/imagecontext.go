@@ -0,0 +1,234 @@
+package dockerfile
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/builder"
+)
+
+// validStageName matches the names given to build stages with FROM ... AS.
+var validStageName = regexp.MustCompile(`^[a-z][a-z0-9-_\.]*$`)
+
+// imageContexts keeps track of the stages of a multi-stage build, and of the
+// images mounted to copy files from with COPY --from. It is shared by the
+// stages of a build, which may run concurrently.
+type imageContexts struct {
+	mu     sync.Mutex
+	list   []*imageMount
+	byName map[string]*imageMount
+	// images mounted for COPY --from=<image>, by reference
+	byImage map[string]*imageMount
+}
+
+// imageMount is a stage, or an image which is not a stage of the build. Its
+// filesystem is only mounted once files are copied from it.
+type imageMount struct {
+	index int           // index of the stage, -1 for other images
+	done  chan struct{} // closed once the stage is built
+	id    string
+	// base is the ID of the image the stage started from, unless it is
+	// scratch or an earlier stage.
+	base string
+	// deps are the stages and images the stage started from or copied
+	// files from. They are only changed by the builder of the stage.
+	deps []*imageMount
+
+	mu      sync.Mutex
+	ctx     builder.Context
+	release func() error
+}
+
+// add registers a new stage, named name if it is not empty.
+func (ic *imageContexts) add(name string) (*imageMount, error) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	im := &imageMount{index: len(ic.list), done: make(chan struct{})}
+	if name != "" {
+		if ic.byName == nil {
+			ic.byName = make(map[string]*imageMount)
+		}
+		if _, ok := ic.byName[name]; ok {
+			return nil, fmt.Errorf("duplicate name %s", name)
+		}
+		ic.byName[name] = im
+	}
+	ic.list = append(ic.list, im)
+	return im, nil
+}
+
+// named returns the stage named name among the stages preceding the stage
+// before, or nil if there is no such stage.
+func (ic *imageContexts) named(name string, before int) *imageMount {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	if im, ok := ic.byName[strings.ToLower(name)]; ok && im.index < before {
+		return im
+	}
+	return nil
+}
+
+// stage returns the stage named name, or whose index is name, among the
+// stages preceding the stage before, or nil if there is no such stage.
+func (ic *imageContexts) stage(name string, before int) *imageMount {
+	if im := ic.named(name, before); im != nil {
+		return im
+	}
+	index, err := strconv.Atoi(name)
+	if err != nil || index < 0 || index >= before {
+		return nil
+	}
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if index >= len(ic.list) {
+		return nil
+	}
+	return ic.list[index]
+}
+
+// get returns the stage or image the current stage of b copies files from. A
+// stage is referred to by its name or its index; anything else is an image
+// reference, which is pulled if it is not available locally. A stage which
+// is still building is waited for.
+func (ic *imageContexts) get(b *Builder, indexOrName string) (*imageMount, error) {
+	current, before := b.stage, 0
+	if current != nil {
+		before = current.index + 1
+	}
+	if im := ic.stage(indexOrName, before); im != nil {
+		if im == current {
+			return nil, fmt.Errorf("build stage %s cannot copy files from itself", indexOrName)
+		}
+		if err := b.waitStage(im); err != nil {
+			return nil, err
+		}
+		if im.id == "" {
+			return nil, fmt.Errorf("build stage %s did not produce an image", indexOrName)
+		}
+		return im, nil
+	}
+	if _, err := strconv.Atoi(indexOrName); err == nil {
+		return nil, fmt.Errorf("invalid build stage index %s", indexOrName)
+	}
+
+	ic.mu.Lock()
+	im, ok := ic.byImage[indexOrName]
+	ic.mu.Unlock()
+	if ok {
+		return im, nil
+	}
+	img, _, err := b.getImage(indexOrName)
+	if err != nil {
+		return nil, err
+	}
+	if img == nil {
+		return nil, fmt.Errorf("cannot copy from %s, it has no filesystem", indexOrName)
+	}
+
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	// another stage may have looked the image up in the meantime
+	if im, ok := ic.byImage[indexOrName]; ok {
+		return im, nil
+	}
+	im = &imageMount{index: -1, id: img.ImageID()}
+	if ic.byImage == nil {
+		ic.byImage = make(map[string]*imageMount)
+	}
+	ic.byImage[indexOrName] = im
+	return im, nil
+}
+
+// use records that the stage im starts from, or copies files from, dep.
+func (im *imageMount) use(dep *imageMount) {
+	for _, d := range im.deps {
+		if d == dep {
+			return
+		}
+	}
+	im.deps = append(im.deps, dep)
+}
+
+// images returns the sorted IDs of the images, other than stages of the
+// build, which the stage im is made of: the images it and the stages it
+// depends on started from, and the images they copied files from. The
+// stages it depends on must be built.
+func (im *imageMount) images() []string {
+	seen := make(map[*imageMount]bool)
+	ids := make(map[string]bool)
+	var walk func(m *imageMount)
+	walk = func(m *imageMount) {
+		if seen[m] {
+			return
+		}
+		seen[m] = true
+		if m.index < 0 {
+			ids[m.id] = true
+			return
+		}
+		if m.base != "" {
+			ids[m.base] = true
+		}
+		for _, d := range m.deps {
+			walk(d)
+		}
+	}
+	walk(im)
+
+	var list []string
+	for id := range ids {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// context returns a build context over the filesystem of the image, mounting
+// it if it was not yet.
+func (im *imageMount) context(docker builder.Backend) (builder.Context, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if im.ctx != nil {
+		return im.ctx, nil
+	}
+	dir, release, err := docker.MountImage(im.id)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := builder.NewLazyContext(dir)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	im.ctx, im.release = ctx, release
+	return ctx, nil
+}
+
+// unmount releases the filesystems mounted during the build.
+func (ic *imageContexts) unmount() {
+	ic.mu.Lock()
+	mounts := append([]*imageMount{}, ic.list...)
+	for _, im := range ic.byImage {
+		mounts = append(mounts, im)
+	}
+	ic.mu.Unlock()
+
+	for _, im := range mounts {
+		im.mu.Lock()
+		if im.release != nil {
+			if err := im.release(); err != nil {
+				logrus.Errorf("Failed to unmount image %s: %v", im.id, err)
+			}
+			im.ctx, im.release = nil, nil
+		}
+		im.mu.Unlock()
+	}
+}
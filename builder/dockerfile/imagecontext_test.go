@@ -0,0 +1,49 @@
+package dockerfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImageMountImages(t *testing.T) {
+	ic := &imageContexts{}
+	build, err := ic.add("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools, err := ic.add("tools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	final, err := ic.add("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused, err := ic.add("unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// FROM golang AS build
+	build.base = "sha256:golang"
+	// FROM build AS tools
+	// COPY --from=busybox
+	tools.use(build)
+	tools.use(&imageMount{index: -1, id: "sha256:busybox"})
+	// FROM alpine
+	// COPY --from=tools
+	// COPY --from=build
+	final.base = "sha256:alpine"
+	final.use(tools)
+	final.use(build)
+	// FROM debian AS unused
+	unused.base = "sha256:debian"
+
+	expected := []string{"sha256:alpine", "sha256:busybox", "sha256:golang"}
+	if images := final.images(); !reflect.DeepEqual(images, expected) {
+		t.Fatalf("expected images %v, got %v", expected, images)
+	}
+	if images := unused.images(); !reflect.DeepEqual(images, []string{"sha256:debian"}) {
+		t.Fatalf("expected only the base image of an independent stage, got %v", images)
+	}
+}
@@ -0,0 +1,91 @@
+package dockerfile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/builder"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+// parseImageLock normalizes the references of an image lock, so that
+// "busybox" and "docker.io/library/busybox:latest" are the same entry, and
+// validates its digests.
+func parseImageLock(lock map[string]string) (map[string]digest.Digest, error) {
+	if len(lock) == 0 {
+		return nil, nil
+	}
+	imageLock := make(map[string]digest.Digest)
+	for name, value := range lock {
+		ref, err := reference.ParseNamed(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image lock: %v", err)
+		}
+		ref = reference.WithDefaultTag(ref)
+		dgst, err := digest.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image lock digest %q for %s: %v", value, name, err)
+		}
+		if other, ok := imageLock[ref.String()]; ok && other != dgst {
+			return nil, fmt.Errorf("image lock has conflicting digests for %s", ref.String())
+		}
+		imageLock[ref.String()] = dgst
+	}
+	return imageLock, nil
+}
+
+// isImageID returns whether name refers to img by its ID: the full ID, with
+// or without its algorithm, or a prefix of at least 12 characters of it, as
+// printed by `docker images`. Shorter prefixes are names: "cafe" is a
+// repository to look up in the image lock even if the ID of the image it
+// resolved to starts with it.
+func isImageID(name string, img builder.Image) bool {
+	hex := strings.TrimPrefix(name, digest.Canonical.String()+":")
+	if !stringid.IsShortID(stringid.TruncateID(hex)) {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimPrefix(img.ImageID(), digest.Canonical.String()+":"), hex)
+}
+
+// pinImage returns the reference `name` pinned to the digest of the image it
+// resolved to: the digest of the manifest the image was pulled with, or the
+// ID of the image if it was not pulled from the repository of `name`. It
+// fails if the image lock of the build requires another digest. An image
+// referred to by its ID is already pinned, and is not in the image lock.
+func (b *Builder) pinImage(name string, img builder.Image) (string, error) {
+	if isImageID(name, img) {
+		return img.ImageID(), nil
+	}
+	ref, err := reference.ParseNamed(name)
+	if err != nil {
+		return "", err
+	}
+	ref = reference.WithDefaultTag(ref)
+
+	digests, err := b.docker.GetRepoDigestsOnBuild(name, img.ImageID())
+	if err != nil {
+		return "", err
+	}
+	pinned := img.ImageID()
+	if len(digests) > 0 {
+		pinned = reference.TrimNamed(ref).String() + "@" + digests[0]
+	}
+	if b.imageLock == nil {
+		return pinned, nil
+	}
+	locked, ok := b.imageLock[ref.String()]
+	if !ok {
+		if _, isCanonical := ref.(reference.Canonical); isCanonical {
+			return pinned, nil
+		}
+		return "", fmt.Errorf("%s is not pinned by the image lock", ref.String())
+	}
+	for _, d := range append(digests, img.ImageID()) {
+		if d == locked.String() {
+			return pinned, nil
+		}
+	}
+	return "", fmt.Errorf("%s resolved to %s, but the image lock pins it to %s", ref.String(), pinned, locked)
+}
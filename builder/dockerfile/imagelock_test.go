@@ -0,0 +1,125 @@
+package dockerfile
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/builder"
+)
+
+const (
+	testManifestDigest = "sha256:817a12c32a39bbe394944ba49de563e085f1d3c5266eb8e9723256bc4448680e"
+	testImageID        = "sha256:7968321274dc6b6171697c33df7815310468e694ac5be0ec03ff053bb135e768"
+)
+
+type pinBackend struct {
+	builder.Backend
+	digests []string
+}
+
+func (b *pinBackend) GetRepoDigestsOnBuild(name string, imageID string) ([]string, error) {
+	return b.digests, nil
+}
+
+type testImage struct {
+	id string
+}
+
+func (i *testImage) ImageID() string {
+	return i.id
+}
+
+func (i *testImage) RunConfig() *container.Config {
+	return nil
+}
+
+func TestParseImageLock(t *testing.T) {
+	lock, err := parseImageLock(map[string]string{
+		"busybox":                          testManifestDigest,
+		"docker.io/library/busybox:latest": testManifestDigest,
+		"golang:1.7":                       testImageID,
+	})
+	if err != nil {
+		t.Fatalf("Error parsing the image lock: %v", err)
+	}
+	if len(lock) != 2 || lock["busybox:latest"].String() != testManifestDigest || lock["golang:1.7"].String() != testImageID {
+		t.Fatalf("Unexpected image lock: %v", lock)
+	}
+
+	invalid := []struct {
+		lock          map[string]string
+		expectedError string
+	}{
+		{map[string]string{"Busybox": testManifestDigest}, "invalid image lock"},
+		{map[string]string{"busybox": "latest"}, "invalid image lock digest"},
+		{map[string]string{"busybox": testManifestDigest, "busybox:latest": testImageID}, "conflicting digests"},
+	}
+	for _, test := range invalid {
+		if _, err := parseImageLock(test.lock); err == nil || !strings.Contains(err.Error(), test.expectedError) {
+			t.Fatalf("Expected error %q for %v, got %v", test.expectedError, test.lock, err)
+		}
+	}
+}
+
+func TestPinImage(t *testing.T) {
+	img := &testImage{id: testImageID}
+	tests := []struct {
+		digests       []string
+		lock          map[string]string
+		pinned        string
+		expectedError string
+	}{
+		{nil, nil, testImageID, ""},
+		{[]string{testManifestDigest}, nil, "busybox@" + testManifestDigest, ""},
+		{[]string{testManifestDigest}, map[string]string{"busybox": testManifestDigest}, "busybox@" + testManifestDigest, ""},
+		{[]string{testManifestDigest}, map[string]string{"busybox": testImageID}, "busybox@" + testManifestDigest, ""},
+		{nil, map[string]string{"busybox": testManifestDigest}, "", "but the image lock pins it to " + testManifestDigest},
+		{[]string{testManifestDigest}, map[string]string{"golang": testImageID}, "", "busybox:latest is not pinned by the image lock"},
+	}
+	for _, test := range tests {
+		lock, err := parseImageLock(test.lock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := &Builder{Stdout: ioutil.Discard, docker: &pinBackend{digests: test.digests}, imageLock: lock}
+		pinned, err := b.pinImage("busybox", img)
+		if test.expectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Fatalf("Expected error %q, got %v", test.expectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error pinning the image: %v", err)
+		}
+		if pinned != test.pinned {
+			t.Fatalf("Expected busybox to be pinned to %s, got %s", test.pinned, pinned)
+		}
+	}
+
+	// images referred to by their ID are not looked up in the image lock
+	lock, err := parseImageLock(map[string]string{"golang": testImageID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Builder{Stdout: ioutil.Discard, docker: &pinBackend{}, imageLock: lock}
+	for _, name := range []string{testImageID, strings.TrimPrefix(testImageID, "sha256:"), testImageID[7:19]} {
+		pinned, err := b.pinImage(name, img)
+		if err != nil {
+			t.Fatalf("Error pinning the image %s: %v", name, err)
+		}
+		if pinned != testImageID {
+			t.Fatalf("Expected %s to be pinned to %s, got %s", name, testImageID, pinned)
+		}
+	}
+
+	// short names made of hex characters are repositories, which the image
+	// lock must pin, even if the ID of the image starts with them
+	for _, name := range []string{testImageID[7:11], testImageID[7:18], "sha256:" + testImageID[7:11]} {
+		if _, err := b.pinImage(name, img); err == nil || !strings.Contains(err.Error(), "is not pinned by the image lock") {
+			t.Fatalf("Expected %s not to be pinned by the image lock, got %v", name, err)
+		}
+	}
+}
@@ -19,14 +19,17 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/backend"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/builder"
 	"github.com/docker/docker/builder/dockerfile/parser"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/gitutils"
 	"github.com/docker/docker/pkg/httputils"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/jsonmessage"
@@ -37,6 +40,7 @@ import (
 	"github.com/docker/docker/pkg/tarsum"
 	"github.com/docker/docker/pkg/urlutil"
 	"github.com/docker/docker/runconfig/opts"
+	"github.com/opencontainers/go-digest"
 )
 
 func (b *Builder) commit(id string, autoCmd strslice.StrSlice, comment string) error {
@@ -59,7 +63,7 @@ func (b *Builder) commit(id string, autoCmd strslice.StrSlice, comment string) e
 		} else if hit {
 			return nil
 		}
-		id, err = b.create()
+		id, err = b.create(nil)
 		if err != nil {
 			return err
 		}
@@ -69,22 +73,20 @@ func (b *Builder) commit(id string, autoCmd strslice.StrSlice, comment string) e
 	autoConfig := *b.runConfig
 	autoConfig.Cmd = autoCmd
 
-	sourceInfo := image.Source{}
-	if b.docker.TapconModeOn() && b.commitSource && b.sourceCtx != nil {
-		sourceInfo.Repo = b.sourceCtx.GitURL()
-		sourceInfo.Revision = hex.EncodeToString(b.sourceCtx.IdentityHash())
-		sourceInfo.Dir = hex.EncodeToString(b.sourceCtx.CwdHash())
-		fmt.Fprintf(b.Stdout, "Tapcon, filling the source info, %v\n", sourceInfo)
-		/// the argument currently serves as only a placeholder
-	}
-
 	commitCfg := &backend.ContainerCommitConfig{
 		ContainerCommitConfig: types.ContainerCommitConfig{
 			Author: b.maintainer,
 			Pause:  true,
 			Config: &autoConfig,
 		},
-		TapconData: sourceInfo,
+	}
+	commitCfg.BaseImage = b.baseImage
+	if b.options.Reproducible {
+		commitCfg.Reproducible = true
+		commitCfg.SourceDateEpoch = time.Unix(b.options.SourceDateEpoch, 0).UTC()
+	}
+	if b.docker.TapconModeOn() && b.commitSource && b.sourceCtx != nil {
+		commitCfg.TapconData = b.provenance()
 	}
 
 	// Commit the container
@@ -94,6 +96,7 @@ func (b *Builder) commit(id string, autoCmd strslice.StrSlice, comment string) e
 	}
 
 	b.image = imageID
+	b.recordStep(imageID)
 	return nil
 }
 
@@ -102,8 +105,17 @@ type copyInfo struct {
 	decompress bool
 }
 
-func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalDecompression bool, cmdName string) error {
-	if b.context == nil {
+// runContextCommand copies files from the build context, or from the
+// filesystem of imageSource if it is not nil.
+func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalDecompression bool, cmdName string, imageSource *imageMount) error {
+	srcContext := b.context
+	if imageSource != nil {
+		var err error
+		if srcContext, err = imageSource.context(b.docker); err != nil {
+			return err
+		}
+	}
+	if srcContext == nil {
 		return fmt.Errorf("No context given. Impossible to use %s", cmdName)
 	}
 
@@ -125,6 +137,15 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalD
 	for _, orig := range args[0 : len(args)-1] {
 		var fi builder.FileInfo
 		decompress := allowLocalDecompression
+		if h, ok := heredocSource(orig, b.heredocs); ok {
+			fi, err = writeHeredoc(h)
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(filepath.Dir(fi.Path()))
+			infos = append(infos, copyInfo{fi, false})
+			continue
+		}
 		if urlutil.IsURL(orig) {
 			if !allowRemote {
 				return fmt.Errorf("Source can't be a URL for %s", cmdName)
@@ -139,7 +160,7 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalD
 			continue
 		}
 		// not a URL
-		subInfos, err := b.calcCopyInfo(cmdName, orig, allowLocalDecompression, true)
+		subInfos, err := b.sourceInfo(srcContext, cmdName, orig, allowLocalDecompression, imageSource == nil)
 		if err != nil {
 			return err
 		}
@@ -214,6 +235,98 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowLocalD
 	return b.commit(container.ID, cmd, comment)
 }
 
+// runGitCommand clones the repository at remoteURL and copies the checkout
+// to dest, like COPY does for a directory of the context. The cache is keyed
+// on the commit the reference of the URL resolved to, so that a moving
+// reference is fetched again while the same commit reached through another
+// URL is not.
+func (b *Builder) runGitCommand(remoteURL, dest string) error {
+	if !urlutil.IsGitURL(remoteURL) {
+		return fmt.Errorf("GIT requires the URL of a git repository, got %s", remoteURL)
+	}
+	src := image.GitSource{Repo: remoteURL, Dest: dest}
+	if i := strings.Index(remoteURL, "#"); i >= 0 {
+		src.Repo = remoteURL[:i]
+		refAndDir := strings.SplitN(remoteURL[i+1:], ":", 2)
+		src.Ref = refAndDir[0]
+		if len(refAndDir) > 1 {
+			src.Dir = refAndDir[1]
+		}
+	}
+
+	root, dir, commit, err := gitutils.CloneCommit(remoteURL)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+	src.Commit = commit
+	fmt.Fprintf(b.Stdout, " ---> Checked out %s\n", commit)
+
+	// the git metadata of the repository and of its submodules is not part
+	// of the checkout
+	if err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Name() == ".git" {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	b.gitSources = append(b.gitSources, src)
+
+	// Work in daemon-specific filepath semantics
+	dest = filepath.FromSlash(dest)
+	b.runConfig.Image = b.image
+
+	srcHash := "git:" + commit
+	if src.Dir != "" {
+		srcHash += ":" + src.Dir
+	}
+	cmd := b.runConfig.Cmd
+	b.runConfig.Cmd = strslice.StrSlice(append(getShell(b.runConfig), fmt.Sprintf("#(nop) GIT %s in %s ", srcHash, dest)))
+	defer func(cmd strslice.StrSlice) { b.runConfig.Cmd = cmd }(cmd)
+
+	if hit, err := b.probeCache(); err != nil {
+		return err
+	} else if hit {
+		return nil
+	}
+
+	container, err := b.docker.ContainerCreate(types.ContainerCreateConfig{Config: b.runConfig})
+	if err != nil {
+		return err
+	}
+	b.tmpContainers[container.ID] = struct{}{}
+
+	comment := fmt.Sprintf("GIT %s in %s", remoteURL, dest)
+
+	// Twiddle the destination when it's a relative path - meaning, make it
+	// relative to the WORKINGDIR
+	if dest, err = normaliseDest("GIT", b.runConfig.WorkingDir, dest); err != nil {
+		return err
+	}
+
+	info := builder.PathFileInfo{FileInfo: fi, FilePath: dir}
+	if err := b.docker.CopyOnBuild(container.ID, dest, info, false); err != nil {
+		return err
+	}
+
+	return b.commit(container.ID, cmd, comment)
+}
+
 func (b *Builder) download(srcURL string) (fi builder.FileInfo, err error) {
 	// get filename from URL
 	u, err := url.Parse(srcURL)
@@ -253,8 +366,7 @@ func (b *Builder) download(srcURL string) (fi builder.FileInfo, err error) {
 		return
 	}
 
-	stdoutFormatter := b.Stdout.(*streamformatter.StdoutFormatter)
-	progressOutput := stdoutFormatter.StreamFormatter.NewProgressOutput(stdoutFormatter.Writer, true)
+	progressOutput := streamformatter.NewJSONStreamFormatter().NewProgressOutput(b.Output, true)
 	progressReader := progress.NewProgressReader(resp.Body, progressOutput, resp.ContentLength, "", "Downloading")
 	// Download and dump result to tmp file
 	if _, err = io.Copy(tmpFile, progressReader); err != nil {
@@ -305,7 +417,75 @@ func (b *Builder) download(srcURL string) (fi builder.FileInfo, err error) {
 	return &builder.HashedFileInfo{FileInfo: builder.PathFileInfo{FileInfo: tmpFileSt, FilePath: tmpFileName}, FileHash: hash}, nil
 }
 
-func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression, allowWildcards bool) ([]copyInfo, error) {
+// heredocSource returns the here-document a source of an ADD or COPY
+// instruction refers to, if any.
+func heredocSource(src string, heredocs []parser.Heredoc) (parser.Heredoc, bool) {
+	marker, ok := parser.ParseHeredocMarker(src)
+	if !ok {
+		return parser.Heredoc{}, false
+	}
+	for _, h := range heredocs {
+		if h.Name == marker.Name {
+			return h, true
+		}
+	}
+	return parser.Heredoc{}, false
+}
+
+// writeHeredoc writes the content of a here-document to a file named after
+// it in a temporary directory, which the caller removes. The hash of the file
+// is the hash of its name and content, so that the content is part of the
+// cache key.
+func writeHeredoc(h parser.Heredoc) (fi builder.FileInfo, err error) {
+	tmpDir, err := ioutils.TempDir("", "docker-heredoc")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(tmpDir)
+		}
+	}()
+	tmpFileName := filepath.Join(tmpDir, h.Name)
+	if err = ioutil.WriteFile(tmpFileName, []byte(h.Content), 0644); err != nil {
+		return
+	}
+	// reset what WriteFile took from the umask and the clock, so that the
+	// copied file only depends on the here-document
+	if err = os.Chmod(tmpFileName, 0644); err != nil {
+		return
+	}
+	if err = system.Chtimes(tmpFileName, time.Time{}, time.Time{}); err != nil {
+		return
+	}
+	st, err := os.Stat(tmpFileName)
+	if err != nil {
+		return
+	}
+	hasher := sha256.New()
+	hasher.Write([]byte(h.Name + "\x00" + h.Content))
+	hash := "heredoc:" + hex.EncodeToString(hasher.Sum(nil))
+	return &builder.HashedFileInfo{FileInfo: builder.PathFileInfo{FileInfo: st, FilePath: tmpFileName}, FileHash: hash}, nil
+}
+
+// sourceInfo returns the information needed to copy a source of an ADD or
+// COPY instruction. Sources in the build context may have been hashed while
+// the build started.
+func (b *Builder) sourceInfo(srcContext builder.Context, cmdName, origPath string, allowLocalDecompression, inBuildContext bool) ([]copyInfo, error) {
+	if inBuildContext && b.stages != nil {
+		if f, ok := b.stages.sources[copySource{path: origPath, decompress: allowLocalDecompression}]; ok {
+			select {
+			case <-f.done:
+			case <-b.clientCtx.Done():
+				return nil, errCancelled
+			}
+			return f.infos, f.err
+		}
+	}
+	return b.calcCopyInfo(srcContext, cmdName, origPath, allowLocalDecompression, true)
+}
+
+func (b *Builder) calcCopyInfo(srcContext builder.Context, cmdName, origPath string, allowLocalDecompression, allowWildcards bool) ([]copyInfo, error) {
 
 	// Work in daemon-specific OS filepath semantics
 	origPath = filepath.FromSlash(origPath)
@@ -318,7 +498,7 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 	// Deal with wildcards
 	if allowWildcards && containsWildcards(origPath) {
 		var copyInfos []copyInfo
-		if err := b.context.Walk("", func(path string, info builder.FileInfo, err error) error {
+		if err := srcContext.Walk("", func(path string, info builder.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
@@ -332,7 +512,7 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 
 			// Note we set allowWildcards to false in case the name has
 			// a * in it
-			subInfos, err := b.calcCopyInfo(cmdName, path, allowLocalDecompression, false)
+			subInfos, err := b.calcCopyInfo(srcContext, cmdName, path, allowLocalDecompression, false)
 			if err != nil {
 				return err
 			}
@@ -346,7 +526,7 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 
 	// Must be a dir or a file
 
-	statPath, fi, err := b.context.Stat(origPath)
+	statPath, fi, err := srcContext.Stat(origPath)
 	if err != nil {
 		return nil, err
 	}
@@ -365,7 +545,7 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 	}
 	// Must be a dir
 	var subfiles []string
-	err = b.context.Walk(statPath, func(path string, info builder.FileInfo, err error) error {
+	err = srcContext.Walk(statPath, func(path string, info builder.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -385,6 +565,57 @@ func (b *Builder) calcCopyInfo(cmdName, origPath string, allowLocalDecompression
 	return copyInfos, nil
 }
 
+// getImage returns the image referenced by name, pulling it if it is not
+// available locally or if the build asks for pulling, along with name pinned
+// to the digest of the image. It returns nil for the scratch image.
+func (b *Builder) getImage(name string) (builder.Image, string, error) {
+	if name == api.NoBaseImageSpecifier {
+		return nil, "", nil
+	}
+
+	var (
+		image builder.Image
+		err   error
+	)
+	if f, ok := b.prefetchedImage(name); ok {
+		image, err = f.wait(b)
+	} else {
+		image, err = b.resolveImage(name)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	pinned, err := b.pinImage(name, image)
+	if err != nil {
+		return nil, "", err
+	}
+	return image, pinned, nil
+}
+
+// resolveImage returns the image referenced by name, pulling it if it is not
+// available locally or if the build asks for pulling.
+func (b *Builder) resolveImage(name string) (builder.Image, error) {
+	var image builder.Image
+	if !b.options.PullParent {
+		image, _ = b.docker.GetImageOnBuild(name)
+		// TODO: shouldn't we error out if error is different from "not found" ?
+	}
+	if image == nil {
+		return b.docker.PullOnBuild(b.clientCtx, name, b.options.AuthConfigs, b.Output)
+	}
+	return image, nil
+}
+
+// prefetchedImage returns the fetch of the image referenced by name, if the
+// build looked it up before the stage using it started.
+func (b *Builder) prefetchedImage(name string) (*imageFetch, bool) {
+	if b.stages == nil {
+		return nil, false
+	}
+	f, ok := b.stages.images[name]
+	return f, ok
+}
+
 func (b *Builder) processImageFrom(img builder.Image) error {
 	if img != nil {
 		b.image = img.ImageID()
@@ -425,6 +656,10 @@ func (b *Builder) processImageFrom(img builder.Image) error {
 	onBuildTriggers := b.runConfig.OnBuild
 	b.runConfig.OnBuild = []string{}
 
+	// the triggers are dispatched as steps of their own, within the step
+	// of the FROM instruction
+	defer func(step stepProgress) { b.step = step }(b.step)
+
 	// parse the ONBUILD triggers by invoking the parser
 	for _, step := range onBuildTriggers {
 		ast, err := parser.Parse(strings.NewReader(step), &b.directive)
@@ -453,9 +688,13 @@ func (b *Builder) processImageFrom(img builder.Image) error {
 // If no image is found, it returns `(false, nil)`.
 // If there is any error, it returns `(false, err)`.
 func (b *Builder) probeCache() (bool, error) {
+	if err := b.lookupStep(); err != nil {
+		return false, err
+	}
+	parent := b.image
 	c := b.imageCache
 	if c == nil || b.options.NoCache || b.cacheBusted {
-		return false, nil
+		return false, b.cacheProbed(parent, false)
 	}
 	cache, err := c.GetCache(b.image, b.runConfig)
 	if err != nil {
@@ -464,17 +703,20 @@ func (b *Builder) probeCache() (bool, error) {
 	if len(cache) == 0 {
 		logrus.Debugf("[BUILDER] Cache miss: %s", b.runConfig.Cmd)
 		b.cacheBusted = true
-		return false, nil
+		return false, b.cacheProbed(parent, false)
 	}
 
-	fmt.Fprint(b.Stdout, " ---> Using cache\n")
+	if err := b.cacheProbed(parent, true); err != nil {
+		return false, err
+	}
 	logrus.Debugf("[BUILDER] Use cached version: %s", b.runConfig.Cmd)
 	b.image = string(cache)
+	b.recordStep(b.image)
 
 	return true, nil
 }
 
-func (b *Builder) create() (string, error) {
+func (b *Builder) create(mounts []mount.Mount) (string, error) {
 	if b.image == "" && !b.noBaseImage {
 		return "", errors.New("Please provide a source image with `from` prior to run")
 	}
@@ -499,12 +741,11 @@ func (b *Builder) create() (string, error) {
 		ShmSize:     b.options.ShmSize,
 		Resources:   resources,
 		NetworkMode: container.NetworkMode(b.options.NetworkMode),
+		Mounts:      mounts,
 	}
-	/// allow tracing during build!
-	if b.docker.TapconModeOn() && b.sourceCtx != nil {
-		// pure linux hack
-		hostConfig.CapAdd = strslice.StrSlice{"sys_ptrace"}
-		hostConfig.Binds = b.setupTraceVolume()
+	if b.tracer != nil {
+		hostConfig.CapAdd = strslice.StrSlice{"SYS_PTRACE"}
+		hostConfig.Binds = b.tracer.binds()
 	}
 
 	config := *b.runConfig
@@ -535,9 +776,10 @@ func (b *Builder) create() (string, error) {
 var errCancelled = errors.New("build cancelled")
 
 func (b *Builder) run(cID string) (err error) {
+	stdout, stderr := b.runOutput()
 	errCh := make(chan error)
 	go func() {
-		errCh <- b.docker.ContainerAttachRaw(cID, nil, b.Stdout, b.Stderr, true)
+		errCh <- b.docker.ContainerAttachRaw(cID, nil, stdout, stderr, true)
 	}()
 
 	finished := make(chan struct{})
@@ -646,6 +888,52 @@ func (b *Builder) readDockerfile() error {
 	return nil
 }
 
+// sourceIdentity identifies the trusted sources of the ongoing build.
+func (b *Builder) sourceIdentity() *image.Source {
+	return &image.Source{
+		Repo:     b.sourceCtx.GitURL(),
+		Revision: string(b.sourceCtx.IdentityHash()),
+		Dir:      hex.EncodeToString(b.sourceCtx.CwdHash()),
+	}
+}
+
+// provenance describes the ongoing build for the image it commits.
+func (b *Builder) provenance() *image.Source {
+	source := b.sourceIdentity()
+	if b.options.GitIdentity != nil {
+		source.UnverifiedRepo = b.options.GitIdentity.Remote
+	}
+	source.File = b.options.Dockerfile
+	source.FileDigest = b.dockerfileDigest
+	// the provenance of a reproducible image only depends on the build
+	created := time.Now().UTC()
+	if b.options.Reproducible {
+		created = time.Unix(b.options.SourceDateEpoch, 0).UTC()
+	}
+	source.Time = created.Format(time.RFC3339Nano)
+	if b.from != nil {
+		source.BaseImage = b.from.ImageID()
+	}
+	if b.stage != nil {
+		for _, id := range b.stage.images() {
+			if id != source.BaseImage {
+				source.Images = append(source.Images, id)
+			}
+		}
+	}
+	source.Git = b.gitSources
+	for k, v := range b.options.BuildArgs {
+		if v == nil || !b.isBuildArgAllowed(k) {
+			continue
+		}
+		if source.BuildArgs == nil {
+			source.BuildArgs = make(map[string]string)
+		}
+		source.BuildArgs[k] = *v
+	}
+	return source
+}
+
 func (b *Builder) parseDockerfile() error {
 	f, err := b.context.Open(b.options.Dockerfile)
 	if err != nil {
@@ -665,10 +953,12 @@ func (b *Builder) parseDockerfile() error {
 			return fmt.Errorf("The Dockerfile (%s) cannot be empty", b.options.Dockerfile)
 		}
 	}
-	b.dockerfile, err = parser.Parse(f, &b.directive)
+	digester := digest.Canonical.Digester()
+	b.dockerfile, err = parser.Parse(io.TeeReader(f, digester.Hash()), &b.directive)
 	if err != nil {
 		return err
 	}
+	b.dockerfileDigest = digester.Digest().String()
 
 	return nil
 }
@@ -2,11 +2,15 @@ package dockerfile
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/dockerfile/parser"
 	"github.com/docker/docker/pkg/archive"
 )
 
@@ -93,3 +97,49 @@ func readAndCheckDockerfile(t *testing.T, testName, contextDir, dockerfilePath,
 		t.Fatalf("Wrong error message. Should be \"%s\". Got \"%s\"", expectedError, err.Error())
 	}
 }
+
+func TestWriteHeredoc(t *testing.T) {
+	heredocs := []parser.Heredoc{{Name: "index.html", Content: "<h1>hello</h1>\n"}}
+	h, ok := heredocSource("<<-index.html", heredocs)
+	if !ok {
+		t.Fatal("expected <<-index.html to refer to the here-document")
+	}
+	if _, ok := heredocSource("<<other", heredocs); ok {
+		t.Fatal("expected <<other not to refer to a here-document")
+	}
+
+	fi, err := writeHeredoc(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(fi.Path()))
+	if fi.Name() != "index.html" {
+		t.Fatalf("expected the file to be named index.html, got %s", fi.Name())
+	}
+	content, err := ioutil.ReadFile(fi.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != h.Content {
+		t.Fatalf("expected %q, got %q", h.Content, content)
+	}
+
+	// the hash only depends on the name and the content
+	other, err := writeHeredoc(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(other.Path()))
+	if fi.(builder.Hashed).Hash() != other.(builder.Hashed).Hash() {
+		t.Fatal("expected the same here-document to have the same hash")
+	}
+	h.Content = "<h1>bye</h1>\n"
+	changed, err := writeHeredoc(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(changed.Path()))
+	if fi.(builder.Hashed).Hash() == changed.(builder.Hashed).Hash() {
+		t.Fatal("expected a different content to change the hash")
+	}
+}
@@ -0,0 +1,543 @@
+package dockerfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/dockerfile/command"
+	"github.com/docker/docker/builder/dockerfile/parser"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/urlutil"
+	"golang.org/x/net/context"
+)
+
+// buildStage is a FROM instruction and the instructions following it, up to
+// the next FROM. The instructions preceding the first FROM belong to the
+// first stage.
+type buildStage struct {
+	first   int // index of the first instruction of the stage
+	nodes   []*parser.Node
+	name    string
+	hasFrom bool
+	// stages which must be built before this one
+	deps []int
+	// images the stage starts from or copies files from, which are not
+	// stages of the build
+	images []string
+	// whether the stage has ARG or GIT instructions, whose effects the
+	// following stages inherit
+	carrier bool
+	// the last preceding stage with ARG or GIT instructions, -1 if none
+	inherit int
+}
+
+// nodeArgs returns the arguments of an instruction, as written in the
+// Dockerfile.
+func nodeArgs(n *parser.Node) []string {
+	var args []string
+	for arg := n.Next; arg != nil; arg = arg.Next {
+		args = append(args, arg.Value)
+	}
+	return args
+}
+
+// copyFrom returns the value of the --from flag of a COPY instruction.
+func copyFrom(n *parser.Node) string {
+	var from string
+	for _, flag := range n.Flags {
+		if strings.HasPrefix(flag, "--from=") {
+			from = strings.TrimPrefix(flag, "--from=")
+		}
+	}
+	return from
+}
+
+// splitStages splits the instructions of a Dockerfile into build stages, and
+// finds the stages each stage depends on: the stage it starts from, the
+// stages it copies files from, and the last preceding stage with ARG or GIT
+// instructions, so that the stages see the build args and git sources they
+// would see if the stages were built one after the other.
+func splitStages(nodes []*parser.Node) ([]*buildStage, error) {
+	var stages []*buildStage
+	byName := make(map[string]int)
+	lastCarrier := -1
+
+	for i, n := range nodes {
+		if len(stages) == 0 || (n.Value == command.From && stages[len(stages)-1].hasFrom) {
+			if len(stages) > 0 && stages[len(stages)-1].carrier {
+				lastCarrier = len(stages) - 1
+			}
+			s := &buildStage{first: i, inherit: lastCarrier}
+			if lastCarrier >= 0 {
+				s.deps = append(s.deps, lastCarrier)
+			}
+			stages = append(stages, s)
+		}
+		index := len(stages) - 1
+		s := stages[index]
+		s.nodes = append(s.nodes, n)
+
+		switch n.Value {
+		case command.From:
+			args := nodeArgs(n)
+			name, err := parseBuildStageName(args)
+			if err != nil {
+				return nil, err
+			}
+			s.hasFrom, s.name = true, name
+			if dep, ok := byName[strings.ToLower(args[0])]; ok {
+				s.deps = append(s.deps, dep)
+			} else if args[0] != api.NoBaseImageSpecifier {
+				s.images = append(s.images, args[0])
+			}
+			if name != "" {
+				if _, ok := byName[name]; !ok {
+					byName[name] = index
+				}
+			}
+		case command.Copy:
+			from := copyFrom(n)
+			if from == "" {
+				break
+			}
+			if dep, ok := byName[strings.ToLower(from)]; ok && dep != index {
+				s.deps = append(s.deps, dep)
+			} else if dep, err := strconv.Atoi(from); err == nil {
+				if dep >= 0 && dep < index {
+					s.deps = append(s.deps, dep)
+				}
+			} else if !ok {
+				s.images = append(s.images, from)
+			}
+		case command.Arg, command.Git:
+			s.carrier = true
+		}
+	}
+	return stages, nil
+}
+
+// parallelBuild is the state shared by the stages of a build running
+// concurrently.
+type parallelBuild struct {
+	slots  chan struct{} // held by the running stages
+	out    *orderedOutput
+	images map[string]*imageFetch
+	// hashes of the sources of ADD and COPY instructions in the build
+	// context, computed while the stages start
+	sources map[copySource]*sourceFetch
+}
+
+// buildParallelism returns how many stages of a build may run at once.
+func (b *Builder) buildParallelism() int {
+	if b.options.Parallelism > 0 {
+		return b.options.Parallelism
+	}
+	return runtime.NumCPU()
+}
+
+// buildStages builds the stages of the Dockerfile, running the stages which
+// do not depend on each other concurrently. Each stage runs on its own copy
+// of b. The output of the stages is written in the order of the Dockerfile.
+// It returns the builder of the last stage.
+func (b *Builder) buildStages() (*Builder, error) {
+	stages, err := splitStages(b.dockerfile.Children)
+	if err != nil {
+		return nil, err
+	}
+	if len(stages) == 0 {
+		return b, nil
+	}
+	mounts := make([]*imageMount, len(stages))
+	for i, s := range stages {
+		if mounts[i], err = b.imageContexts.add(s.name); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(b.clientCtx)
+	defer cancel()
+
+	p := &parallelBuild{
+		slots: make(chan struct{}, b.buildParallelism()),
+		out:   newOrderedOutput(len(stages)),
+	}
+	b.stages = p
+	p.images = b.prefetchImages(ctx, stages)
+	p.sources = b.prefetchSources(stages)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		builders = make([]*Builder, len(stages))
+	)
+	for i := range stages {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := b.buildStage(ctx, stages, i, mounts, builders)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// the build args used by any stage were consumed
+	last := builders[len(builders)-1]
+	for _, sb := range builders {
+		for arg := range sb.allowedBuildArgs {
+			last.allowedBuildArgs[arg] = true
+		}
+	}
+	return last, nil
+}
+
+// buildStage waits for the stages stage i depends on and builds it. The
+// builder of the stage is stored in builders.
+func (b *Builder) buildStage(ctx context.Context, stages []*buildStage, i int, mounts []*imageMount, builders []*Builder) error {
+	p := b.stages
+	s := stages[i]
+	defer p.out.done(i)
+	defer close(mounts[i].done)
+
+	for _, dep := range s.deps {
+		select {
+		case <-mounts[dep].done:
+		case <-ctx.Done():
+			return errCancelled
+		}
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return errCancelled
+	}
+	defer func() { <-p.slots }()
+
+	// a stage starts from the state of the last stage with ARG or GIT
+	// instructions before it, which is one of its dependencies
+	parent := b
+	if s.inherit >= 0 {
+		if parent = builders[s.inherit]; parent == nil {
+			// the stage was cancelled before it started
+			return errCancelled
+		}
+	}
+	sb := b.stageBuilder(ctx, parent, mounts[i])
+	builders[i] = sb
+
+	total := len(b.dockerfile.Children)
+	for j, n := range s.nodes {
+		select {
+		case <-ctx.Done():
+			if b.clientCtx.Err() == nil {
+				// another stage failed
+				return errCancelled
+			}
+			logrus.Debug("Builder: build cancelled!")
+			fmt.Fprint(sb.Stdout, "Build cancelled")
+			return errors.New("Build cancelled")
+		default:
+			// Not cancelled yet, keep going...
+		}
+
+		if err := sb.dispatch(s.first+j, total, n); err != nil {
+			sb.stepFailed(err)
+			if sb.options.ForceRemove {
+				sb.clearTmp()
+			}
+			return err
+		}
+		sb.stage.id = sb.image
+
+		sb.stepDone()
+		if sb.options.Remove {
+			sb.clearTmp()
+		}
+	}
+	return nil
+}
+
+// stageBuilder returns a builder for a stage, starting from the state of
+// parent: the build args and git sources it inherits from the preceding
+// stages.
+func (b *Builder) stageBuilder(ctx context.Context, parent *Builder, stage *imageMount) *Builder {
+	sb := *b
+	sb.clientCtx = ctx
+	sb.stage = stage
+	sb.Stdout = b.stages.out.writer(stage.index, b.Stdout)
+	sb.Stderr = b.stages.out.writer(stage.index, b.Stderr)
+	sb.Output = b.stages.out.writer(stage.index, b.Output)
+	sb.useEventWriters()
+	sb.tmpContainers = make(map[string]struct{})
+
+	options := *b.options
+	options.BuildArgs = make(map[string]*string)
+	for k, v := range parent.options.BuildArgs {
+		options.BuildArgs[k] = v
+	}
+	sb.options = &options
+	sb.allowedBuildArgs = make(map[string]bool)
+	for k, v := range parent.allowedBuildArgs {
+		sb.allowedBuildArgs[k] = v
+	}
+	sb.gitSources = append([]image.GitSource(nil), parent.gitSources...)
+	return &sb
+}
+
+// waitStage waits until the stage im is built. The stage waiting gives its
+// slot up in the meantime, so that the stage it waits for can run.
+func (b *Builder) waitStage(im *imageMount) error {
+	if b.stages == nil {
+		// the stages run one after the other
+		return nil
+	}
+	select {
+	case <-im.done:
+		return nil
+	default:
+	}
+
+	<-b.stages.slots
+	select {
+	case <-im.done:
+	case <-b.clientCtx.Done():
+		b.stages.slots <- struct{}{}
+		return errCancelled
+	}
+	b.stages.slots <- struct{}{}
+	return nil
+}
+
+// imageFetch is an image looked up, and pulled if needed, before the stages
+// using it run. The output of the pull is held until the first stage using
+// the image writes it.
+type imageFetch struct {
+	done  chan struct{}
+	owner int // index of the first stage using the image
+	out   *heldOutput
+	image builder.Image
+	err   error
+}
+
+// prefetchImages looks up the images used by the stages concurrently.
+func (b *Builder) prefetchImages(ctx context.Context, stages []*buildStage) map[string]*imageFetch {
+	fetches := make(map[string]*imageFetch)
+	for i, s := range stages {
+		for _, name := range s.images {
+			if _, ok := fetches[name]; ok {
+				continue
+			}
+			f := &imageFetch{done: make(chan struct{}), owner: i, out: &heldOutput{}}
+			fetches[name] = f
+
+			fb := *b
+			fb.clientCtx = ctx
+			fb.Output = f.out
+			go func(name string) {
+				defer close(f.done)
+				f.image, f.err = fb.resolveImage(name)
+			}(name)
+		}
+	}
+	return fetches
+}
+
+// wait waits for the image to be fetched, and writes the output of its pull
+// if b builds the first stage using it.
+func (f *imageFetch) wait(b *Builder) (builder.Image, error) {
+	select {
+	case <-f.done:
+	case <-b.clientCtx.Done():
+		return nil, errCancelled
+	}
+	if b.stage != nil && b.stage.index == f.owner {
+		f.out.replay(b.Output)
+	}
+	return f.image, f.err
+}
+
+// copySource is a source of an ADD or COPY instruction in the build context.
+type copySource struct {
+	path       string
+	decompress bool
+}
+
+// sourceFetch is the hash of a copySource, computed before the instruction
+// copying it runs.
+type sourceFetch struct {
+	done  chan struct{}
+	infos []copyInfo
+	err   error
+}
+
+// literalSource reports whether a source of an ADD or COPY instruction is
+// used as written, without variable substitution.
+func (b *Builder) literalSource(src string) bool {
+	return !strings.ContainsAny(src, "$'\"") && !strings.ContainsRune(src, b.directive.EscapeToken)
+}
+
+// prefetchSources hashes the sources of the ADD and COPY instructions in the
+// build context concurrently. Only the sources without variables are
+// hashed, as the others depend on the environment of the instruction.
+func (b *Builder) prefetchSources(stages []*buildStage) map[copySource]*sourceFetch {
+	fetches := make(map[copySource]*sourceFetch)
+	if b.context == nil {
+		return fetches
+	}
+	slots := make(chan struct{}, b.buildParallelism())
+	for _, s := range stages {
+		for _, n := range s.nodes {
+			if (n.Value != command.Add && n.Value != command.Copy) || copyFrom(n) != "" {
+				continue
+			}
+			args := nodeArgs(n)
+			if len(args) < 2 {
+				continue
+			}
+			for _, src := range args[:len(args)-1] {
+				if _, ok := heredocSource(src, n.Heredocs); ok {
+					continue
+				}
+				if urlutil.IsURL(src) || !b.literalSource(src) {
+					continue
+				}
+				key := copySource{path: src, decompress: n.Value == command.Add}
+				if _, ok := fetches[key]; ok {
+					continue
+				}
+				f := &sourceFetch{done: make(chan struct{})}
+				fetches[key] = f
+				go func(key copySource, cmdName string) {
+					defer close(f.done)
+					slots <- struct{}{}
+					defer func() { <-slots }()
+					f.infos, f.err = b.calcCopyInfo(b.context, cmdName, key.path, key.decompress, true)
+				}(key, strings.ToUpper(n.Value))
+			}
+		}
+	}
+	return fetches
+}
+
+// orderedOutput writes the output of the stages of a build in the order of
+// the stages, whichever order they run in. The output of the first stage
+// which is not done is written as it comes, the output of the following
+// stages is held until the stages before them are done.
+type orderedOutput struct {
+	mu      sync.Mutex
+	current int
+	isDone  []bool
+	held    []*heldOutput
+}
+
+func newOrderedOutput(stages int) *orderedOutput {
+	o := &orderedOutput{
+		isDone: make([]bool, stages),
+		held:   make([]*heldOutput, stages),
+	}
+	for i := range o.held {
+		o.held[i] = &heldOutput{}
+	}
+	return o
+}
+
+// writer returns a writer to w for the output of a stage.
+func (o *orderedOutput) writer(stage int, w io.Writer) io.Writer {
+	return &stageWriter{o: o, stage: stage, w: w}
+}
+
+// done marks a stage as done, and writes the output held for the following
+// stages up to the next one which is not done.
+func (o *orderedOutput) done(stage int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.isDone[stage] = true
+	for o.current < len(o.isDone) && o.isDone[o.current] {
+		o.current++
+		if o.current < len(o.held) {
+			o.held[o.current].replay(nil)
+		}
+	}
+}
+
+type stageWriter struct {
+	o     *orderedOutput
+	stage int
+	w     io.Writer
+}
+
+func (sw *stageWriter) Write(p []byte) (int, error) {
+	sw.o.mu.Lock()
+	defer sw.o.mu.Unlock()
+
+	if sw.stage == sw.o.current {
+		return sw.w.Write(p)
+	}
+	sw.o.held[sw.stage].add(sw.w, p)
+	return len(p), nil
+}
+
+// heldOutput holds writes to replay later.
+type heldOutput struct {
+	mu     sync.Mutex
+	writes []heldWrite
+}
+
+type heldWrite struct {
+	w io.Writer
+	p []byte
+}
+
+func (h *heldOutput) add(w io.Writer, p []byte) {
+	h.mu.Lock()
+	h.writes = append(h.writes, heldWrite{w: w, p: append([]byte(nil), p...)})
+	h.mu.Unlock()
+}
+
+// Write holds p, to be replayed to the writer given to replay.
+func (h *heldOutput) Write(p []byte) (int, error) {
+	h.add(nil, p)
+	return len(p), nil
+}
+
+// replay writes the held output, to w for the writes which had no writer,
+// and forgets it.
+func (h *heldOutput) replay(w io.Writer) {
+	h.mu.Lock()
+	writes := h.writes
+	h.writes = nil
+	h.mu.Unlock()
+
+	for _, hw := range writes {
+		dst := hw.w
+		if dst == nil {
+			dst = w
+		}
+		if dst == nil {
+			continue
+		}
+		if _, err := dst.Write(hw.p); err != nil {
+			logrus.Debugf("[BUILDER] failed to write the output of a stage: %v", err)
+		}
+	}
+}
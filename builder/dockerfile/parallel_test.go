@@ -0,0 +1,109 @@
+package dockerfile
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/builder/dockerfile/parser"
+)
+
+func TestSplitStages(t *testing.T) {
+	dockerfile := `ARG VERSION=1
+FROM golang:1.7 AS build
+COPY . /src
+RUN make
+FROM alpine AS docs
+ARG DOCS
+RUN make docs
+FROM build AS test
+RUN make test
+FROM busybox
+COPY --from=build /src/app /app
+COPY --from=1 /docs /docs
+COPY --from=nginx /etc/nginx /etc/nginx
+`
+	d := parser.Directive{}
+	parser.SetEscapeToken(parser.DefaultEscapeToken, &d)
+	n, err := parser.Parse(strings.NewReader(dockerfile), &d)
+	if err != nil {
+		t.Fatalf("Error when parsing Dockerfile: %s", err)
+	}
+
+	stages, err := splitStages(n.Children)
+	if err != nil {
+		t.Fatalf("Error when splitting stages: %s", err)
+	}
+	expected := []struct {
+		first   int
+		nodes   int
+		name    string
+		deps    []int
+		images  []string
+		inherit int
+	}{
+		{0, 4, "build", nil, []string{"golang:1.7"}, -1},
+		{4, 3, "docs", []int{0}, []string{"alpine"}, 0},
+		{7, 2, "test", []int{1, 0}, nil, 1},
+		{9, 4, "", []int{1, 0, 1}, []string{"busybox", "nginx"}, 1},
+	}
+	if len(stages) != len(expected) {
+		t.Fatalf("Expected %d stages, got %d", len(expected), len(stages))
+	}
+	for i, e := range expected {
+		s := stages[i]
+		if s.first != e.first || len(s.nodes) != e.nodes || s.name != e.name || s.inherit != e.inherit {
+			t.Fatalf("Unexpected stage %d: %+v", i, s)
+		}
+		if !reflect.DeepEqual(s.deps, e.deps) {
+			t.Fatalf("Stage %d should depend on %v, got %v", i, e.deps, s.deps)
+		}
+		if !reflect.DeepEqual(s.images, e.images) {
+			t.Fatalf("Stage %d should use images %v, got %v", i, e.images, s.images)
+		}
+	}
+}
+
+func TestOrderedOutput(t *testing.T) {
+	out := &bytes.Buffer{}
+	o := newOrderedOutput(3)
+	w0, w1, w2 := o.writer(0, out), o.writer(1, out), o.writer(2, out)
+
+	w2.Write([]byte("2a "))
+	w1.Write([]byte("1a "))
+	w0.Write([]byte("0a "))
+	if out.String() != "0a " {
+		t.Fatalf("Only the output of the first stage should be written, got %q", out.String())
+	}
+
+	o.done(2)
+	w1.Write([]byte("1b "))
+	if out.String() != "0a " {
+		t.Fatalf("The output of the following stages should be held, got %q", out.String())
+	}
+
+	o.done(0)
+	w1.Write([]byte("1c "))
+	o.done(1)
+	if expected := "0a 1a 1b 1c 2a "; out.String() != expected {
+		t.Fatalf("Expected output %q, got %q", expected, out.String())
+	}
+}
+
+func TestHeldOutput(t *testing.T) {
+	h := &heldOutput{}
+	h.Write([]byte("pulling "))
+	h.Write([]byte("done"))
+
+	out := &bytes.Buffer{}
+	h.replay(out)
+	if out.String() != "pulling done" {
+		t.Fatalf("Expected the held output to be replayed, got %q", out.String())
+	}
+
+	h.replay(out)
+	if out.String() != "pulling done" {
+		t.Fatalf("The held output should be replayed once, got %q", out.String())
+	}
+}
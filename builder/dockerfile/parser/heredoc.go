@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/builder/dockerfile/command"
+)
+
+// Heredoc is a here-document following an instruction, such as the script
+// of `RUN <<EOF`. Its lines are taken as they are: they are neither joined
+// on the escape token nor stripped of comments.
+type Heredoc struct {
+	Name    string // the delimiter, without its quotes
+	Content string // the lines up to the delimiter, each ending with a newline
+	Expand  bool   // whether variables are expanded in the content, true when the delimiter is not quoted
+	Chomp   bool   // whether the leading tabs of the lines were stripped (<<-)
+}
+
+var (
+	// tokenHeredoc matches a here-document at the start of a word of the
+	// arguments of RUN, such as `<<EOF` in `cat <<EOF>out`. Here-strings
+	// (<<<) are not taken for one.
+	tokenHeredoc = regexp.MustCompile(`^<<(-?)(["']?)([\w][\w.-]*)(["']?)`)
+	// tokenHeredocWord matches a source of ADD and COPY which is a
+	// here-document.
+	tokenHeredocWord = regexp.MustCompile(`^<<(-?)(["']?)([\w][\w.-]*)(["']?)$`)
+)
+
+// heredocCommands are the instructions which can be followed by
+// here-documents.
+var heredocCommands = map[string]bool{
+	command.Add:  true,
+	command.Copy: true,
+	command.Run:  true,
+}
+
+// newHeredoc returns the here-document of a marker matched by tokenHeredoc
+// or tokenHeredocWord, false if its quotes do not match.
+func newHeredoc(match []string) (Heredoc, bool) {
+	chomp, open, name, closing := match[1], match[2], match[3], match[4]
+	if open != closing {
+		return Heredoc{}, false
+	}
+	return Heredoc{Name: name, Expand: open == "", Chomp: chomp == "-"}, true
+}
+
+// ParseHeredocMarker returns the here-document a word refers to, such as
+// the source `<<EOF` of a COPY instruction, false if it is not a
+// here-document marker. The content of the here-document is not set.
+func ParseHeredocMarker(word string) (Heredoc, bool) {
+	match := tokenHeredocWord.FindStringSubmatch(word)
+	if match == nil {
+		return Heredoc{}, false
+	}
+	return newHeredoc(match)
+}
+
+// heredocMarkers returns the here-documents the arguments of an instruction
+// refer to, in order, without their content. Instructions in the JSON form
+// cannot have here-documents. The arguments of RUN are split into words
+// like a shell does, so that markers in quotes, like in
+// `RUN echo "a <<EOF b"`, are not taken for here-documents.
+func heredocMarkers(node *Node, d *Directive) []Heredoc {
+	if !heredocCommands[node.Value] || node.Attributes["json"] {
+		return nil
+	}
+	var heredocs []Heredoc
+	for n := node.Next; n != nil; n = n.Next {
+		if node.Value == command.Run {
+			for _, word := range parseWords(n.Value, d) {
+				match := tokenHeredoc.FindStringSubmatch(word)
+				if match == nil {
+					continue
+				}
+				if h, ok := newHeredoc(match); ok {
+					heredocs = append(heredocs, h)
+				}
+			}
+			continue
+		}
+		if h, ok := ParseHeredocMarker(n.Value); ok {
+			heredocs = append(heredocs, h)
+		}
+	}
+	return heredocs
+}
+
+// readHeredocs reads the content of the here-documents of node from the
+// lines following it, and returns the number of lines read. startLine is the
+// line the instruction begins on, for the error messages.
+func readHeredocs(scanner *bufio.Scanner, node *Node, d *Directive, startLine int) (int, error) {
+	heredocs := heredocMarkers(node, d)
+	read := 0
+	for i, h := range heredocs {
+		terminated := false
+		var content []string
+		for scanner.Scan() {
+			read++
+			text := scanner.Text()
+			if h.Chomp {
+				text = strings.TrimLeft(text, "\t")
+			}
+			if text == h.Name {
+				terminated = true
+				break
+			}
+			content = append(content, text+"\n")
+		}
+		if !terminated {
+			return read, fmt.Errorf("unterminated heredoc %s of the instruction on line %d", h.Name, startLine)
+		}
+		heredocs[i].Content = strings.Join(content, "")
+	}
+	node.Heredocs = heredocs
+	return read, nil
+}
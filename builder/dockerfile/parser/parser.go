@@ -34,6 +34,7 @@ type Node struct {
 	Flags      []string        // only top Node should have this set
 	StartLine  int             // the line in the original dockerfile where the node begins
 	EndLine    int             // the line in the original dockerfile where the node ends
+	Heredocs   []Heredoc       // the here-documents following the instruction, only top Node should have this set
 }
 
 // Directive is the structure used during a build run to hold the state of
@@ -80,7 +81,7 @@ func init() {
 		command.Entrypoint:  parseMaybeJSON,
 		command.Env:         parseEnv,
 		command.Expose:      parseStringsWhitespaceDelimited,
-		command.From:        parseString,
+		command.From:        parseStringsWhitespaceDelimited,
 		command.Healthcheck: parseHealthConfig,
 		command.Label:       parseLabel,
 		command.Maintainer:  parseString,
@@ -92,7 +93,7 @@ func init() {
 		command.Volume:      parseMaybeJSONToList,
 		command.Workdir:     parseString,
 		command.Tapcon:      parseString,
-		command.Git:         parseString,
+		command.Git:         parseMaybeJSONToList,
 	}
 }
 
@@ -207,6 +208,14 @@ func Parse(rwc io.Reader, d *Directive) (*Node, error) {
 		}
 
 		if child != nil {
+			// Read the here-documents following the instruction, which
+			// are part of it.
+			n, err := readHeredocs(scanner, child, d, startLine)
+			if err != nil {
+				return nil, err
+			}
+			currentLine += n
+
 			// Update the line information for the current child.
 			child.StartLine = startLine
 			child.EndLine = currentLine
@@ -6,7 +6,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -171,3 +173,75 @@ func TestLineInformation(t *testing.T) {
 		}
 	}
 }
+
+func TestParseHeredoc(t *testing.T) {
+	dockerfile := "# escape=`\n" +
+		"FROM windowsservercore\n" +
+		"RUN <<EOF `\n" +
+		"  && echo done\n" +
+		"echo a `\n" +
+		"# b\n" +
+		"EOF\n" +
+		"COPY <<-'EOF' C:\\out.txt\n" +
+		"\t$NAME\n" +
+		"\tEOF\n" +
+		"RUN echo end\n"
+
+	d := Directive{LookingForDirectives: true}
+	SetEscapeToken(DefaultEscapeToken, &d)
+	ast, err := Parse(strings.NewReader(dockerfile), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ast.Children) != 4 {
+		t.Fatalf("expected 4 instructions, got %d", len(ast.Children))
+	}
+
+	run := ast.Children[1]
+	if run.Next.Value != "<<EOF   && echo done" {
+		t.Fatalf("unexpected RUN arguments: %q", run.Next.Value)
+	}
+	expected := []Heredoc{{Name: "EOF", Content: "echo a `\n# b\n", Expand: true}}
+	if !reflect.DeepEqual(run.Heredocs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, run.Heredocs)
+	}
+
+	copy := ast.Children[2]
+	expected = []Heredoc{{Name: "EOF", Content: "$NAME\n", Chomp: true}}
+	if !reflect.DeepEqual(copy.Heredocs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, copy.Heredocs)
+	}
+
+	lines := [][]int{{2, 2}, {3, 7}, {8, 10}, {11, 11}}
+	for i, child := range ast.Children {
+		if child.StartLine != lines[i][0] || child.EndLine != lines[i][1] {
+			t.Fatalf("expected lines %d-%d for child %d, got %d-%d", lines[i][0], lines[i][1], i, child.StartLine, child.EndLine)
+		}
+	}
+}
+
+func TestParseHeredocInQuotes(t *testing.T) {
+	dockerfile := "FROM busybox\n" +
+		"RUN echo \"a <<EOF b\" 'c <<EOF' d\\<<EOF\n" +
+		"RUN cat <<'EOF'>out && echo \"<<END\"\n" +
+		"x\n" +
+		"EOF\n" +
+		"RUN echo end\n"
+
+	d := Directive{LookingForDirectives: true}
+	SetEscapeToken(DefaultEscapeToken, &d)
+	ast, err := Parse(strings.NewReader(dockerfile), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ast.Children) != 4 {
+		t.Fatalf("expected 4 instructions, got %d", len(ast.Children))
+	}
+	if heredocs := ast.Children[1].Heredocs; len(heredocs) != 0 {
+		t.Fatalf("expected no here-documents in quotes, got %+v", heredocs)
+	}
+	expected := []Heredoc{{Name: "EOF", Content: "x\n"}}
+	if heredocs := ast.Children[2].Heredocs; !reflect.DeepEqual(heredocs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, heredocs)
+	}
+}
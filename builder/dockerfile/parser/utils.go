@@ -31,6 +31,10 @@ func (node *Node) Dump() string {
 		}
 	}
 
+	for _, h := range node.Heredocs {
+		str += fmt.Sprintf(" (heredoc %q %q)", h.Name, h.Content)
+	}
+
 	return strings.TrimSpace(str)
 }
 
@@ -0,0 +1,158 @@
+package dockerfile
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/builder/remotecache"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// The progress of a build is written as text to its output, unless the
+// client asked for the json progress format. The progress is then sent as
+// types.BuildEvent messages, and the text the builder and the RUN steps
+// write is sent as message and log events of the step running.
+
+// eventFormatter formats the events, which are always sent over the JSON
+// stream of the build API.
+var eventFormatter = streamformatter.NewJSONStreamFormatter()
+
+// stepProgress is the step of a stage being dispatched.
+type stepProgress struct {
+	number int // from 1, 0 before the first step
+	line   int // line of the instruction in the Dockerfile
+	start  time.Time
+	parent string // image the step started from
+}
+
+// jsonProgress reports whether the progress of the build is sent as events.
+func (b *Builder) jsonProgress() bool {
+	return b.options.Progress == types.BuildProgressJSON
+}
+
+// emit sends an event to the client.
+func (b *Builder) emit(ev types.BuildEvent) {
+	ev.TimeNano = time.Now().UnixNano()
+	if _, err := b.Output.Write(eventFormatter.FormatAux(ev)); err != nil {
+		logrus.Debugf("[BUILDER] failed to send build event: %v", err)
+	}
+}
+
+// eventWriter sends what is written to it as events of the step running.
+type eventWriter struct {
+	b      *Builder
+	typ    string
+	stream string
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	w.b.emit(types.BuildEvent{Type: w.typ, Step: w.b.step.number, Stream: w.stream, Data: string(p)})
+	return len(p), nil
+}
+
+// useEventWriters makes the text written to b.Stdout and b.Stderr events,
+// if the progress of the build is sent as events.
+func (b *Builder) useEventWriters() {
+	if b.jsonProgress() {
+		b.Stdout = &eventWriter{b: b, typ: types.BuildEventMessage, stream: "stdout"}
+		b.Stderr = &eventWriter{b: b, typ: types.BuildEventMessage, stream: "stderr"}
+	}
+}
+
+// runOutput returns the writers the output of the command of a RUN step is
+// copied to.
+func (b *Builder) runOutput() (stdout, stderr io.Writer) {
+	if !b.jsonProgress() {
+		return b.Stdout, b.Stderr
+	}
+	return &eventWriter{b: b, typ: types.BuildEventLog, stream: "stdout"},
+		&eventWriter{b: b, typ: types.BuildEventLog, stream: "stderr"}
+}
+
+// stepStarted reports that the step number of total starts, running the
+// instruction on line of the Dockerfile.
+func (b *Builder) stepStarted(number, total, line int, instruction string) {
+	b.step = stepProgress{number: number, line: line, start: time.Now(), parent: b.image}
+	if !b.jsonProgress() {
+		fmt.Fprintf(b.Stdout, "Step %d/%d : %s\n", number, total, instruction)
+		return
+	}
+	b.emit(types.BuildEvent{
+		Type:        types.BuildEventStepStart,
+		Step:        number,
+		Steps:       total,
+		Instruction: instruction,
+		Line:        line,
+	})
+}
+
+// cacheProbed reports whether the step starting from the image parent was
+// found in the build cache.
+func (b *Builder) cacheProbed(parent string, hit bool) error {
+	if !b.jsonProgress() {
+		if hit {
+			fmt.Fprint(b.Stdout, " ---> Using cache\n")
+		}
+		return nil
+	}
+	key, err := remotecache.Key(parent, b.runConfig)
+	if err != nil {
+		return err
+	}
+	b.emit(types.BuildEvent{
+		Type:     types.BuildEventCache,
+		Step:     b.step.number,
+		Cached:   hit,
+		CacheKey: key.String(),
+	})
+	return nil
+}
+
+// stepDone reports that the step is done, and produced b.image.
+func (b *Builder) stepDone() {
+	if !b.jsonProgress() {
+		fmt.Fprintf(b.Stdout, " ---> %s\n", stringid.TruncateID(b.image))
+		return
+	}
+	var size int64
+	if b.image != b.step.parent {
+		var err error
+		if size, err = b.docker.LayerSizeOnBuild(b.image); err != nil {
+			logrus.Debugf("[BUILDER] failed to get the size of the layer of %s: %v", b.image, err)
+		}
+	}
+	b.emit(types.BuildEvent{
+		Type:     types.BuildEventStepDone,
+		Step:     b.step.number,
+		ImageID:  b.image,
+		Size:     size,
+		Duration: time.Since(b.step.start),
+	})
+}
+
+// stepFailed reports that the step failed with err. The error itself is
+// returned by the build.
+func (b *Builder) stepFailed(err error) {
+	if !b.jsonProgress() || err == errCancelled {
+		return
+	}
+	b.emit(types.BuildEvent{
+		Type:  types.BuildEventError,
+		Step:  b.step.number,
+		Line:  b.step.line,
+		Error: err.Error(),
+	})
+}
+
+// buildDone reports that the build produced the image b.image.
+func (b *Builder) buildDone() {
+	if !b.jsonProgress() {
+		fmt.Fprintf(b.Stdout, "Successfully built %s\n", stringid.TruncateID(b.image))
+		return
+	}
+	b.emit(types.BuildEvent{Type: types.BuildEventDone, ImageID: b.image})
+}
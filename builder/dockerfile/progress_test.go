@@ -0,0 +1,127 @@
+package dockerfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/builder"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+type sizeBackend struct {
+	builder.Backend
+}
+
+func (b *sizeBackend) LayerSizeOnBuild(imageID string) (int64, error) {
+	return 1024, nil
+}
+
+// readEvents decodes the events sent to out.
+func readEvents(t *testing.T, out *bytes.Buffer) []types.BuildEvent {
+	var events []types.BuildEvent
+	dec := json.NewDecoder(out)
+	for {
+		var jm jsonmessage.JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				return events
+			}
+			t.Fatal(err)
+		}
+		if jm.Aux == nil {
+			t.Fatalf("Expected only events, got %+v", jm)
+		}
+		var ev types.BuildEvent
+		if err := json.Unmarshal(*jm.Aux, &ev); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+}
+
+func TestJSONProgress(t *testing.T) {
+	out := &bytes.Buffer{}
+	b := &Builder{
+		options:   &types.ImageBuildOptions{Progress: types.BuildProgressJSON},
+		docker:    &sizeBackend{},
+		runConfig: &container.Config{Cmd: []string{"/bin/sh", "-c", "make"}},
+		image:     "sha256:parent",
+		Output:    out,
+	}
+	b.useEventWriters()
+
+	b.stepStarted(2, 3, 5, "RUN make")
+	if err := b.cacheProbed(b.image, false); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := b.runOutput()
+	fmt.Fprint(stdout, "building\n")
+	fmt.Fprint(stderr, "warning\n")
+	fmt.Fprint(b.Stdout, "Removing intermediate container\n")
+	b.image = "sha256:child"
+	b.stepDone()
+	b.stepFailed(fmt.Errorf("failed"))
+	b.buildDone()
+
+	events := readEvents(t, out)
+	expected := []types.BuildEvent{
+		{Type: types.BuildEventStepStart, Step: 2, Steps: 3, Instruction: "RUN make", Line: 5},
+		{Type: types.BuildEventCache, Step: 2},
+		{Type: types.BuildEventLog, Step: 2, Stream: "stdout", Data: "building\n"},
+		{Type: types.BuildEventLog, Step: 2, Stream: "stderr", Data: "warning\n"},
+		{Type: types.BuildEventMessage, Step: 2, Stream: "stdout", Data: "Removing intermediate container\n"},
+		{Type: types.BuildEventStepDone, Step: 2, ImageID: "sha256:child", Size: 1024},
+		{Type: types.BuildEventError, Step: 2, Line: 5, Error: "failed"},
+		{Type: types.BuildEventDone, ImageID: "sha256:child"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %+v", len(expected), events)
+	}
+	for i, ev := range events {
+		if ev.TimeNano == 0 {
+			t.Fatalf("Expected event %d to have a time", i)
+		}
+		ev.TimeNano = 0
+		if ev.Type == types.BuildEventCache {
+			if ev.CacheKey == "" {
+				t.Fatal("Expected the cache event to have a key")
+			}
+			ev.CacheKey = ""
+		}
+		if ev.Type == types.BuildEventStepDone {
+			ev.Duration = 0
+		}
+		if ev != expected[i] {
+			t.Fatalf("Expected event %d to be %+v, got %+v", i, expected[i], ev)
+		}
+	}
+}
+
+func TestTextProgress(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	b := &Builder{
+		options:   &types.ImageBuildOptions{},
+		runConfig: &container.Config{},
+		image:     "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		Stdout:    stdout,
+	}
+	b.useEventWriters()
+
+	b.stepStarted(1, 2, 1, "FROM busybox")
+	if err := b.cacheProbed(b.image, true); err != nil {
+		t.Fatal(err)
+	}
+	b.stepDone()
+	b.stepFailed(fmt.Errorf("failed"))
+	b.buildDone()
+
+	expected := "Step 1/2 : FROM busybox\n ---> Using cache\n ---> 0123456789ab\nSuccessfully built 0123456789ab\n"
+	if stdout.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, stdout.String())
+	}
+}
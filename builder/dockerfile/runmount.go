@@ -0,0 +1,234 @@
+package dockerfile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/backend"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/pkg/system"
+)
+
+// Types of the mounts of RUN instructions.
+const (
+	mountTypeSecret = "secret"
+	mountTypeCache  = "cache"
+)
+
+// runMountFields are the fields each type of mount accepts, besides type.
+var runMountFields = map[string][]string{
+	mountTypeSecret: {"id", "target", "required", "uid", "gid", "mode"},
+	mountTypeCache:  {"id", "target"},
+}
+
+// runMount is a mount of a RUN instruction, given with --mount.
+type runMount struct {
+	Type     string
+	ID       string
+	Target   string
+	Required bool
+	UID      string
+	GID      string
+	Mode     os.FileMode
+}
+
+// parseRunMount parses the value of a --mount flag of RUN, which is a
+// comma-separated list of key=value pairs, such as
+// type=secret,id=npmrc,target=.npmrc or type=cache,target=/root/.cache
+func parseRunMount(value string) (*runMount, error) {
+	fields, err := csv.NewReader(strings.NewReader(value)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid mount %q: %v", value, err)
+	}
+
+	m := &runMount{UID: "0", GID: "0", Mode: 0400}
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		key := strings.ToLower(parts[0])
+		switch key {
+		case "dst", "destination":
+			key = "target"
+		}
+		seen[key] = true
+		if len(parts) == 1 {
+			// flags which can be set without a value
+			if key == "required" {
+				m.Required = true
+				continue
+			}
+			return nil, fmt.Errorf("invalid field %q in mount %q, must be a key=value pair", field, value)
+		}
+
+		val := parts[1]
+		switch key {
+		case "type":
+			m.Type = strings.ToLower(val)
+		case "id":
+			m.ID = val
+		case "target":
+			m.Target = val
+		case "required":
+			if m.Required, err = strconv.ParseBool(val); err != nil {
+				return nil, fmt.Errorf("invalid value for required in mount %q: %s", value, val)
+			}
+		case "uid":
+			if _, err := strconv.ParseUint(val, 10, 32); err != nil {
+				return nil, fmt.Errorf("invalid uid in mount %q: %s", value, val)
+			}
+			m.UID = val
+		case "gid":
+			if _, err := strconv.ParseUint(val, 10, 32); err != nil {
+				return nil, fmt.Errorf("invalid gid in mount %q: %s", value, val)
+			}
+			m.GID = val
+		case "mode":
+			mode, err := strconv.ParseUint(val, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mode in mount %q: %s", value, val)
+			}
+			m.Mode = os.FileMode(mode)
+		default:
+			return nil, fmt.Errorf("unknown field %q in mount %q", key, value)
+		}
+	}
+
+	allowed, ok := runMountFields[m.Type]
+	if m.Type == "" {
+		return nil, fmt.Errorf("mount %q requires a type", value)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unsupported mount type %q", m.Type)
+	}
+	for key := range seen {
+		supported := key == "type"
+		for _, field := range allowed {
+			supported = supported || key == field
+		}
+		if !supported {
+			return nil, fmt.Errorf("field %s is not supported by %s mounts", key, m.Type)
+		}
+	}
+
+	switch m.Type {
+	case mountTypeSecret:
+		if m.ID == "" {
+			return nil, fmt.Errorf("secret mount %q requires an id", value)
+		}
+		if m.Target == "" {
+			m.Target = m.ID
+		}
+		// secrets are files of /run/secrets
+		if m.Target != filepath.Base(m.Target) || m.Target == "." || m.Target == ".." {
+			return nil, fmt.Errorf("invalid target %q in mount %q, must be a file name", m.Target, value)
+		}
+	case mountTypeCache:
+		if m.Target == "" {
+			return nil, fmt.Errorf("cache mount %q requires a target", value)
+		}
+		if !system.IsAbs(m.Target) {
+			return nil, fmt.Errorf("invalid target %q in mount %q, must be an absolute path", m.Target, value)
+		}
+		// caches are shared by the builds using the same id
+		if m.ID == "" {
+			m.ID = m.Target
+		}
+	}
+	return m, nil
+}
+
+// runMounts parses the --mount flags of a RUN instruction, and returns the
+// build secrets and the caches to mount into its container. Secrets which the
+// build was not given are skipped, unless they are required.
+func (b *Builder) runMounts(values []string) ([]backend.BuildSecret, []*runMount, error) {
+	var (
+		secrets []backend.BuildSecret
+		caches  []*runMount
+	)
+	secretTargets := make(map[string]bool)
+	cacheTargets := make(map[string]bool)
+	cacheIDs := make(map[string]bool)
+	for _, value := range values {
+		m, err := parseRunMount(value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch m.Type {
+		case mountTypeCache:
+			if cacheTargets[m.Target] {
+				return nil, nil, fmt.Errorf("duplicate cache target %s", m.Target)
+			}
+			if cacheIDs[m.ID] {
+				return nil, nil, fmt.Errorf("duplicate cache id %s", m.ID)
+			}
+			cacheTargets[m.Target], cacheIDs[m.ID] = true, true
+			caches = append(caches, m)
+		case mountTypeSecret:
+			if secretTargets[m.Target] {
+				return nil, nil, fmt.Errorf("duplicate secret target %s", m.Target)
+			}
+			secretTargets[m.Target] = true
+
+			data, ok := b.options.Secrets[m.ID]
+			if !ok {
+				if m.Required {
+					return nil, nil, fmt.Errorf("secret %s is required but was not given to the build", m.ID)
+				}
+				continue
+			}
+			secrets = append(secrets, backend.BuildSecret{
+				Target: m.Target,
+				UID:    m.UID,
+				GID:    m.GID,
+				Mode:   m.Mode,
+				Data:   data,
+			})
+		}
+	}
+	return secrets, caches, nil
+}
+
+// mountCaches locks the caches of a RUN instruction, waiting for the builds
+// using them to release them, and returns their mounts along with a function
+// releasing them.
+func (b *Builder) mountCaches(caches []*runMount) ([]mount.Mount, func(), error) {
+	// caches are locked in the order of their ids, so that builds locking
+	// the same caches do not deadlock
+	var ids []string
+	byID := make(map[string]*runMount)
+	for _, m := range caches {
+		ids = append(ids, m.ID)
+		byID[m.ID] = m
+	}
+	sort.Strings(ids)
+
+	var (
+		mounts   []mount.Mount
+		releases []func()
+	)
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for _, id := range ids {
+		volume, r, err := b.docker.AcquireBuildCache(id)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		releases = append(releases, r)
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeVolume,
+			Source: volume,
+			Target: byID[id].Target,
+		})
+	}
+	return mounts, release, nil
+}
@@ -0,0 +1,148 @@
+package dockerfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/builder"
+)
+
+func TestParseRunMount(t *testing.T) {
+	m, err := parseRunMount("type=secret,id=npmrc")
+	if err != nil {
+		t.Fatalf("Error when parsing mount: %s", err)
+	}
+	if m.Type != mountTypeSecret || m.ID != "npmrc" || m.Target != "npmrc" || m.Required || m.UID != "0" || m.GID != "0" || m.Mode != 0400 {
+		t.Fatalf("Unexpected mount: %+v", m)
+	}
+
+	m, err = parseRunMount("type=secret,id=npmrc,target=.npmrc,required,uid=1000,gid=1000,mode=0440")
+	if err != nil {
+		t.Fatalf("Error when parsing mount: %s", err)
+	}
+	if m.Target != ".npmrc" || !m.Required || m.UID != "1000" || m.GID != "1000" || m.Mode != 0440 {
+		t.Fatalf("Unexpected mount: %+v", m)
+	}
+
+	invalid := map[string]string{
+		"id=npmrc":                               "requires a type",
+		"type=bind,id=npmrc":                     "unsupported mount type",
+		"type=secret":                            "requires an id",
+		"type=secret,id=npmrc,target=/etc/npmrc": "must be a file name",
+		"type=secret,id=npmrc,target=..":         "must be a file name",
+		"type=secret,id=npmrc,mode=rw":           "invalid mode",
+		"type=secret,id=npmrc,uid=root":          "invalid uid",
+		"type=secret,id=npmrc,required=maybe":    "invalid value for required",
+		"type=secret,id=npmrc,ro=true":           "unknown field",
+		"type=secret,npmrc":                      "must be a key=value pair",
+	}
+	for value, expected := range invalid {
+		if _, err := parseRunMount(value); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected an error containing %q for %s, got %v", expected, value, err)
+		}
+	}
+}
+
+func TestParseRunMountCache(t *testing.T) {
+	m, err := parseRunMount("type=cache,target=/root/.cache/go-build")
+	if err != nil {
+		t.Fatalf("Error when parsing mount: %s", err)
+	}
+	if m.Type != mountTypeCache || m.ID != "/root/.cache/go-build" || m.Target != "/root/.cache/go-build" {
+		t.Fatalf("Unexpected mount: %+v", m)
+	}
+
+	m, err = parseRunMount("type=cache,id=gomod,dst=/go/pkg/mod")
+	if err != nil {
+		t.Fatalf("Error when parsing mount: %s", err)
+	}
+	if m.ID != "gomod" || m.Target != "/go/pkg/mod" {
+		t.Fatalf("Unexpected mount: %+v", m)
+	}
+
+	invalid := map[string]string{
+		"type=cache,id=gomod":                     "requires a target",
+		"type=cache,target=go/pkg/mod":            "must be an absolute path",
+		"type=cache,target=/go/pkg/mod,mode=0700": "not supported by cache mounts",
+		"type=cache,target=/go/pkg/mod,required":  "not supported by cache mounts",
+	}
+	for value, expected := range invalid {
+		if _, err := parseRunMount(value); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected an error containing %q for %s, got %v", expected, value, err)
+		}
+	}
+}
+
+func TestRunMounts(t *testing.T) {
+	b := &Builder{options: &types.ImageBuildOptions{
+		Secrets: map[string][]byte{"npmrc": []byte("token")},
+	}}
+
+	secrets, caches, err := b.runMounts([]string{
+		"type=secret,id=npmrc,target=.npmrc",
+		"type=secret,id=other",
+		"type=cache,id=npm,target=/root/.npm",
+	})
+	if err != nil {
+		t.Fatalf("Error when getting mounts: %s", err)
+	}
+	if len(secrets) != 1 || secrets[0].Target != ".npmrc" || string(secrets[0].Data) != "token" {
+		t.Fatalf("Unexpected secrets: %+v", secrets)
+	}
+	if len(caches) != 1 || caches[0].ID != "npm" {
+		t.Fatalf("Unexpected caches: %+v", caches)
+	}
+
+	invalid := map[string][]string{
+		"is required":             {"type=secret,id=other,required"},
+		"duplicate secret target": {"type=secret,id=npmrc", "type=secret,id=other,target=npmrc"},
+		"duplicate cache target":  {"type=cache,id=a,target=/cache", "type=cache,id=b,target=/cache"},
+		"duplicate cache id":      {"type=cache,id=a,target=/cache", "type=cache,id=a,target=/other"},
+	}
+	for expected, values := range invalid {
+		if _, _, err := b.runMounts(values); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected an error containing %q for %v, got %v", expected, values, err)
+		}
+	}
+}
+
+// cacheBackend records the order in which caches are acquired and released.
+type cacheBackend struct {
+	builder.Backend
+	events []string
+}
+
+func (c *cacheBackend) AcquireBuildCache(id string) (string, func(), error) {
+	c.events = append(c.events, "acquire "+id)
+	return "volume-" + id, func() { c.events = append(c.events, "release "+id) }, nil
+}
+
+func TestMountCaches(t *testing.T) {
+	docker := &cacheBackend{}
+	b := &Builder{docker: docker}
+
+	caches := []*runMount{
+		{Type: mountTypeCache, ID: "npm", Target: "/root/.npm"},
+		{Type: mountTypeCache, ID: "gomod", Target: "/go/pkg/mod"},
+	}
+	mounts, release, err := b.mountCaches(caches)
+	if err != nil {
+		t.Fatalf("Error when mounting caches: %s", err)
+	}
+	release()
+
+	expected := []mount.Mount{
+		{Type: mount.TypeVolume, Source: "volume-gomod", Target: "/go/pkg/mod"},
+		{Type: mount.TypeVolume, Source: "volume-npm", Target: "/root/.npm"},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("Expected mounts %+v, got %+v", expected, mounts)
+	}
+	events := []string{"acquire gomod", "acquire npm", "release gomod", "release npm"}
+	if !reflect.DeepEqual(docker.events, events) {
+		t.Fatalf("Expected %v, got %v", events, docker.events)
+	}
+}
@@ -55,6 +55,39 @@ func ProcessWords(word string, env []string, escapeToken rune) ([]string, error)
 	return words, err
 }
 
+// ProcessHeredoc will use the 'env' list of environment variables, and
+// replace any env var references in the content of a here-document. Like
+// in a shell, the quotes are kept as they are, and the escape token only
+// escapes '$' and itself.
+func ProcessHeredoc(content string, env []string, escapeToken rune) (string, error) {
+	sw := &shellWord{
+		word:        content,
+		envs:        env,
+		pos:         0,
+		escapeToken: escapeToken,
+	}
+	sw.scanner.Init(strings.NewReader(content))
+	var result string
+	for sw.scanner.Peek() != scanner.EOF {
+		if sw.scanner.Peek() == '$' {
+			tmp, err := sw.processDollar()
+			if err != nil {
+				return "", err
+			}
+			result += tmp
+			continue
+		}
+		ch := sw.scanner.Next()
+		if ch == escapeToken {
+			if next := sw.scanner.Peek(); next == '$' || next == escapeToken {
+				ch = sw.scanner.Next()
+			}
+		}
+		result += string(ch)
+	}
+	return result, nil
+}
+
 func (sw *shellWord) process() (string, []string, error) {
 	return sw.processStopOn(scanner.EOF)
 }
@@ -153,3 +153,28 @@ func TestGetEnv(t *testing.T) {
 		t.Fatal("8 - 'car' should map to 'hat'")
 	}
 }
+
+func TestProcessHeredoc(t *testing.T) {
+	envs := []string{"NAME=world", "EMPTY="}
+	tests := []struct {
+		content     string
+		escapeToken rune
+		expected    string
+	}{
+		{"hello $NAME\n", '\\', "hello world\n"},
+		{"'$NAME' \"${NAME}\"\n", '\\', "'world' \"world\"\n"},
+		{"${EMPTY:-default} ${NAME:+set}\n", '\\', "default set\n"},
+		{"\\$NAME \\\\ \\n\n", '\\', "$NAME \\ \\n\n"},
+		{"`$NAME \\$NAME\n", '`', "$NAME \\world\n"},
+		{"50$ #1\n", '\\', "50$ #1\n"},
+	}
+	for _, test := range tests {
+		result, err := ProcessHeredoc(test.content, envs, test.escapeToken)
+		if err != nil {
+			t.Fatalf("%q: %v", test.content, err)
+		}
+		if result != test.expected {
+			t.Fatalf("%q was supposed to result in %q, but got %q instead", test.content, test.expected, result)
+		}
+	}
+}
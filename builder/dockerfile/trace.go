@@ -1,65 +1,308 @@
 package dockerfile
 
 import (
+	"bufio"
+	"debug/elf"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
-	"time"
 
-	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 )
 
 const (
-	TraceFilePrefix   = "btrace"
-	TraceDir          = "/var/run/docker-btrace/"
-	ContainerTraceDir = "/trace"
-)
+	// TraceDir is the directory of the host in which the traces of the
+	// running builds are collected.
+	TraceDir = "/var/run/docker-btrace"
 
-var (
-	initFlag sync.Once
+	// ContainerTraceDir is where the trace directory of a build is mounted
+	// in its containers. It lives under /dev, which is a tmpfs, so that the
+	// mount point does not end up in the committed layer.
+	ContainerTraceDir = "/dev/.btrace"
+
+	// ContainerTracer is where the tracer binary of the daemon is mounted in
+	// the build containers.
+	ContainerTracer = "/dev/.btrace-strace"
+
+	// tracerName is the name of the tracer shipped with the daemon, a
+	// static build of strace, looked up in the PATH of the daemon. It runs
+	// inside the build containers, so it must not depend on the libraries
+	// of the image being built.
+	tracerName = "docker-btrace"
+
+	// syscalls traced in each RUN step
+	tracedSyscalls = "trace=open,openat,execve,connect,sendto"
 )
 
-func createTraceDir() {
-	info, err := os.Stat(TraceDir)
-	if err != nil && !os.IsNotExist(err) {
-		logrus.Errorf("Error to stat trace dir %s: %v\n", TraceDir, err)
-		return
+// tracer runs the RUN steps of a build under strace and collects what they
+// accessed. The stages of a build running concurrently share it.
+type tracer struct {
+	path string // path of the tracer on the host
+	dir  string // trace directory of the build on the host
+
+	mu    sync.Mutex
+	steps []types.BuildTraceStep
+}
+
+// newTracer prepares the trace directory of a build.
+func newTracer() (*tracer, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("tracing builds is not supported on Windows")
+	}
+	path, err := exec.LookPath(tracerName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot trace build: %v", err)
+	}
+	if err := checkStatic(path); err != nil {
+		return nil, fmt.Errorf("cannot trace build: %v", err)
+	}
+	if err := os.MkdirAll(TraceDir, 0755); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(TraceDir, "build-")
+	if err != nil {
+		return nil, err
+	}
+	// the steps may run as any user of the image
+	if err := os.Chmod(dir, 0777); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &tracer{path: path, dir: dir}, nil
+}
+
+// checkStatic checks that the executable at path is statically linked, so
+// that it runs in the build containers whatever their image.
+func checkStatic(path string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_INTERP {
+			return fmt.Errorf("%s is dynamically linked, it would load the libraries of the image being built", path)
+		}
+	}
+	return nil
+}
+
+// Close removes the trace directory of the build.
+func (t *tracer) Close() error {
+	return os.RemoveAll(t.dir)
+}
+
+// binds returns the mounts giving a build container access to the tracer
+// and to the trace directory.
+func (t *tracer) binds() []string {
+	return []string{
+		fmt.Sprintf("%s:%s:rw", t.dir, ContainerTraceDir),
+		fmt.Sprintf("%s:%s:ro", t.path, ContainerTracer),
+	}
+}
+
+func stepPrefix(step int) string {
+	return fmt.Sprintf("step-%d", step)
+}
+
+// command wraps the command of a RUN step so that it runs under the tracer.
+// Every process of the step writes its trace to a file of its own.
+func (t *tracer) command(step int, cmd []string) []string {
+	return append([]string{
+		ContainerTracer, "-q", "-ff",
+		"-e", tracedSyscalls,
+		"-o", ContainerTraceDir + "/" + stepPrefix(step),
+	}, cmd...)
+}
+
+// skip records a step which was not traced because it was taken from the
+// build cache.
+func (t *tracer) skip(step int, instruction string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps = append(t.steps, types.BuildTraceStep{
+		Step:        step + 1,
+		Instruction: instruction,
+		Cached:      true,
+	})
+}
+
+// collect parses the traces written by a step and removes them.
+func (t *tracer) collect(step int, instruction string) error {
+	files, err := filepath.Glob(filepath.Join(t.dir, stepPrefix(step)+".*"))
+	if err != nil {
+		return err
 	}
-	if os.IsNotExist(err) {
-		err = os.Mkdir(TraceDir, 0755)
+	s := newTraceSet()
+	for _, file := range files {
+		f, err := os.Open(file)
 		if err != nil {
-			logrus.Errorf("Error to create trace dir %s: %v\n", TraceDir, err)
-			return
+			return err
 		}
-	} else {
-		if !info.IsDir() {
-			logrus.Errorf("trace dir exist as non-dir %s\n", TraceDir)
+		err = s.parse(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("error parsing trace of step %d: %v", step+1, err)
+		}
+		if err := os.Remove(file); err != nil {
+			return err
 		}
 	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps = append(t.steps, types.BuildTraceStep{
+		Step:        step + 1,
+		Instruction: instruction,
+		FilesRead:   s.files.list(),
+		Executed:    s.executed.list(),
+		Endpoints:   s.endpoints.list(),
+	})
+	return nil
+}
+
+// report aggregates the traces of all steps, listed in the order of the
+// Dockerfile.
+func (t *tracer) report(imageID string) *types.BuildTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
+	sort.Sort(byStep(t.steps))
+	all := newTraceSet()
+	for _, step := range t.steps {
+		all.files.add(step.FilesRead...)
+		all.executed.add(step.Executed...)
+		all.endpoints.add(step.Endpoints...)
+	}
+	return &types.BuildTrace{
+		ImageID:   imageID,
+		FilesRead: all.files.list(),
+		Executed:  all.executed.list(),
+		Endpoints: all.endpoints.list(),
+		Steps:     t.steps,
+	}
 }
 
-func (b *Builder) TraceInit(name string) {
-	initFlag.Do(createTraceDir)
-	logrus.Debug("Tapcon start image build trace")
+type byStep []types.BuildTraceStep
 
-	ts := time.Now().Unix()
-	b.traceKey = fmt.Sprintf("%d", ts)
-	b.traceName = name
-	dirName := fmt.Sprintf("%s/%s", ContainerTraceDir, name)
-	if _, err := os.Stat(dirName); err != nil {
-		if os.IsNotExist(err) {
-			os.Mkdir(dirName, 0755)
-		}
+func (s byStep) Len() int           { return len(s) }
+func (s byStep) Less(i, j int) bool { return s[i].Step < s[j].Step }
+func (s byStep) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type stringSet map[string]struct{}
+
+func (s stringSet) add(values ...string) {
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+}
+
+func (s stringSet) list() []string {
+	if len(s) == 0 {
+		return nil
 	}
+	l := make([]string, 0, len(s))
+	for v := range s {
+		l = append(l, v)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// traceSet is what a set of processes accessed.
+type traceSet struct {
+	files     stringSet
+	executed  stringSet
+	endpoints stringSet
 }
 
-func (b *Builder) TraceCmdPrefix(id int) []string {
-	traceFileName := fmt.Sprintf("%s/%s/log-%s.%d", ContainerTraceDir, b.traceName, b.traceKey, id)
-	return []string{"strace", "-e", "trace=open,process,network", "-ff", "-o", traceFileName,
-		"sh", "-c"}
+func newTraceSet() *traceSet {
+	return &traceSet{
+		files:     make(stringSet),
+		executed:  make(stringSet),
+		endpoints: make(stringSet),
+	}
 }
 
-func (b *Builder) setupTraceVolume() []string {
-	return []string{fmt.Sprintf("%s:%s:rw", TraceDir, ContainerTraceDir)}
+var (
+	// open("path", FLAGS...) or openat(dirfd, "path", FLAGS...)
+	openRe = regexp.MustCompile(`^open(?:at)?\((?:[^",]+, )?"((?:[^"\\]|\\.)*)", ([A-Z_|]+)`)
+	// execve("path", [argv], ...)
+	execRe = regexp.MustCompile(`^execve\("((?:[^"\\]|\\.)*)"`)
+	// connect(fd, {sa_family=...}, len) or sendto(fd, buf, len, flags, {sa_family=...}, len)
+	netRe   = regexp.MustCompile(`^(?:connect|sendto)\(.*\{sa_family=(AF_INET6?),`)
+	inetRe  = regexp.MustCompile(`sin_port=htons\((\d+)\), sin_addr=inet_addr\("([^"]+)"\)`)
+	inet6Re = regexp.MustCompile(`sin6_port=htons\((\d+)\),.*inet_pton\(AF_INET6, "([^"]+)"`)
+)
+
+// result returns the return value of the system call traced on line.
+func result(line string) (int, bool) {
+	i := strings.LastIndex(line, ") = ")
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(line[i+len(") = "):])
+	if len(fields) == 0 {
+		return 0, false
+	}
+	ret, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, false
+	}
+	return ret, true
+}
+
+// unquote undoes the escaping of a string printed by strace.
+func unquote(s string) string {
+	if u, err := strconv.Unquote(`"` + s + `"`); err == nil {
+		return u
+	}
+	return s
+}
+
+// parse adds the accesses found in the strace output read from r. Failed
+// opens and executions are ignored, while every attempt to contact an
+// endpoint is recorded.
+func (s *traceSet) parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := openRe.FindStringSubmatch(line); m != nil {
+			flags := m[2]
+			if ret, ok := result(line); !ok || ret < 0 || strings.Contains(flags, "O_DIRECTORY") {
+				continue
+			}
+			if strings.Contains(flags, "O_RDONLY") || strings.Contains(flags, "O_RDWR") {
+				s.files.add(unquote(m[1]))
+			}
+			continue
+		}
+		if m := execRe.FindStringSubmatch(line); m != nil {
+			if ret, ok := result(line); ok && ret == 0 {
+				s.executed.add(unquote(m[1]))
+			}
+			continue
+		}
+		if m := netRe.FindStringSubmatch(line); m != nil {
+			re := inetRe
+			if m[1] == "AF_INET6" {
+				re = inet6Re
+			}
+			if a := re.FindStringSubmatch(line); a != nil {
+				s.endpoints.add(net.JoinHostPort(a[2], a[1]))
+			}
+		}
+	}
+	return scanner.Err()
 }
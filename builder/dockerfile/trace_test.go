@@ -0,0 +1,95 @@
+package dockerfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+const straceOutput = `execve("/bin/sh", ["sh", "-c", "apt-get update"], [/* 5 vars */]) = 0
+open("/etc/ld.so.cache", O_RDONLY|O_CLOEXEC) = 3
+open("/lib/x86_64-linux-gnu/libc.so.6", O_RDONLY|O_CLOEXEC) = 3
+open("/etc/missing", O_RDONLY) = -1 ENOENT (No such file or directory)
+openat(AT_FDCWD, "/etc/apt/sources.list", O_RDONLY) = 4
+openat(AT_FDCWD, "/etc/apt/sources.list.d", O_RDONLY|O_NONBLOCK|O_DIRECTORY|O_CLOEXEC) = 5
+open("/var/lib/apt/lists/lock", O_WRONLY|O_CREAT|O_TRUNC, 0640) = 6
+open("/tmp/with \"quote\"", O_RDWR) = 7
+execve("/usr/bin/missing", ["missing"], [/* 5 vars */]) = -1 ENOENT (No such file or directory)
+execve("/usr/bin/apt-get", ["apt-get", "update"], [/* 5 vars */]) = 0
+connect(3, {sa_family=AF_UNIX, sun_path="/var/run/nscd/socket"}, 110) = -1 ENOENT (No such file or directory)
+sendto(3, "\x12\x34\x01\x00", 38, MSG_NOSIGNAL, {sa_family=AF_INET, sin_port=htons(53), sin_addr=inet_addr("8.8.8.8")}, 16) = 38
+connect(4, {sa_family=AF_INET, sin_port=htons(80), sin_addr=inet_addr("91.189.88.149")}, 16) = -1 EINPROGRESS (Operation now in progress)
+connect(5, {sa_family=AF_INET6, sin6_port=htons(443), inet_pton(AF_INET6, "2001:db8::1", &sin6_addr), sin6_flowinfo=htonl(0), sin6_scope_id=0}, 28) = 0
++++ exited with 0 +++
+`
+
+func TestTraceParse(t *testing.T) {
+	s := newTraceSet()
+	if err := s.parse(strings.NewReader(straceOutput)); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []string{
+		"/etc/apt/sources.list",
+		"/etc/ld.so.cache",
+		"/lib/x86_64-linux-gnu/libc.so.6",
+		`/tmp/with "quote"`,
+	}
+	if got := s.files.list(); !reflect.DeepEqual(got, files) {
+		t.Fatalf("expected files %v, got %v", files, got)
+	}
+
+	executed := []string{"/bin/sh", "/usr/bin/apt-get"}
+	if got := s.executed.list(); !reflect.DeepEqual(got, executed) {
+		t.Fatalf("expected executed %v, got %v", executed, got)
+	}
+
+	endpoints := []string{"8.8.8.8:53", "91.189.88.149:80", "[2001:db8::1]:443"}
+	if got := s.endpoints.list(); !reflect.DeepEqual(got, endpoints) {
+		t.Fatalf("expected endpoints %v, got %v", endpoints, got)
+	}
+}
+
+func TestTraceReport(t *testing.T) {
+	tr := &tracer{}
+	tr.steps = []types.BuildTraceStep{
+		{Step: 2, Instruction: "RUN a", FilesRead: []string{"/b", "/a"}, Executed: []string{"/bin/sh"}},
+		{Step: 3, Instruction: "RUN b", Cached: true},
+		{Step: 4, Instruction: "RUN c", FilesRead: []string{"/a"}, Executed: []string{"/bin/sh"}, Endpoints: []string{"1.2.3.4:80"}},
+	}
+
+	report := tr.report("sha256:abc")
+	if report.ImageID != "sha256:abc" || len(report.Steps) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if expected := []string{"/a", "/b"}; !reflect.DeepEqual(report.FilesRead, expected) {
+		t.Fatalf("expected files %v, got %v", expected, report.FilesRead)
+	}
+	if expected := []string{"/bin/sh"}; !reflect.DeepEqual(report.Executed, expected) {
+		t.Fatalf("expected executed %v, got %v", expected, report.Executed)
+	}
+	if expected := []string{"1.2.3.4:80"}; !reflect.DeepEqual(report.Endpoints, expected) {
+		t.Fatalf("expected endpoints %v, got %v", expected, report.Endpoints)
+	}
+}
+
+func TestTraceCommand(t *testing.T) {
+	tr := &tracer{}
+	cmd := tr.command(1, []string{"/bin/sh", "-c", "true"})
+	if cmd[0] != ContainerTracer {
+		t.Fatalf("expected the command to run under %s, got %v", ContainerTracer, cmd)
+	}
+	if tail := cmd[len(cmd)-3:]; !reflect.DeepEqual(tail, []string{"/bin/sh", "-c", "true"}) {
+		t.Fatalf("expected the command to end with the step command, got %v", cmd)
+	}
+}
+
+func TestCheckStatic(t *testing.T) {
+	if err := checkStatic("/bin/sh"); err == nil {
+		t.Skip("/bin/sh is statically linked")
+	} else if !strings.Contains(err.Error(), "dynamically linked") {
+		t.Fatalf("expected /bin/sh to be refused as dynamically linked, got %v", err)
+	}
+}
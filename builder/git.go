@@ -1,9 +1,14 @@
 package builder
 
 import (
+	"crypto/sha1"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/gitutils"
 )
@@ -71,3 +76,80 @@ func MakeTrustedGitContext(gitURL string,
 	idHash []byte, cwdHash []byte) TrustedGitContext {
 	return &trustedGitContext{gitURL, idHash, cwdHash}
 }
+
+// MakeTrustedTarContext returns a Context from a tar stream taken from the
+// git checkout described by identity. The context is trusted only if its
+// files hash to the tree recorded in the identity, with the semantics of
+// git. The remote of the identity cannot be verified, so the trusted
+// context has no git URL.
+func MakeTrustedTarContext(r io.Reader, identity *types.GitIdentity) (ModifiableContext, TrustedGitContext, error) {
+	c, err := MakeTarSumContext(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifyGitIdentity(c.(*tarSumContext).root, identity); err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("untrusted build context: %v", err)
+	}
+	cwdHash := sha1.Sum([]byte(identity.Subdir))
+	return c, MakeTrustedGitContext("", []byte(identity.Commit), cwdHash[:]), nil
+}
+
+// verifyGitIdentity checks that the files under root are the tree of the
+// directory identity.Subdir of the commit identity.Commit.
+func verifyGitIdentity(root string, identity *types.GitIdentity) error {
+	if identity.Dirty {
+		return errors.New("the context has uncommitted changes")
+	}
+	if identity.Remote == "" {
+		return errors.New("the repository has no remote")
+	}
+	if gitutils.HashObject("commit", identity.CommitObject) != identity.Commit {
+		return fmt.Errorf("commit object does not match commit %s", identity.Commit)
+	}
+	tree, err := gitutils.CommitTree(identity.CommitObject)
+	if err != nil {
+		return err
+	}
+	if tree != identity.Tree {
+		return fmt.Errorf("commit %s does not have tree %s", identity.Commit, identity.Tree)
+	}
+
+	// walk down from the tree of the commit to the tree of the context
+	var dirs []string
+	if subdir := strings.Trim(identity.Subdir, "/"); subdir != "" {
+		dirs = strings.Split(subdir, "/")
+	}
+	if len(identity.Trees) != len(dirs) {
+		return fmt.Errorf("expected %d tree objects for %q, got %d", len(dirs), identity.Subdir, len(identity.Trees))
+	}
+	expected := identity.Tree
+	for i, dir := range dirs {
+		if hash := gitutils.HashObject("tree", identity.Trees[i]); hash != expected {
+			return fmt.Errorf("tree object %s does not match tree %s", hash, expected)
+		}
+		entries, err := gitutils.ParseTree(identity.Trees[i])
+		if err != nil {
+			return err
+		}
+		expected = ""
+		for _, e := range entries {
+			if e.Name == dir && e.Mode == gitutils.ModeTree {
+				expected = e.Hash
+				break
+			}
+		}
+		if expected == "" {
+			return fmt.Errorf("directory %s is not in commit %s", strings.Join(dirs[:i+1], "/"), identity.Commit)
+		}
+	}
+
+	actual, err := gitutils.TreeHash(root)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("the files of the context do not match commit %s", identity.Commit)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func gitOutput(t *testing.T, dir string, args ...string) []byte {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return out
+}
+
+// makeGitIdentity commits files in a new repository and returns the
+// repository along with the identity of its sub directory app.
+func makeGitIdentity(t *testing.T, files map[string]string) (string, *types.GitIdentity) {
+	root, err := ioutil.TempDir("", "builder-git-identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, root, "init")
+	gitOutput(t, root, "config", "user.email", "test@docker.com")
+	gitOutput(t, root, "config", "user.name", "Docker test")
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitOutput(t, root, "add", ".")
+	gitOutput(t, root, "commit", "-m", "initial")
+
+	commit := strings.TrimSpace(string(gitOutput(t, root, "rev-parse", "HEAD")))
+	return root, &types.GitIdentity{
+		Remote:       "https://example.com/app.git",
+		Commit:       commit,
+		CommitObject: gitOutput(t, root, "cat-file", "commit", commit),
+		Tree:         strings.TrimSpace(string(gitOutput(t, root, "rev-parse", commit+"^{tree}"))),
+		Subdir:       "app/",
+		Trees:        [][]byte{gitOutput(t, root, "cat-file", "tree", commit+"^{tree}")},
+	}
+}
+
+func TestVerifyGitIdentity(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root, identity := makeGitIdentity(t, map[string]string{
+		"README":         "readme",
+		"app/Dockerfile": "FROM scratch\n",
+		"app/src/file":   "content",
+	})
+	defer os.RemoveAll(root)
+	contextDir := filepath.Join(root, "app")
+
+	if err := verifyGitIdentity(contextDir, identity); err != nil {
+		t.Fatalf("expected the context to match its identity: %v", err)
+	}
+
+	// the context must be the sub directory of the identity
+	if err := verifyGitIdentity(root, identity); err == nil {
+		t.Fatal("expected the root of the checkout not to match the identity of app/")
+	}
+
+	dirty := *identity
+	dirty.Dirty = true
+	if err := verifyGitIdentity(contextDir, &dirty); err == nil {
+		t.Fatal("expected a dirty identity to be rejected")
+	}
+
+	forged := *identity
+	forged.Tree = strings.Repeat("0", 40)
+	if err := verifyGitIdentity(contextDir, &forged); err == nil {
+		t.Fatal("expected a tree not matching the commit to be rejected")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(contextDir, "src", "file"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyGitIdentity(contextDir, identity); err == nil {
+		t.Fatal("expected a modified context to be rejected")
+	}
+}
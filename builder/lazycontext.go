@@ -0,0 +1,148 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/docker/docker/pkg/pools"
+	"github.com/docker/docker/pkg/symlink"
+)
+
+// lazyContext is a Context over a directory which hashes the files only
+// when they are accessed. It does not own the directory, so Close leaves it
+// in place.
+type lazyContext struct {
+	root string
+
+	mu   sync.Mutex
+	sums map[string]string
+}
+
+// NewLazyContext returns a build Context for the directory at root, such as
+// the mounted filesystem of an image. The hash of a file is computed from its
+// metadata and content the first time it is accessed.
+func NewLazyContext(root string) (Context, error) {
+	return &lazyContext{
+		root: root,
+		sums: make(map[string]string),
+	}, nil
+}
+
+func (c *lazyContext) Close() error {
+	return nil
+}
+
+func (c *lazyContext) normalize(path string) (cleanpath, fullpath string, err error) {
+	cleanpath = filepath.Clean(string(os.PathSeparator) + path)[1:]
+	fullpath, err = symlink.FollowSymlinkInScope(filepath.Join(c.root, path), c.root)
+	if err != nil {
+		return "", "", fmt.Errorf("Forbidden path outside the build context: %s (%s)", path, fullpath)
+	}
+	return
+}
+
+func (c *lazyContext) Open(path string) (io.ReadCloser, error) {
+	cleanpath, fullpath, err := c.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := os.Open(fullpath)
+	if err != nil {
+		return nil, convertPathError(err, cleanpath)
+	}
+	return r, nil
+}
+
+func (c *lazyContext) Stat(path string) (string, FileInfo, error) {
+	cleanpath, fullpath, err := c.normalize(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	st, err := os.Lstat(fullpath)
+	if err != nil {
+		return "", nil, convertPathError(err, cleanpath)
+	}
+
+	rel, err := filepath.Rel(c.root, fullpath)
+	if err != nil {
+		return "", nil, convertPathError(err, cleanpath)
+	}
+
+	sum, err := c.hash(rel, st)
+	if err != nil {
+		return "", nil, err
+	}
+	fi := &HashedFileInfo{PathFileInfo{st, fullpath, filepath.Base(cleanpath)}, sum}
+	return rel, fi, nil
+}
+
+func (c *lazyContext) Walk(root string, walkFn WalkFunc) error {
+	_, fullpath, err := c.normalize(root)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(fullpath, func(fullpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.root, fullpath)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		sum, err := c.hash(rel, info)
+		if err != nil {
+			return err
+		}
+		fi := &HashedFileInfo{PathFileInfo{FileInfo: info, FilePath: fullpath}, sum}
+		return walkFn(rel, fi, nil)
+	})
+}
+
+// hash returns the hash of the file at rel, computing it if it was not
+// accessed before. It covers the name, mode, link target and content of the
+// file, but not its ownership and timestamps, which COPY does not preserve.
+func (c *lazyContext) hash(rel string, fi os.FileInfo) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if sum, ok := c.sums[rel]; ok {
+		return sum, nil
+	}
+
+	fullpath := filepath.Join(c.root, rel)
+	var link string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(fullpath); err != nil {
+			return "", err
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "name%smode%dsize%dlink%s", filepath.ToSlash(rel), fi.Mode(), fi.Size(), link)
+	if fi.Mode().IsRegular() && fi.Size() > 0 {
+		f, err := os.Open(fullpath)
+		if err != nil {
+			return "", err
+		}
+		_, err = pools.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	c.sums[rel] = sum
+	return sum, nil
+}
@@ -0,0 +1,97 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLazyContextStat(t *testing.T) {
+	contextDir, cleanup := createTestTempDir(t, "", "builder-lazy-context-test")
+	defer cleanup()
+
+	createTestTempFile(t, contextDir, filename, contents, 0644)
+	createTestTempFile(t, contextDir, "other", contents+"-changed", 0644)
+
+	ctx, err := NewLazyContext(contextDir)
+	if err != nil {
+		t.Fatalf("Error when creating lazy context: %s", err)
+	}
+	defer ctx.Close()
+
+	rel, fi, err := ctx.Stat(filename)
+	if err != nil {
+		t.Fatalf("Error when executing Stat: %s", err)
+	}
+	if rel != filename {
+		t.Fatalf("Stat returned %s, expected %s", rel, filename)
+	}
+	sum := fi.(Hashed).Hash()
+	if sum == "" {
+		t.Fatal("Stat returned an empty hash")
+	}
+
+	_, other, err := ctx.Stat("other")
+	if err != nil {
+		t.Fatalf("Error when executing Stat: %s", err)
+	}
+	if other.(Hashed).Hash() == sum {
+		t.Fatal("Files with different contents have the same hash")
+	}
+
+	// hashes are computed once
+	if err := ioutil.WriteFile(filepath.Join(contextDir, filename), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Error when writing file: %s", err)
+	}
+	_, fi, err = ctx.Stat(filename)
+	if err != nil {
+		t.Fatalf("Error when executing Stat: %s", err)
+	}
+	if fi.(Hashed).Hash() != sum {
+		t.Fatal("The hash of a file changed on the second access")
+	}
+
+	if _, _, err := ctx.Stat("../" + filename); err == nil {
+		t.Fatal("Stat should fail for a path outside of the context")
+	}
+
+	// Close does not remove the directory, which the context does not own
+	if _, err := os.Stat(contextDir); err != nil {
+		t.Fatalf("Context directory was removed: %s", err)
+	}
+}
+
+func TestLazyContextWalk(t *testing.T) {
+	contextDir, cleanup := createTestTempDir(t, "", "builder-lazy-context-test")
+	defer cleanup()
+
+	subdir := createTestTempSubdir(t, contextDir, "builder-lazy-context-subdir")
+	createTestTempFile(t, subdir, filename, contents, 0644)
+
+	ctx, err := NewLazyContext(contextDir)
+	if err != nil {
+		t.Fatalf("Error when creating lazy context: %s", err)
+	}
+	defer ctx.Close()
+
+	var walked []string
+	err = ctx.Walk(filepath.Base(subdir), func(path string, fi FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.(Hashed).Hash() == "" {
+			t.Fatalf("Walk returned an empty hash for %s", path)
+		}
+		walked = append(walked, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error when walking the context: %s", err)
+	}
+
+	expected := []string{filepath.Base(subdir), filepath.Join(filepath.Base(subdir), filename)}
+	if len(walked) != len(expected) || walked[0] != expected[0] || walked[1] != expected[1] {
+		t.Fatalf("Walk visited %v, expected %v", walked, expected)
+	}
+}
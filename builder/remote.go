@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"regexp"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/httputils"
 	"github.com/docker/docker/pkg/urlutil"
@@ -70,13 +71,18 @@ func MakeRemoteContext(remoteURL string, contentTypeHandlers map[string]func(io.
 // DetectContextFromRemoteURL returns a context and in certain cases the name of the dockerfile to be used
 // irrespective of user input.
 // progressReader is only used if remoteURL is actually a URL (not empty, and not a Git endpoint).
-func DetectContextFromRemoteURL(r io.ReadCloser, remoteURL string, createProgressReader func(in io.ReadCloser) io.ReadCloser, backend Backend) (context ModifiableContext, sourceCtx TrustedGitContext, dockerfileName string, err error) {
+// A context uploaded by the client is trusted if it comes with a git identity
+// matching its files.
+func DetectContextFromRemoteURL(r io.ReadCloser, remoteURL string, identity *types.GitIdentity, createProgressReader func(in io.ReadCloser) io.ReadCloser, backend Backend) (context ModifiableContext, sourceCtx TrustedGitContext, dockerfileName string, err error) {
 
 	sourceCtx = nil
 	switch {
+	case remoteURL == "" && identity != nil:
+		context, sourceCtx, err = MakeTrustedTarContext(r, identity)
 	case remoteURL == "":
 		if backend.TapconModeOn() {
-			err = fmt.Errorf("Tapcon mode should use only Git build")
+			err = fmt.Errorf("Tapcon mode should use only Git build or a context with a git identity")
+			return
 		}
 		context, err = MakeTarSumContext(r)
 	case urlutil.IsGitURL(remoteURL):
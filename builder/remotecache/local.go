@@ -0,0 +1,136 @@
+package remotecache
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/net/context"
+)
+
+// indexFile is the name of the manifest in the directory of a local cache.
+const indexFile = "index.json"
+
+// localCache is a cache in a directory. The manifest is stored in
+// index.json, and the blobs in blobs/<algorithm>/<hex>.
+type localCache struct {
+	root string
+}
+
+// NewLocal returns the cache in the directory root, which is created when
+// the cache is exported.
+func NewLocal(root string) Target {
+	return &localCache{root: root}
+}
+
+func (c *localCache) blobPath(dgst digest.Digest) string {
+	return filepath.Join(c.root, "blobs", string(dgst.Algorithm()), dgst.Hex())
+}
+
+func (c *localCache) Manifest(ctx context.Context) (*Manifest, error) {
+	p, err := ioutil.ReadFile(filepath.Join(c.root, indexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(p, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (c *localCache) Open(ctx context.Context, dgst digest.Digest) (io.ReadCloser, error) {
+	if err := dgst.Validate(); err != nil {
+		return nil, err
+	}
+	return os.Open(c.blobPath(dgst))
+}
+
+func (c *localCache) Has(ctx context.Context, dgst digest.Digest) (bool, error) {
+	if err := dgst.Validate(); err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(c.blobPath(dgst)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *localCache) Put(ctx context.Context, mediaType string, r io.Reader) (Blob, error) {
+	dir := filepath.Join(c.root, "blobs", string(digest.Canonical))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Blob{}, err
+	}
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return Blob{}, err
+	}
+	defer os.Remove(f.Name())
+
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(io.MultiWriter(f, digester.Hash()), r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return Blob{}, err
+	}
+
+	blob := Blob{MediaType: mediaType, Digest: digester.Digest(), Size: size}
+	if err := os.Rename(f.Name(), c.blobPath(blob.Digest)); err != nil {
+		return Blob{}, err
+	}
+	return blob, nil
+}
+
+// Commit writes the manifest and removes the blobs it does not refer to,
+// which belonged to the manifests exported before.
+func (c *localCache) Commit(ctx context.Context, m *Manifest) error {
+	p, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := ioutils.AtomicWriteFile(filepath.Join(c.root, indexFile), p, 0644); err != nil {
+		return err
+	}
+
+	used := make(map[string]bool)
+	for _, b := range m.Blobs() {
+		used[c.blobPath(b.Digest)] = true
+	}
+	dir := filepath.Join(c.root, "blobs", string(digest.Canonical))
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, fi := range files {
+		if strings.HasPrefix(fi.Name(), ".tmp-") {
+			// written by an export in progress
+			continue
+		}
+		if p := filepath.Join(dir, fi.Name()); !used[p] {
+			if err := os.Remove(p); err != nil {
+				logrus.Warnf("Failed to remove unused build cache blob %s: %v", p, err)
+			}
+		}
+	}
+	return nil
+}
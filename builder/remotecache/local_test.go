@@ -0,0 +1,90 @@
+package remotecache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"golang.org/x/net/context"
+)
+
+func TestLocalCache(t *testing.T) {
+	root, err := ioutil.TempDir("", "remotecache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	ctx := context.Background()
+	c := NewLocal(filepath.Join(root, "cache"))
+	if m, err := c.Manifest(ctx); err != nil || m != nil {
+		t.Fatalf("An empty cache should have no manifest, got %v, %v", m, err)
+	}
+
+	config, err := c.Put(ctx, "config", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Error when storing blob: %s", err)
+	}
+	stale, err := c.Put(ctx, "layer", strings.NewReader("stale"))
+	if err != nil {
+		t.Fatalf("Error when storing blob: %s", err)
+	}
+	if config.Size != 2 || config.MediaType != "config" {
+		t.Fatalf("Unexpected blob: %+v", config)
+	}
+	if has, err := c.Has(ctx, config.Digest); err != nil || !has {
+		t.Fatalf("The cache should have blob %s, got %v, %v", config.Digest, has, err)
+	}
+
+	key, err := Key("", &container.Config{Cmd: []string{"/bin/sh", "-c", "make"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manifest{Records: []Record{{Key: key, Image: config}}}
+	if err := c.Commit(ctx, m); err != nil {
+		t.Fatalf("Error when committing manifest: %s", err)
+	}
+
+	read, err := c.Manifest(ctx)
+	if err != nil {
+		t.Fatalf("Error when reading manifest: %s", err)
+	}
+	if len(read.Records) != 1 || read.Records[0].Key != key || read.Records[0].Image != config {
+		t.Fatalf("Unexpected manifest: %+v", read)
+	}
+
+	rc, err := c.Open(ctx, config.Digest)
+	if err != nil {
+		t.Fatalf("Error when opening blob: %s", err)
+	}
+	p, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(p) != "{}" {
+		t.Fatalf("Unexpected blob content %q, %v", p, err)
+	}
+
+	// the blobs the manifest does not refer to are removed
+	if has, err := c.Has(ctx, stale.Digest); err != nil || has {
+		t.Fatalf("The cache should not have blob %s anymore, got %v, %v", stale.Digest, has, err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	cfg := &container.Config{Cmd: []string{"/bin/sh", "-c", "make"}}
+	key, err := Key("sha256:parent", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := Key("sha256:parent", &container.Config{Cmd: []string{"/bin/sh", "-c", "make"}}); other != key {
+		t.Fatalf("The same step should have the same key, got %s and %s", key, other)
+	}
+	if other, _ := Key("sha256:other", cfg); other == key {
+		t.Fatal("Steps with different parents should have different keys")
+	}
+	if other, _ := Key("sha256:parent", &container.Config{Cmd: []string{"/bin/sh", "-c", "make test"}}); other == key {
+		t.Fatal("Steps with different configurations should have different keys")
+	}
+}
@@ -0,0 +1,140 @@
+package remotecache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/net/context"
+)
+
+// registryCache is a cache stored in a repository of a registry, under a
+// tag. The tag points to an image manifest whose configuration is the cache
+// manifest and whose layers are the blobs of the cache, so that the registry
+// keeps them.
+type registryCache struct {
+	repo distribution.Repository
+	tag  string
+}
+
+// NewRegistry returns the cache stored in repo under tag.
+func NewRegistry(repo distribution.Repository, tag string) Target {
+	return &registryCache{repo: repo, tag: tag}
+}
+
+func (c *registryCache) Manifest(ctx context.Context) (*Manifest, error) {
+	desc, err := c.repo.Tags(ctx).Get(ctx, c.tag)
+	if err != nil {
+		if isManifestUnknown(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	ms, err := c.repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mfst, err := ms.Get(ctx, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := mfst.(*schema2.DeserializedManifest)
+	if !ok || m.Config.MediaType != MediaTypeManifest {
+		return nil, fmt.Errorf("%s:%s is not a build cache", c.repo.Named().Name(), c.tag)
+	}
+	p, err := c.repo.Blobs(ctx).Get(ctx, m.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var cm Manifest
+	if err := json.Unmarshal(p, &cm); err != nil {
+		return nil, err
+	}
+	return &cm, nil
+}
+
+func (c *registryCache) Open(ctx context.Context, dgst digest.Digest) (io.ReadCloser, error) {
+	return c.repo.Blobs(ctx).Open(ctx, dgst)
+}
+
+func (c *registryCache) Has(ctx context.Context, dgst digest.Digest) (bool, error) {
+	if _, err := c.repo.Blobs(ctx).Stat(ctx, dgst); err != nil {
+		if err == distribution.ErrBlobUnknown {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *registryCache) Put(ctx context.Context, mediaType string, r io.Reader) (Blob, error) {
+	w, err := c.repo.Blobs(ctx).Create(ctx)
+	if err != nil {
+		return Blob{}, err
+	}
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(io.MultiWriter(w, digester.Hash()), r)
+	if err != nil {
+		w.Cancel(ctx)
+		return Blob{}, err
+	}
+	desc, err := w.Commit(ctx, distribution.Descriptor{
+		MediaType: mediaType,
+		Digest:    digester.Digest(),
+		Size:      size,
+	})
+	if err != nil {
+		return Blob{}, err
+	}
+	return Blob{MediaType: mediaType, Digest: desc.Digest, Size: size}, nil
+}
+
+func (c *registryCache) Commit(ctx context.Context, m *Manifest) error {
+	p, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	config, err := c.repo.Blobs(ctx).Put(ctx, MediaTypeManifest, p)
+	if err != nil {
+		return err
+	}
+
+	blobs := m.Blobs()
+	layers := make([]distribution.Descriptor, 0, len(blobs))
+	for _, b := range blobs {
+		layers = append(layers, distribution.Descriptor{MediaType: b.MediaType, Digest: b.Digest, Size: b.Size})
+	}
+	mfst, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    distribution.Descriptor{MediaType: MediaTypeManifest, Digest: config.Digest, Size: config.Size},
+		Layers:    layers,
+	})
+	if err != nil {
+		return err
+	}
+	ms, err := c.repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = ms.Put(ctx, mfst, distribution.WithTag(c.tag))
+	return err
+}
+
+// isManifestUnknown reports whether err means that the tag of the cache does
+// not exist.
+func isManifestUnknown(err error) bool {
+	switch v := err.(type) {
+	case distribution.ErrTagUnknown:
+		return true
+	case errcode.Errors:
+		return len(v) > 0 && isManifestUnknown(v[0])
+	case errcode.Error:
+		return v.Code == v2.ErrorCodeManifestUnknown
+	}
+	return false
+}
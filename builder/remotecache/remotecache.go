@@ -0,0 +1,110 @@
+// Package remotecache stores the build cache outside of the daemon, so that a
+// build on another host can use it.
+//
+// The cache of a build is the list of its steps: the image each step started
+// from, the configuration the builder looked the step up with, and the image
+// it produced. It is stored as content-addressed blobs, the configurations of
+// the images and the layers the steps added, plus a manifest listing the
+// steps. Importing a cache only fetches the blobs of the steps which hit.
+package remotecache
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/opencontainers/go-digest"
+	"golang.org/x/net/context"
+)
+
+// MediaTypeManifest is the media type of a cache manifest.
+const MediaTypeManifest = "application/vnd.docker.buildcache.v1+json"
+
+// Blob describes a blob of the cache.
+type Blob struct {
+	MediaType string
+	Digest    digest.Digest
+	Size      int64
+}
+
+// Record is a step of a build.
+type Record struct {
+	// Key identifies the step, see Key.
+	Key digest.Digest
+	// Parent is the ID of the image the step started from, empty for a
+	// step of a stage starting from scratch.
+	Parent string
+	// Image is the configuration of the image the step produced. Its
+	// digest is the ID of the image.
+	Image Blob
+	// Layer is the compressed layer the step added to the image, nil if
+	// it did not add one.
+	Layer *Blob `json:",omitempty"`
+	// DiffID is the digest of the uncompressed layer.
+	DiffID digest.Digest `json:",omitempty"`
+}
+
+// Manifest lists the steps stored in a cache.
+type Manifest struct {
+	Records []Record
+}
+
+// Blobs returns the blobs the records of the manifest refer to, each once.
+func (m *Manifest) Blobs() []Blob {
+	var blobs []Blob
+	seen := make(map[digest.Digest]bool)
+	add := func(b Blob) {
+		if !seen[b.Digest] {
+			seen[b.Digest] = true
+			blobs = append(blobs, b)
+		}
+	}
+	for _, r := range m.Records {
+		add(r.Image)
+		if r.Layer != nil {
+			add(*r.Layer)
+		}
+	}
+	return blobs
+}
+
+// Key returns the key of a step starting from the image parent, which the
+// builder looked up with the configuration cfg.
+func Key(parent string, cfg *container.Config) (digest.Digest, error) {
+	p, err := json.Marshal(struct {
+		Parent string
+		Config *container.Config
+	}{parent, cfg})
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(p), nil
+}
+
+// Step is a step of a build to export: the key the builder looked it up
+// with, and the image it produced.
+type Step struct {
+	Key    digest.Digest
+	Parent string
+	Image  string
+}
+
+// Source is a cache to import.
+type Source interface {
+	// Manifest returns the manifest of the cache, nil if the cache was
+	// never exported.
+	Manifest(ctx context.Context) (*Manifest, error)
+	// Open returns the content of a blob.
+	Open(ctx context.Context, dgst digest.Digest) (io.ReadCloser, error)
+}
+
+// Target is a destination to export a cache to.
+type Target interface {
+	Source
+	// Has reports whether the target already has a blob.
+	Has(ctx context.Context, dgst digest.Digest) (bool, error)
+	// Put stores the content read from r as a blob.
+	Put(ctx context.Context, mediaType string, r io.Reader) (Blob, error)
+	// Commit stores the manifest, replacing the previous one.
+	Commit(ctx context.Context, m *Manifest) error
+}
@@ -0,0 +1,113 @@
+package remotecache
+
+import (
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Types of cache.
+const (
+	TypeLocal    = "local"
+	TypeRegistry = "registry"
+)
+
+// Spec is where to import a cache from or export it to, written as comma
+// separated key=value pairs: type=local,src=<dir> or type=local,dest=<dir>
+// for a directory of the daemon host, and type=registry,ref=<reference> for
+// a repository of a registry.
+type Spec struct {
+	Type string
+	// Path is the directory of a local cache.
+	Path string
+	// Ref is the reference a registry cache is stored under.
+	Ref string
+}
+
+// IsSpec reports whether s is a cache spec, rather than the reference of an
+// image to use as a cache source.
+func IsSpec(s string) bool {
+	return strings.HasPrefix(s, "type=")
+}
+
+// ParseSpec parses a cache spec. A local cache is read from its src, and
+// written to its dest if export is set.
+func ParseSpec(s string, export bool) (*Spec, error) {
+	fields, err := csv.NewReader(strings.NewReader(s)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache %q: %v", s, err)
+	}
+
+	pathKey := "src"
+	if export {
+		pathKey = "dest"
+	}
+	spec := &Spec{}
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid cache %q: %s must be a key=value pair", s, field)
+		}
+		key, value := strings.ToLower(parts[0]), parts[1]
+		switch key {
+		case "type":
+			spec.Type = value
+		case pathKey:
+			spec.Path = value
+		case "ref":
+			spec.Ref = value
+		default:
+			return nil, fmt.Errorf("invalid cache %q: unknown field %s", s, key)
+		}
+	}
+
+	switch spec.Type {
+	case TypeLocal:
+		if spec.Path == "" {
+			return nil, fmt.Errorf("invalid cache %q: a local cache requires %s", s, pathKey)
+		}
+		if spec.Ref != "" {
+			return nil, fmt.Errorf("invalid cache %q: ref is only valid for a registry cache", s)
+		}
+	case TypeRegistry:
+		if spec.Ref == "" {
+			return nil, fmt.Errorf("invalid cache %q: a registry cache requires ref", s)
+		}
+		if spec.Path != "" {
+			return nil, fmt.Errorf("invalid cache %q: %s is only valid for a local cache", s, pathKey)
+		}
+	case "":
+		return nil, fmt.Errorf("invalid cache %q: type is required", s)
+	default:
+		return nil, fmt.Errorf("invalid cache %q: unsupported type %s", s, spec.Type)
+	}
+	return spec, nil
+}
+
+// String formats the spec back, with dest as the key of the path of a local
+// cache if export is set.
+func (s *Spec) String(export bool) string {
+	switch s.Type {
+	case TypeLocal:
+		if export {
+			return "type=local,dest=" + s.Path
+		}
+		return "type=local,src=" + s.Path
+	default:
+		return "type=" + s.Type + ",ref=" + s.Ref
+	}
+}
+
+// Abs makes the path of a local cache absolute.
+func (s *Spec) Abs() error {
+	if s.Type != TypeLocal {
+		return nil
+	}
+	path, err := filepath.Abs(s.Path)
+	if err != nil {
+		return err
+	}
+	s.Path = path
+	return nil
+}
@@ -0,0 +1,45 @@
+package remotecache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec("type=local,src=/var/cache/build", false)
+	if err != nil {
+		t.Fatalf("Error when parsing spec: %s", err)
+	}
+	if spec.Type != TypeLocal || spec.Path != "/var/cache/build" || spec.String(false) != "type=local,src=/var/cache/build" {
+		t.Fatalf("Unexpected spec: %+v", spec)
+	}
+
+	spec, err = ParseSpec("type=registry,ref=example.com/app:cache", true)
+	if err != nil {
+		t.Fatalf("Error when parsing spec: %s", err)
+	}
+	if spec.Type != TypeRegistry || spec.Ref != "example.com/app:cache" || spec.String(true) != "type=registry,ref=example.com/app:cache" {
+		t.Fatalf("Unexpected spec: %+v", spec)
+	}
+
+	invalid := []struct {
+		spec          string
+		export        bool
+		expectedError string
+	}{
+		{"src=/cache", false, "type is required"},
+		{"type=s3,ref=bucket", false, "unsupported type"},
+		{"type=local", false, "requires src"},
+		{"type=local,src=/cache", true, "unknown field src"},
+		{"type=local,dest=/cache,ref=app", true, "only valid for a registry cache"},
+		{"type=registry", true, "requires ref"},
+		{"type=registry,ref=app,src=/cache", false, "only valid for a local cache"},
+		{"type=local,/cache", false, "must be a key=value pair"},
+	}
+	for _, test := range invalid {
+		_, err := ParseSpec(test.spec, test.export)
+		if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+			t.Fatalf("Expected error %q for %s, got %v", test.expectedError, test.spec, err)
+		}
+	}
+}
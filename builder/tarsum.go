@@ -3,7 +3,6 @@ package builder
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -158,13 +157,3 @@ func (c *tarSumContext) Remove(path string) error {
 	}
 	return os.RemoveAll(fullpath)
 }
-
-func (c *tarSumContext) Add(path string) error {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return err
-	}
-	fname := filepath.Join(c.root, filepath.Base(path))
-	err = ioutil.WriteFile(fname, data, 0755)
-	return err
-}
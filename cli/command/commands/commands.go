@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/docker/docker/cli/command"
+	"github.com/docker/docker/cli/command/builder"
 	"github.com/docker/docker/cli/command/checkpoint"
 	"github.com/docker/docker/cli/command/container"
 	"github.com/docker/docker/cli/command/image"
@@ -23,6 +24,9 @@ import (
 // AddCommands adds all the commands from cli/command to the root command
 func AddCommands(cmd *cobra.Command, dockerCli *command.DockerCli) {
 	cmd.AddCommand(
+		// builder
+		builder.NewBuilderCommand(dockerCli),
+
 		// checkpoint
 		checkpoint.NewCheckpointCommand(dockerCli),
 
@@ -0,0 +1,131 @@
+package formatter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stringid"
+	units "github.com/docker/go-units"
+)
+
+const (
+	defaultTapconPrincipalQuietFormat = "{{.ContainerID}}"
+	defaultTapconPrincipalTableFormat = "table {{.ContainerID}}\t{{.PID}}\t{{.Network}}\t{{.Address}}\t{{.Ports}}\t{{.Created}}\t{{.Status}}"
+
+	pidHeader     = "PID"
+	networkHeader = "NETWORK"
+	addressHeader = "ADDRESS"
+	configHeader  = "CONFIG"
+)
+
+// NewTapconPrincipalFormat returns a Format for rendering using a tapcon
+// principal Context
+func NewTapconPrincipalFormat(source string, quiet bool) Format {
+	switch source {
+	case TableFormatKey:
+		if quiet {
+			return defaultTapconPrincipalQuietFormat
+		}
+		return defaultTapconPrincipalTableFormat
+	case RawFormatKey:
+		if quiet {
+			return `container_id: {{.ContainerID}}`
+		}
+		return `container_id: {{.ContainerID}}\npid: {{.PID}}\nnetwork: {{.Network}}\naddress: {{.Address}}\nports: {{.Ports}}\nstatus: {{.Status}}\n`
+	}
+	return Format(source)
+}
+
+// TapconPrincipalWrite writes the context
+func TapconPrincipalWrite(ctx Context, principals []types.TapconPrincipal) error {
+	render := func(format func(subContext subContext) error) error {
+		for _, principal := range principals {
+			principalCtx := &tapconPrincipalContext{trunc: ctx.Trunc, p: principal}
+			if err := format(principalCtx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return ctx.Write(&tapconPrincipalContext{}, render)
+}
+
+type tapconPrincipalContext struct {
+	HeaderContext
+	trunc bool
+	p     types.TapconPrincipal
+}
+
+func (c *tapconPrincipalContext) MarshalJSON() ([]byte, error) {
+	return marshalJSON(c)
+}
+
+func (c *tapconPrincipalContext) ContainerID() string {
+	c.AddHeader(containerIDHeader)
+	if c.trunc {
+		return stringid.TruncateID(c.p.ContainerID)
+	}
+	return c.p.ContainerID
+}
+
+func (c *tapconPrincipalContext) PID() string {
+	c.AddHeader(pidHeader)
+	return fmt.Sprintf("%d", c.p.PID)
+}
+
+func (c *tapconPrincipalContext) ImageID() string {
+	c.AddHeader(imageIDHeader)
+	if c.trunc {
+		return stringid.TruncateID(c.p.ImageID)
+	}
+	return c.p.ImageID
+}
+
+func (c *tapconPrincipalContext) Config() string {
+	c.AddHeader(configHeader)
+	return c.p.Config
+}
+
+func (c *tapconPrincipalContext) Network() string {
+	c.AddHeader(networkHeader)
+	return c.p.Network
+}
+
+func (c *tapconPrincipalContext) Address() string {
+	c.AddHeader(addressHeader)
+	return c.p.IP
+}
+
+func (c *tapconPrincipalContext) Ports() string {
+	c.AddHeader(portsHeader)
+	return fmt.Sprintf("%d-%d", c.p.PortMin, c.p.PortMax)
+}
+
+func (c *tapconPrincipalContext) Created() string {
+	c.AddHeader(createdSinceHeader)
+	return sinceTimestamp(c.p.Created)
+}
+
+// Status is "Active", or how long ago the principal was deleted. A principal
+// the attestation service does not know about is reported with the error
+// which prevented its registration.
+func (c *tapconPrincipalContext) Status() string {
+	c.AddHeader(statusHeader)
+	switch {
+	case c.p.Deleted != "":
+		return "Deleted " + sinceTimestamp(c.p.Deleted)
+	case c.p.Error != "":
+		return "Unregistered: " + c.p.Error
+	}
+	return "Active"
+}
+
+// sinceTimestamp returns how long ago the RFC 3339 timestamp t was.
+func sinceTimestamp(t string) string {
+	ts, err := time.Parse(time.RFC3339Nano, t)
+	if err != nil {
+		return t
+	}
+	return units.HumanDuration(time.Now().UTC().Sub(ts)) + " ago"
+}
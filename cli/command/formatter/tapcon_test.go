@@ -0,0 +1,118 @@
+package formatter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/pkg/testutil/assert"
+)
+
+func TestTapconPrincipalContext(t *testing.T) {
+	containerID := stringid.GenerateRandomID()
+	created := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)
+
+	var ctx tapconPrincipalContext
+	cases := []struct {
+		principalCtx tapconPrincipalContext
+		expValue     string
+		expHeader    string
+		call         func() string
+	}{
+		{tapconPrincipalContext{
+			p:     types.TapconPrincipal{ContainerID: containerID},
+			trunc: false,
+		}, containerID, containerIDHeader, ctx.ContainerID},
+		{tapconPrincipalContext{
+			p:     types.TapconPrincipal{ContainerID: containerID},
+			trunc: true,
+		}, stringid.TruncateID(containerID), containerIDHeader, ctx.ContainerID},
+		{tapconPrincipalContext{
+			p: types.TapconPrincipal{PID: 42},
+		}, "42", pidHeader, ctx.PID},
+		{tapconPrincipalContext{
+			p: types.TapconPrincipal{IP: "172.17.0.1"},
+		}, "172.17.0.1", addressHeader, ctx.Address},
+		{tapconPrincipalContext{
+			p: types.TapconPrincipal{PortMin: 1024, PortMax: 1279},
+		}, "1024-1279", portsHeader, ctx.Ports},
+		{tapconPrincipalContext{
+			p: types.TapconPrincipal{Created: created},
+		}, "About an hour ago", createdSinceHeader, ctx.Created},
+		{tapconPrincipalContext{
+			p: types.TapconPrincipal{Created: created},
+		}, "Active", statusHeader, ctx.Status},
+		{tapconPrincipalContext{
+			p: types.TapconPrincipal{Created: created, Deleted: created},
+		}, "Deleted About an hour ago", statusHeader, ctx.Status},
+		{tapconPrincipalContext{
+			p: types.TapconPrincipal{Created: created, Error: "connection refused"},
+		}, "Unregistered: connection refused", statusHeader, ctx.Status},
+	}
+
+	for _, c := range cases {
+		ctx = c.principalCtx
+		v := c.call()
+		if v != c.expValue {
+			t.Fatalf("Expected %s, was %s\n", c.expValue, v)
+		}
+
+		h := ctx.FullHeader()
+		if h != c.expHeader {
+			t.Fatalf("Expected %s, was %s\n", c.expHeader, h)
+		}
+	}
+}
+
+func TestTapconPrincipalContextWrite(t *testing.T) {
+	cases := []struct {
+		context  Context
+		expected string
+	}{
+		// Table format
+		{
+			Context{Format: NewTapconPrincipalFormat("table {{.ContainerID}}\t{{.PID}}\t{{.Network}}\t{{.Address}}\t{{.Ports}}", false)},
+			`CONTAINER ID        PID                 NETWORK             ADDRESS             PORTS
+containerID1        42                  bridge              172.17.0.1          1024-1279
+containerID2        43                  overnet             10.0.0.3            1280-1535
+`,
+		},
+		{
+			Context{Format: NewTapconPrincipalFormat("table", true)},
+			`containerID1
+containerID2
+`,
+		},
+		// Raw Format
+		{
+			Context{Format: NewTapconPrincipalFormat("raw", true)},
+			`container_id: containerID1
+container_id: containerID2
+`,
+		},
+		// Custom Format
+		{
+			Context{Format: NewTapconPrincipalFormat("{{.Address}}:{{.Ports}}", false)},
+			`172.17.0.1:1024-1279
+10.0.0.3:1280-1535
+`,
+		},
+	}
+
+	for _, testcase := range cases {
+		principals := []types.TapconPrincipal{
+			{ContainerID: "containerID1", PID: 42, Network: "bridge", IP: "172.17.0.1", PortMin: 1024, PortMax: 1279},
+			{ContainerID: "containerID2", PID: 43, Network: "overnet", IP: "10.0.0.3", PortMin: 1280, PortMax: 1535},
+		}
+		out := bytes.NewBufferString("")
+		testcase.context.Output = out
+		err := TapconPrincipalWrite(testcase.context, principals)
+		if err != nil {
+			assert.Error(t, err, testcase.expected)
+		} else {
+			assert.Equal(t, out.String(), testcase.expected)
+		}
+	}
+}
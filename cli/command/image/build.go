@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -55,10 +56,19 @@ type buildOptions struct {
 	forceRm        bool
 	pull           bool
 	cacheFrom      []string
+	cacheTo        []string
 	compress       bool
 	securityOpt    []string
 	networkMode    string
 	squash         bool
+	trace          bool
+	gitIdentity    bool
+	target         string
+	lockFile       string
+	secrets        []string
+	parallelism    int
+	reproducible   string
+	progress       string
 }
 
 // NewBuildCommand creates a new `docker build` command
@@ -103,7 +113,9 @@ func NewBuildCommand(dockerCli *command.DockerCli) *cobra.Command {
 	flags.BoolVar(&options.forceRm, "force-rm", false, "Always remove intermediate containers")
 	flags.BoolVarP(&options.quiet, "quiet", "q", false, "Suppress the build output and print image ID on success")
 	flags.BoolVar(&options.pull, "pull", false, "Always attempt to pull a newer version of the image")
-	flags.StringSliceVar(&options.cacheFrom, "cache-from", []string{}, "Images to consider as cache sources")
+	flags.StringArrayVar(&options.cacheFrom, "cache-from", []string{}, "Images, or caches exported by other builds (type=local,src=dir|type=registry,ref=name), to consider as cache sources")
+	flags.StringArrayVar(&options.cacheTo, "cache-to", []string{}, "Export the build cache (type=local,dest=dir|type=registry,ref=name)")
+	flags.SetAnnotation("cache-to", "version", []string{"1.26"})
 	flags.BoolVar(&options.compress, "compress", false, "Compress the build context using gzip")
 	flags.StringSliceVar(&options.securityOpt, "security-opt", []string{}, "Security options")
 	flags.StringVar(&options.networkMode, "network", "default", "Set the networking mode for the RUN instructions during build")
@@ -114,6 +126,24 @@ func NewBuildCommand(dockerCli *command.DockerCli) *cobra.Command {
 	flags.SetAnnotation("squash", "experimental", nil)
 	flags.SetAnnotation("squash", "version", []string{"1.25"})
 
+	flags.BoolVar(&options.trace, "trace", false, "Trace the RUN instructions and attach a report of what they accessed to the image")
+	flags.SetAnnotation("trace", "version", []string{"1.26"})
+	flags.BoolVar(&options.gitIdentity, "git-identity", false, "Send only the files committed in the git checkout of the context, along with their commit")
+	flags.SetAnnotation("git-identity", "version", []string{"1.26"})
+	flags.StringVar(&options.target, "target", "", "Set the target build stage to build")
+	flags.SetAnnotation("target", "version", []string{"1.26"})
+	flags.StringVar(&options.lockFile, "lock-file", "", "Fail unless the images used by the build resolve to the digests in this file")
+	flags.SetAnnotation("lock-file", "version", []string{"1.26"})
+	flags.StringArrayVar(&options.secrets, "secret", []string{}, "Secret file to expose to the RUN instructions of the build (id=name,src=path)")
+	flags.SetAnnotation("secret", "version", []string{"1.26"})
+	flags.IntVar(&options.parallelism, "parallelism", 0, "Maximum number of build stages to run at once (default the number of CPUs of the daemon)")
+	flags.SetAnnotation("parallelism", "version", []string{"1.26"})
+	flags.StringVar(&options.reproducible, "reproducible", "", "Make the build reproducible, clamping its timestamps to this Unix time (default $SOURCE_DATE_EPOCH, or 0)")
+	flags.Lookup("reproducible").NoOptDefVal = build.SourceDateEpochEnv
+	flags.SetAnnotation("reproducible", "version", []string{"1.26"})
+	flags.StringVar(&options.progress, "progress", build.ProgressClassic, "Set type of progress output (classic, tty, json)")
+	flags.SetAnnotation("progress", "version", []string{"1.26"})
+
 	return cmd
 }
 
@@ -141,6 +171,8 @@ func runBuild(dockerCli *command.DockerCli, options buildOptions) error {
 		contextDir    string
 		tempDir       string
 		relDockerfile string
+		gitIdentity   *types.GitIdentity
+		imageLock     map[string]string
 		progBuff      io.Writer
 		buildBuff     io.Writer
 	)
@@ -155,6 +187,39 @@ func runBuild(dockerCli *command.DockerCli, options buildOptions) error {
 
 	remote := ""
 
+	if options.gitIdentity {
+		if specifiedContext == "-" || urlutil.IsURL(specifiedContext) {
+			return errors.New("--git-identity is only supported with a local build context")
+		}
+		if command.IsTrusted() {
+			return errors.New("--git-identity cannot be used with content trust, which rewrites the Dockerfile")
+		}
+	}
+
+	if options.lockFile != "" {
+		if imageLock, err = build.ReadImageLock(options.lockFile); err != nil {
+			return err
+		}
+	}
+
+	if err := build.ValidateProgress(options.progress); err != nil {
+		return err
+	}
+
+	secrets, err := build.ReadSecrets(options.secrets)
+	if err != nil {
+		return err
+	}
+
+	cacheFrom, err := build.ParseCacheFrom(options.cacheFrom)
+	if err != nil {
+		return err
+	}
+	cacheTo, err := build.ParseCacheTo(options.cacheTo)
+	if err != nil {
+		return err
+	}
+
 	switch {
 	case specifiedContext == "-":
 		buildCtx, relDockerfile, err = build.GetContextFromReader(dockerCli.In(), options.dockerfileName)
@@ -219,6 +284,30 @@ func runBuild(dockerCli *command.DockerCli, options buildOptions) error {
 			includes = append(includes, ".dockerignore", relDockerfile)
 		}
 
+		// A context with a git identity must hold exactly the files of the
+		// commit, so the tracked files are sent whatever .dockerignore says.
+		if options.gitIdentity {
+			var files []string
+			gitIdentity, files, err = build.GetGitIdentity(contextDir)
+			if err != nil {
+				return fmt.Errorf("unable to get the git identity of the context: %v", err)
+			}
+			if gitIdentity.Dirty {
+				return errors.New("the git checkout of the context has uncommitted changes")
+			}
+			tracked := false
+			for _, file := range files {
+				if file == relDockerfile {
+					tracked = true
+					break
+				}
+			}
+			if !tracked {
+				return fmt.Errorf("the Dockerfile %s is not committed in the git checkout of the context", relDockerfile)
+			}
+			includes, excludes = files, nil
+		}
+
 		compression := archive.Uncompressed
 		if options.compress {
 			compression = archive.Gzip
@@ -308,10 +397,28 @@ func runBuild(dockerCli *command.DockerCli, options buildOptions) error {
 		BuildArgs:      runconfigopts.ConvertKVStringsToMapWithNil(options.buildArgs.GetAll()),
 		AuthConfigs:    authConfigs,
 		Labels:         runconfigopts.ConvertKVStringsToMap(options.labels.GetAll()),
-		CacheFrom:      options.cacheFrom,
+		CacheFrom:      cacheFrom,
+		CacheTo:        cacheTo,
 		SecurityOpt:    options.securityOpt,
 		NetworkMode:    options.networkMode,
 		Squash:         options.squash,
+		Trace:          options.trace,
+		GitIdentity:    gitIdentity,
+		Target:         options.target,
+		ImageLock:      imageLock,
+		Secrets:        secrets,
+		Parallelism:    options.parallelism,
+	}
+
+	if options.reproducible != "" {
+		buildOptions.Reproducible = true
+		if buildOptions.SourceDateEpoch, err = build.ParseSourceDateEpoch(options.reproducible); err != nil {
+			return err
+		}
+	}
+	// the output of a quiet build is not shown
+	if options.progress != build.ProgressClassic && !options.quiet {
+		buildOptions.Progress = types.BuildProgressJSON
 	}
 
 	if remote != "" {
@@ -327,7 +434,11 @@ func runBuild(dockerCli *command.DockerCli, options buildOptions) error {
 	}
 	defer response.Body.Close()
 
-	err = jsonmessage.DisplayJSONMessagesStream(response.Body, buildBuff, dockerCli.Out().FD(), dockerCli.Out().IsTerminal(), nil)
+	if buildOptions.Progress == types.BuildProgressJSON {
+		err = build.DisplayProgress(response.Body, buildBuff, dockerCli.Out().FD(), dockerCli.Out().IsTerminal(), options.progress)
+	} else {
+		err = jsonmessage.DisplayJSONMessagesStream(response.Body, buildBuff, dockerCli.Out().FD(), dockerCli.Out().IsTerminal(), nil)
+	}
 	if err != nil {
 		if jerr, ok := err.(*jsonmessage.JSONError); ok {
 			// If no error code is set, default to 1
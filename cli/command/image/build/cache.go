@@ -0,0 +1,52 @@
+package build
+
+import (
+	"strings"
+
+	"github.com/docker/docker/builder/remotecache"
+)
+
+// ParseCacheFrom returns the cache sources given with --cache-from. A value
+// starting with type= is a cache exported by another build; any other value
+// is a comma separated list of images.
+func ParseCacheFrom(values []string) ([]string, error) {
+	var sources []string
+	for _, value := range values {
+		if !remotecache.IsSpec(value) {
+			sources = append(sources, strings.Split(value, ",")...)
+			continue
+		}
+		spec, err := resolveCacheSpec(value, false)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, spec)
+	}
+	return sources, nil
+}
+
+// ParseCacheTo returns the cache exports given with --cache-to.
+func ParseCacheTo(values []string) ([]string, error) {
+	var exports []string
+	for _, value := range values {
+		spec, err := resolveCacheSpec(value, true)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, spec)
+	}
+	return exports, nil
+}
+
+// resolveCacheSpec validates a cache spec and makes the directory of a local
+// cache absolute.
+func resolveCacheSpec(value string, export bool) (string, error) {
+	spec, err := remotecache.ParseSpec(value, export)
+	if err != nil {
+		return "", err
+	}
+	if err := spec.Abs(); err != nil {
+		return "", err
+	}
+	return spec.String(export), nil
+}
@@ -0,0 +1,40 @@
+package build
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCacheFrom(t *testing.T) {
+	sources, err := ParseCacheFrom([]string{"busybox,alpine", "type=local,src=/cache", "type=registry,ref=example.com/app:cache"})
+	if err != nil {
+		t.Fatalf("Error when parsing cache sources: %s", err)
+	}
+	expected := []string{"busybox", "alpine", "type=local,src=/cache", "type=registry,ref=example.com/app:cache"}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Fatalf("Expected cache sources %v, got %v", expected, sources)
+	}
+
+	if _, err := ParseCacheFrom([]string{"type=local,dest=/cache"}); err == nil {
+		t.Fatal("Importing a local cache from dest should fail")
+	}
+}
+
+func TestParseCacheTo(t *testing.T) {
+	exports, err := ParseCacheTo([]string{"type=local,dest=cache"})
+	if err != nil {
+		t.Fatalf("Error when parsing cache exports: %s", err)
+	}
+	abs, err := filepath.Abs("cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "type=local,dest=" + abs; len(exports) != 1 || exports[0] != expected {
+		t.Fatalf("Expected cache exports [%s], got %v", expected, exports)
+	}
+
+	if _, err := ParseCacheTo([]string{"busybox"}); err == nil {
+		t.Fatal("Exporting to an image should fail")
+	}
+}
@@ -0,0 +1,94 @@
+package build
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/gitutils"
+)
+
+// GetGitIdentity returns the identity of the git checkout containing
+// contextDir, along with the files of the context which are tracked by git,
+// relative to contextDir. The daemon trusts a context made of exactly these
+// files if they are the ones committed in HEAD.
+func GetGitIdentity(contextDir string) (*types.GitIdentity, []string, error) {
+	out, err := gitOutput(contextDir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not in a git checkout: %v", contextDir, err)
+	}
+	identity := &types.GitIdentity{
+		Subdir: strings.TrimSpace(string(out)),
+	}
+
+	if out, err = gitOutput(contextDir, "config", "--get", "remote.origin.url"); err != nil {
+		return nil, nil, errors.New("the git checkout has no origin remote")
+	}
+	identity.Remote = strings.TrimSpace(string(out))
+
+	if out, err = gitOutput(contextDir, "rev-parse", "HEAD"); err != nil {
+		return nil, nil, err
+	}
+	identity.Commit = strings.TrimSpace(string(out))
+	if identity.CommitObject, err = gitOutput(contextDir, "cat-file", "commit", identity.Commit); err != nil {
+		return nil, nil, err
+	}
+	if identity.Tree, err = gitutils.CommitTree(identity.CommitObject); err != nil {
+		return nil, nil, err
+	}
+
+	// the trees from the root of the checkout down to the parent of the
+	// context
+	var dirs []string
+	if subdir := strings.Trim(identity.Subdir, "/"); subdir != "" {
+		dirs = strings.Split(subdir, "/")
+	}
+	for i := range dirs {
+		rev := identity.Commit + ":" + path.Join(dirs[:i]...)
+		tree, err := gitOutput(contextDir, "cat-file", "tree", rev)
+		if err != nil {
+			return nil, nil, err
+		}
+		identity.Trees = append(identity.Trees, tree)
+	}
+
+	if out, err = gitOutput(contextDir, "status", "--porcelain", "--untracked-files=all", "--", "."); err != nil {
+		return nil, nil, err
+	}
+	identity.Dirty = len(out) > 0
+
+	if out, err = gitOutput(contextDir, "ls-files", "--stage", "-z"); err != nil {
+		return nil, nil, err
+	}
+	var files []string
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		if len(entry) == 0 {
+			continue
+		}
+		// <mode> <hash> <stage>\t<path>
+		fields := strings.SplitN(string(entry), "\t", 2)
+		if len(fields) != 2 {
+			return nil, nil, fmt.Errorf("unexpected output of git ls-files: %q", entry)
+		}
+		if strings.HasPrefix(fields[0], gitutils.ModeSubmodule+" ") {
+			return nil, nil, fmt.Errorf("submodule %s cannot be part of a context with a git identity", fields[1])
+		}
+		files = append(files, fields[1])
+	}
+	return identity, files, nil
+}
+
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
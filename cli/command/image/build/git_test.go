@@ -0,0 +1,76 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v (%s)", args, err, out)
+	}
+}
+
+func TestGetGitIdentity(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root, err := ioutil.TempDir("", "docker-build-git-identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	runGit(t, root, "init")
+	runGit(t, root, "config", "user.email", "test@docker.com")
+	runGit(t, root, "config", "user.name", "Docker test")
+	createTestTempFile(t, root, "README", "readme", 0644)
+	contextDir := filepath.Join(root, "app")
+	if err := os.MkdirAll(filepath.Join(contextDir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	createTestTempFile(t, contextDir, "Dockerfile", "FROM scratch\n", 0644)
+	createTestTempFile(t, filepath.Join(contextDir, "src"), "main.go", "package main\n", 0644)
+	runGit(t, root, "add", ".")
+	runGit(t, root, "commit", "-m", "initial")
+
+	if _, _, err := GetGitIdentity(contextDir); err == nil {
+		t.Fatal("expected an error for a checkout without origin remote")
+	}
+	runGit(t, root, "remote", "add", "origin", "https://example.com/app.git")
+
+	identity, files, err := GetGitIdentity(contextDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Remote != "https://example.com/app.git" {
+		t.Fatalf("unexpected remote %q", identity.Remote)
+	}
+	if identity.Subdir != "app/" {
+		t.Fatalf("unexpected subdir %q", identity.Subdir)
+	}
+	if len(identity.Commit) != 40 || identity.Tree == "" {
+		t.Fatalf("unexpected commit %q and tree %q", identity.Commit, identity.Tree)
+	}
+	if len(identity.Trees) != 1 {
+		t.Fatalf("expected the root tree only, got %d trees", len(identity.Trees))
+	}
+	if identity.Dirty {
+		t.Fatal("expected a clean checkout")
+	}
+	if expected := []string{"Dockerfile", "src/main.go"}; !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected files %v, got %v", expected, files)
+	}
+
+	createTestTempFile(t, contextDir, "untracked", "new", 0644)
+	if identity, _, err = GetGitIdentity(contextDir); err != nil {
+		t.Fatal(err)
+	}
+	if !identity.Dirty {
+		t.Fatal("expected a dirty checkout")
+	}
+}
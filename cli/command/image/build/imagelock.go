@@ -0,0 +1,28 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ReadImageLock reads a lock file mapping the references of the images a
+// build uses to the digests they must resolve to, such as
+//
+//	{
+//	    "golang:1.7": "sha256:6765038c2b8f407fd6e3ecea043b44580c229ccfa2a13f6d85866cf2b4a9628e",
+//	    "busybox": "sha256:817a12c32a39bbe394944ba49de563e085f1d3c5266eb8e9723256bc4448680e"
+//	}
+func ReadImageLock(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lock map[string]string
+	if err := json.NewDecoder(f).Decode(&lock); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %v", path, err)
+	}
+	return lock, nil
+}
@@ -0,0 +1,41 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadImageLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image-lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "images.lock")
+	content := `{"busybox": "sha256:817a12c32a39bbe394944ba49de563e085f1d3c5266eb8e9723256bc4448680e"}`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := ReadImageLock(path)
+	if err != nil {
+		t.Fatalf("Error reading the lock file: %v", err)
+	}
+	if len(lock) != 1 || lock["busybox"] != "sha256:817a12c32a39bbe394944ba49de563e085f1d3c5266eb8e9723256bc4448680e" {
+		t.Fatalf("Unexpected lock: %v", lock)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`["busybox"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadImageLock(path); err == nil || !strings.Contains(err.Error(), "invalid lock file") {
+		t.Fatalf("Expected an invalid lock file error, got %v", err)
+	}
+
+	if _, err := ReadImageLock(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatalf("Expected a not exist error, got %v", err)
+	}
+}
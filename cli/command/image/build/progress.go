@@ -0,0 +1,282 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/pkg/term"
+	units "github.com/docker/go-units"
+)
+
+// Formats of the progress of a build given with --progress.
+const (
+	// ProgressClassic is the text output of the daemon.
+	ProgressClassic = "classic"
+	// ProgressTTY shows a line per step, updated while the step runs, when
+	// the output is a terminal, and the classic text otherwise.
+	ProgressTTY = "tty"
+	// ProgressJSON writes the events of the build as JSON, one per line.
+	ProgressJSON = "json"
+)
+
+// ValidateProgress checks the progress format given with --progress.
+func ValidateProgress(format string) error {
+	switch format {
+	case ProgressClassic, ProgressTTY, ProgressJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid progress format %q: must be %s, %s or %s", format, ProgressClassic, ProgressTTY, ProgressJSON)
+}
+
+// DisplayProgress displays the messages of a build started with the json
+// progress format, read from in, to out in the progress format. Like
+// jsonmessage.DisplayJSONMessagesStream, it returns the error the build
+// failed with.
+func DisplayProgress(in io.Reader, out io.Writer, terminalFd uintptr, isTerminal bool, format string) error {
+	switch {
+	case format == ProgressJSON:
+		return displayJSON(in, out)
+	case format == ProgressTTY && isTerminal:
+		width := 80
+		if ws, err := term.GetWinsize(terminalFd); err == nil && ws.Width > 0 {
+			width = int(ws.Width)
+		}
+		return displayTTY(in, out, width)
+	}
+	return jsonmessage.DisplayJSONMessagesStream(in, out, terminalFd, isTerminal, func(aux *json.RawMessage) {
+		if ev, ok := decodeEvent(aux); ok {
+			writeClassic(out, ev)
+		}
+	})
+}
+
+// decodeEvent returns the build event carried by the aux data of a message.
+func decodeEvent(aux *json.RawMessage) (*types.BuildEvent, bool) {
+	var ev types.BuildEvent
+	if err := json.Unmarshal(*aux, &ev); err != nil || ev.Type == "" {
+		return nil, false
+	}
+	return &ev, true
+}
+
+// writeClassic writes an event as the daemon writes the progress of a build
+// in its text output.
+func writeClassic(out io.Writer, ev *types.BuildEvent) {
+	switch ev.Type {
+	case types.BuildEventStepStart:
+		fmt.Fprintf(out, "Step %d/%d : %s\n", ev.Step, ev.Steps, ev.Instruction)
+	case types.BuildEventCache:
+		if ev.Cached {
+			fmt.Fprint(out, " ---> Using cache\n")
+		}
+	case types.BuildEventLog, types.BuildEventMessage:
+		if ev.Stream == "stderr" {
+			fmt.Fprint(out, "\033[91m"+ev.Data+"\033[0m")
+		} else {
+			fmt.Fprint(out, ev.Data)
+		}
+	case types.BuildEventStepDone:
+		fmt.Fprintf(out, " ---> %s\n", stringid.TruncateID(ev.ImageID))
+	case types.BuildEventDone:
+		fmt.Fprintf(out, "Successfully built %s\n", stringid.TruncateID(ev.ImageID))
+	}
+}
+
+// displayJSON writes the events of a build to out, one per line. The other
+// messages, such as the progress of the pulls, are written as message
+// events.
+func displayJSON(in io.Reader, out io.Writer) error {
+	dec := json.NewDecoder(in)
+	enc := json.NewEncoder(out)
+	for {
+		var jm jsonmessage.JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if jm.Error != nil {
+			return jm.Error
+		}
+
+		var ev *types.BuildEvent
+		if jm.Aux != nil {
+			var ok bool
+			if ev, ok = decodeEvent(jm.Aux); !ok {
+				continue
+			}
+		} else {
+			var text bytes.Buffer
+			if err := jm.Display(&text, false); err != nil {
+				return err
+			}
+			if text.Len() == 0 {
+				continue
+			}
+			ev = &types.BuildEvent{
+				Type:     types.BuildEventMessage,
+				Stream:   "stdout",
+				Data:     text.String(),
+				TimeNano: time.Now().UnixNano(),
+			}
+		}
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// ttyDisplay shows a line per step. The line of the step running is updated
+// with the last line of its output, and the whole output of a step is only
+// shown if it fails.
+type ttyDisplay struct {
+	out     io.Writer
+	width   int
+	current *types.BuildEvent // start of the step running
+	cached  bool
+	logs    bytes.Buffer // output of the step running
+	status  string       // line of the step running, as displayed
+}
+
+func displayTTY(in io.Reader, out io.Writer, width int) error {
+	d := &ttyDisplay{out: out, width: width}
+	defer d.clearStatus()
+
+	dec := json.NewDecoder(in)
+	for {
+		var jm jsonmessage.JSONMessage
+		if err := dec.Decode(&jm); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if jm.Error != nil {
+			return jm.Error
+		}
+		if jm.Aux == nil {
+			// such as the progress of a pull, shown above the step
+			status := d.status
+			d.clearStatus()
+			if err := jm.Display(out, true); err != nil {
+				return err
+			}
+			if status != "" && (jm.Progress == nil || jm.Progress.String() == "") {
+				d.setStatus(status)
+			}
+			continue
+		}
+		if ev, ok := decodeEvent(jm.Aux); ok {
+			d.event(ev)
+		}
+	}
+}
+
+func (d *ttyDisplay) event(ev *types.BuildEvent) {
+	switch ev.Type {
+	case types.BuildEventStepStart:
+		d.current, d.cached = ev, false
+		d.logs.Reset()
+		d.setStatus(d.stepLine(""))
+	case types.BuildEventCache:
+		d.cached = ev.Cached
+	case types.BuildEventLog, types.BuildEventMessage:
+		if ev.Step == 0 {
+			d.println(strings.TrimSuffix(ev.Data, "\n"))
+			return
+		}
+		if d.current == nil {
+			// such as the removal of the container of a step done
+			return
+		}
+		d.logs.WriteString(ev.Data)
+		if ev.Type == types.BuildEventLog {
+			if line := lastLine(ev.Data); line != "" {
+				d.setStatus(d.stepLine(line))
+			}
+		}
+	case types.BuildEventStepDone:
+		if d.current == nil {
+			return
+		}
+		result := "DONE " + formatDuration(ev.Duration)
+		if d.cached {
+			result = "CACHED"
+		}
+		if ev.Size > 0 {
+			result += ", " + units.HumanSize(float64(ev.Size))
+		}
+		line := d.stepLine("") + "  " + result
+		d.current = nil
+		d.println(line)
+	case types.BuildEventError:
+		if d.current == nil {
+			return
+		}
+		line := fmt.Sprintf("%s  ERROR (Dockerfile line %d)", d.stepLine(""), ev.Line)
+		d.current = nil
+		d.println(line)
+		fmt.Fprint(d.out, d.logs.String())
+	case types.BuildEventDone:
+		d.println("Successfully built " + stringid.TruncateID(ev.ImageID))
+	}
+}
+
+// stepLine returns the line of the step running, followed by a line of its
+// output, cut to the width of the terminal.
+func (d *ttyDisplay) stepLine(output string) string {
+	line := fmt.Sprintf("[%d/%d] %s", d.current.Step, d.current.Steps, d.current.Instruction)
+	if output != "" {
+		line += " | " + output
+	}
+	if r := []rune(line); len(r) > d.width-1 {
+		line = string(r[:d.width-1])
+	}
+	return line
+}
+
+// setStatus replaces the line of the step running.
+func (d *ttyDisplay) setStatus(status string) {
+	d.status = status
+	fmt.Fprintf(d.out, "\r%c[2K%s", 27, status)
+}
+
+func (d *ttyDisplay) clearStatus() {
+	if d.status != "" {
+		fmt.Fprintf(d.out, "\r%c[2K", 27)
+		d.status = ""
+	}
+}
+
+// println writes a line above the line of the step running.
+func (d *ttyDisplay) println(line string) {
+	status := d.status
+	d.clearStatus()
+	fmt.Fprintln(d.out, line)
+	if d.current != nil {
+		d.setStatus(status)
+	}
+}
+
+// lastLine returns the last line of output which is not blank.
+func lastLine(output string) string {
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
@@ -0,0 +1,133 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// buildStream returns the stream of a build which sent the events, and a
+// status message before them.
+func buildStream(t *testing.T, events ...types.BuildEvent) *bytes.Buffer {
+	stream := &bytes.Buffer{}
+	enc := json.NewEncoder(stream)
+	if err := enc.Encode(jsonmessage.JSONMessage{Stream: "Sending build context\n"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aux := json.RawMessage(data)
+		if err := enc.Encode(jsonmessage.JSONMessage{Aux: &aux}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stream
+}
+
+var progressEvents = []types.BuildEvent{
+	{Type: types.BuildEventStepStart, Step: 1, Steps: 2, Instruction: "FROM busybox", Line: 1},
+	{Type: types.BuildEventStepDone, Step: 1, ImageID: "sha256:0123456789abcdef"},
+	{Type: types.BuildEventStepStart, Step: 2, Steps: 2, Instruction: "RUN make", Line: 2},
+	{Type: types.BuildEventCache, Step: 2, CacheKey: "sha256:key"},
+	{Type: types.BuildEventLog, Step: 2, Stream: "stdout", Data: "compiling\n"},
+	{Type: types.BuildEventStepDone, Step: 2, ImageID: "sha256:fedcba9876543210", Size: 2048, Duration: 1500 * time.Millisecond},
+	{Type: types.BuildEventDone, ImageID: "sha256:fedcba9876543210"},
+}
+
+func TestValidateProgress(t *testing.T) {
+	for _, format := range []string{ProgressClassic, ProgressTTY, ProgressJSON} {
+		if err := ValidateProgress(format); err != nil {
+			t.Fatalf("Expected %s to be valid, got %v", format, err)
+		}
+	}
+	if err := ValidateProgress("plain"); err == nil {
+		t.Fatal("Expected an error for an unknown progress format")
+	}
+}
+
+func TestDisplayProgressClassic(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := DisplayProgress(buildStream(t, progressEvents...), out, 0, false, ProgressTTY); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Sending build context\n" +
+		"Step 1/2 : FROM busybox\n ---> 0123456789ab\n" +
+		"Step 2/2 : RUN make\ncompiling\n ---> fedcba987654\n" +
+		"Successfully built fedcba987654\n"
+	if out.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestDisplayProgressJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := DisplayProgress(buildStream(t, progressEvents...), out, 0, false, ProgressJSON); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(progressEvents)+1 {
+		t.Fatalf("Expected an event per line, got %q", out.String())
+	}
+	var message types.BuildEvent
+	if err := json.Unmarshal([]byte(lines[0]), &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Type != types.BuildEventMessage || message.Data != "Sending build context\n" {
+		t.Fatalf("Expected the status to be sent as a message event, got %+v", message)
+	}
+	for i, line := range lines[1:] {
+		var ev types.BuildEvent
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev != progressEvents[i] {
+			t.Fatalf("Expected event %+v, got %+v", progressEvents[i], ev)
+		}
+	}
+}
+
+func TestDisplayProgressTTY(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := displayTTY(buildStream(t, progressEvents...), out, 80); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"[1/2] FROM busybox  DONE 0.0s\n",
+		"[2/2] RUN make | compiling",
+		"[2/2] RUN make  DONE 1.5s, 2.048 kB\n",
+		"Successfully built fedcba987654\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Expected %q in %q", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "compiling\n") {
+		t.Fatalf("Expected the output of a step done to be hidden, got %q", out.String())
+	}
+}
+
+func TestDisplayProgressTTYError(t *testing.T) {
+	stream := buildStream(t,
+		types.BuildEvent{Type: types.BuildEventStepStart, Step: 1, Steps: 1, Instruction: "RUN make", Line: 3},
+		types.BuildEvent{Type: types.BuildEventLog, Step: 1, Stream: "stderr", Data: "no rule\n"},
+		types.BuildEvent{Type: types.BuildEventError, Step: 1, Line: 3, Error: "exit code 2"},
+	)
+	json.NewEncoder(stream).Encode(jsonmessage.JSONMessage{Error: &jsonmessage.JSONError{Message: "exit code 2"}})
+
+	out := &bytes.Buffer{}
+	err := displayTTY(stream, out, 80)
+	if err == nil || err.Error() != "exit code 2" {
+		t.Fatalf("Expected the error of the build, got %v", err)
+	}
+	if !strings.Contains(out.String(), "[1/1] RUN make  ERROR (Dockerfile line 3)\nno rule\n") {
+		t.Fatalf("Expected the failed step and its output, got %q", out.String())
+	}
+}
@@ -0,0 +1,28 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// SourceDateEpochEnv is the environment variable holding the Unix time the
+// timestamps of a reproducible build are clamped to, when --reproducible is
+// given no value.
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// ParseSourceDateEpoch returns the Unix time given with --reproducible. The
+// value SOURCE_DATE_EPOCH stands for the variable of the environment, which
+// defaults to 0 if it is not set.
+func ParseSourceDateEpoch(value string) (int64, error) {
+	if value == SourceDateEpochEnv {
+		if value = os.Getenv(SourceDateEpochEnv); value == "" {
+			return 0, nil
+		}
+	}
+	epoch, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || epoch < 0 {
+		return 0, fmt.Errorf("invalid source date epoch %q: must be a number of seconds since the Unix epoch", value)
+	}
+	return epoch, nil
+}
@@ -0,0 +1,29 @@
+package build
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseSourceDateEpoch(t *testing.T) {
+	defer os.Setenv(SourceDateEpochEnv, os.Getenv(SourceDateEpochEnv))
+
+	os.Unsetenv(SourceDateEpochEnv)
+	if epoch, err := ParseSourceDateEpoch(SourceDateEpochEnv); err != nil || epoch != 0 {
+		t.Fatalf("Expected 0 without %s, got %d, %v", SourceDateEpochEnv, epoch, err)
+	}
+
+	os.Setenv(SourceDateEpochEnv, "1500000000")
+	if epoch, err := ParseSourceDateEpoch(SourceDateEpochEnv); err != nil || epoch != 1500000000 {
+		t.Fatalf("Expected the value of %s, got %d, %v", SourceDateEpochEnv, epoch, err)
+	}
+	if epoch, err := ParseSourceDateEpoch("42"); err != nil || epoch != 42 {
+		t.Fatalf("Expected 42, got %d, %v", epoch, err)
+	}
+
+	for _, value := range []string{"yesterday", "-1", "1.5"} {
+		if _, err := ParseSourceDateEpoch(value); err == nil {
+			t.Fatalf("Parsing %q should fail", value)
+		}
+	}
+}
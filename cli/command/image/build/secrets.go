@@ -0,0 +1,79 @@
+package build
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSecretSize is the largest size of a build secret. Secrets are sent in a
+// request header, so they must stay small.
+const maxSecretSize = 500 * 1024
+
+// ReadSecrets reads the files of the build secrets given with --secret, as
+// id=name,src=path, and returns their content by id.
+func ReadSecrets(specs []string) (map[string][]byte, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	secrets := make(map[string][]byte)
+	for _, spec := range specs {
+		id, src, err := parseSecret(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := secrets[id]; ok {
+			return nil, fmt.Errorf("duplicate secret id %s", id)
+		}
+
+		fi, err := os.Stat(src)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read secret %s: %v", id, err)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("unable to read secret %s: %s is not a regular file", id, src)
+		}
+		if fi.Size() > maxSecretSize {
+			return nil, fmt.Errorf("secret %s is larger than %d bytes", id, maxSecretSize)
+		}
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read secret %s: %v", id, err)
+		}
+		secrets[id] = data
+	}
+	return secrets, nil
+}
+
+// parseSecret parses a comma-separated id=name,src=path value. The id defaults
+// to the base name of the source file.
+func parseSecret(spec string) (id, src string, err error) {
+	fields, err := csv.NewReader(strings.NewReader(spec)).Read()
+	if err != nil {
+		return "", "", fmt.Errorf("invalid secret %q: %v", spec, err)
+	}
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("invalid field %q in secret %q, must be a key=value pair", field, spec)
+		}
+		switch strings.ToLower(parts[0]) {
+		case "id":
+			id = parts[1]
+		case "src", "source":
+			src = parts[1]
+		default:
+			return "", "", fmt.Errorf("unknown field %q in secret %q", parts[0], spec)
+		}
+	}
+	if src == "" {
+		return "", "", fmt.Errorf("secret %q requires a src", spec)
+	}
+	if id == "" {
+		id = filepath.Base(src)
+	}
+	return id, src, nil
+}
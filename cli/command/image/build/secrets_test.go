@@ -0,0 +1,56 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-secrets-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	npmrc := filepath.Join(dir, "npmrc")
+	if err := ioutil.WriteFile(npmrc, []byte("token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key := filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(key, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := ReadSecrets([]string{"id=npm,src=" + npmrc, "src=" + key})
+	if err != nil {
+		t.Fatalf("Error reading the secrets: %v", err)
+	}
+	if len(secrets) != 2 || string(secrets["npm"]) != "token" || string(secrets["key.pem"]) != "key" {
+		t.Fatalf("Unexpected secrets: %v", secrets)
+	}
+
+	big := filepath.Join(dir, "big")
+	if err := ioutil.WriteFile(big, make([]byte, maxSecretSize+1), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string]string{
+		"id=npm":                                "requires a src",
+		"id=npm,src=" + npmrc + ",mode=0400":    "unknown field",
+		"id=npm,src=" + filepath.Join(dir, "x"): "unable to read secret npm",
+		"id=big,src=" + big:                     "larger than",
+		"id=dir,src=" + dir:                     "not a regular file",
+	}
+	for spec, expected := range invalid {
+		if _, err := ReadSecrets([]string{spec}); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected an error containing %q for %s, got %v", expected, spec, err)
+		}
+	}
+
+	if _, err := ReadSecrets([]string{"id=npm,src=" + npmrc, "id=npm,src=" + key}); err == nil || !strings.Contains(err.Error(), "duplicate secret id") {
+		t.Fatalf("Expected a duplicate id error, got %v", err)
+	}
+}
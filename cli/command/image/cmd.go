@@ -27,6 +27,8 @@ func NewImageCommand(dockerCli *command.DockerCli) *cobra.Command {
 		newListCommand(dockerCli),
 		newRemoveCommand(dockerCli),
 		newInspectCommand(dockerCli),
+		newProvenanceCommand(dockerCli),
+		newTraceCommand(dockerCli),
 		NewPruneCommand(dockerCli),
 	)
 	return cmd
@@ -0,0 +1,58 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/cli"
+	"github.com/docker/docker/cli/command"
+	"github.com/docker/docker/cli/command/inspect"
+	"github.com/spf13/cobra"
+)
+
+type provenanceOptions struct {
+	format string
+	refs   []string
+}
+
+// newProvenanceCommand creates a new cobra.Command for `docker image provenance`
+func newProvenanceCommand(dockerCli *command.DockerCli) *cobra.Command {
+	var opts provenanceOptions
+
+	cmd := &cobra.Command{
+		Use:   "provenance [OPTIONS] IMAGE [IMAGE...]",
+		Short: "Display how one or more images were built",
+		Args:  cli.RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.refs = args
+			return runProvenance(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.format, "format", "f", "", "Format the output using the given Go template")
+	return cmd
+}
+
+func runProvenance(dockerCli *command.DockerCli, opts provenanceOptions) error {
+	client := dockerCli.Client()
+	ctx := context.Background()
+
+	getRefFunc := func(ref string) (interface{}, []byte, error) {
+		img, _, err := client.ImageInspectWithRaw(ctx, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		if img.Provenance == nil {
+			return nil, nil, fmt.Errorf("Image %s has no provenance", ref)
+		}
+		raw, err := json.Marshal(img.Provenance)
+		if err != nil {
+			return nil, nil, err
+		}
+		return img.Provenance, raw, nil
+	}
+	return inspect.Inspect(dockerCli.Out(), opts.refs, opts.format, getRefFunc)
+}
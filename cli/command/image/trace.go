@@ -0,0 +1,45 @@
+package image
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/cli"
+	"github.com/docker/docker/cli/command"
+	"github.com/docker/docker/cli/command/inspect"
+	"github.com/spf13/cobra"
+)
+
+type traceOptions struct {
+	format string
+	refs   []string
+}
+
+// newTraceCommand creates a new cobra.Command for `docker image trace`
+func newTraceCommand(dockerCli *command.DockerCli) *cobra.Command {
+	var opts traceOptions
+
+	cmd := &cobra.Command{
+		Use:   "trace [OPTIONS] IMAGE [IMAGE...]",
+		Short: "Display what the build of one or more images accessed",
+		Args:  cli.RequiresMinArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.refs = args
+			return runTrace(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.format, "format", "f", "", "Format the output using the given Go template")
+	return cmd
+}
+
+func runTrace(dockerCli *command.DockerCli, opts traceOptions) error {
+	client := dockerCli.Client()
+	ctx := context.Background()
+
+	getRefFunc := func(ref string) (interface{}, []byte, error) {
+		trace, err := client.ImageTrace(ctx, ref)
+		return trace, nil, err
+	}
+	return inspect.Inspect(dockerCli.Out(), opts.refs, opts.format, getRefFunc)
+}
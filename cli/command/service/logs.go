@@ -21,9 +21,11 @@ type logsOptions struct {
 	noResolve  bool
 	follow     bool
 	since      string
+	until      string
 	timestamps bool
 	details    bool
 	tail       string
+	grep       string
 
 	service string
 }
@@ -46,9 +48,13 @@ func newLogsCommand(dockerCli *command.DockerCli) *cobra.Command {
 	flags.BoolVar(&opts.noResolve, "no-resolve", false, "Do not map IDs to Names")
 	flags.BoolVarP(&opts.follow, "follow", "f", false, "Follow log output")
 	flags.StringVar(&opts.since, "since", "", "Show logs since timestamp")
+	flags.StringVar(&opts.until, "until", "", "Show logs before a timestamp")
+	flags.SetAnnotation("until", "version", []string{"1.26"})
 	flags.BoolVarP(&opts.timestamps, "timestamps", "t", false, "Show timestamps")
 	flags.BoolVar(&opts.details, "details", false, "Show extra details provided to logs")
 	flags.StringVar(&opts.tail, "tail", "all", "Number of lines to show from the end of the logs")
+	flags.StringVar(&opts.grep, "grep", "", "Only show the lines matching a regular expression")
+	flags.SetAnnotation("grep", "version", []string{"1.26"})
 	return cmd
 }
 
@@ -59,10 +65,12 @@ func runLogs(dockerCli *command.DockerCli, opts *logsOptions) error {
 		ShowStdout: true,
 		ShowStderr: true,
 		Since:      opts.since,
+		Until:      opts.until,
 		Timestamps: opts.timestamps,
 		Follow:     opts.follow,
 		Tail:       opts.tail,
 		Details:    opts.details,
+		Grep:       opts.grep,
 	}
 
 	client := dockerCli.Client()
@@ -0,0 +1,115 @@
+package system
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/cli"
+	"github.com/docker/docker/cli/command"
+	"github.com/docker/docker/cli/command/formatter"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/spf13/cobra"
+)
+
+// newTapconCommand returns a cobra command for `system tapcon` subcommands
+func newTapconCommand(dockerCli *command.DockerCli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tapcon",
+		Short: "Manage the principals registered for tapcon containers",
+		Args:  cli.NoArgs,
+		RunE:  dockerCli.ShowHelp,
+		Tags:  map[string]string{"version": "1.26"},
+	}
+	cmd.AddCommand(
+		newTapconListCommand(dockerCli),
+		newTapconReconcileCommand(dockerCli),
+	)
+	return cmd
+}
+
+type tapconListOptions struct {
+	all     bool
+	quiet   bool
+	noTrunc bool
+	format  string
+}
+
+func newTapconListCommand(dockerCli *command.DockerCli) *cobra.Command {
+	var opts tapconListOptions
+
+	cmd := &cobra.Command{
+		Use:     "ls [OPTIONS]",
+		Aliases: []string{"list"},
+		Short:   "List the principals registered with the attestation service",
+		Args:    cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTapconList(dockerCli, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&opts.all, "all", "a", false, "Show deleted principals too")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "Only display container IDs")
+	flags.BoolVar(&opts.noTrunc, "no-trunc", false, "Do not truncate the output")
+	flags.StringVar(&opts.format, "format", "", "Pretty-print principals using a Go template")
+
+	return cmd
+}
+
+func runTapconList(dockerCli *command.DockerCli, opts tapconListOptions) error {
+	principals, err := dockerCli.Client().TapconPrincipals(context.Background(), types.TapconPrincipalListOptions{All: opts.all})
+	if err != nil {
+		return err
+	}
+
+	format := opts.format
+	if len(format) == 0 {
+		format = formatter.TableFormatKey
+	}
+
+	principalsCtx := formatter.Context{
+		Output: dockerCli.Out(),
+		Format: formatter.NewTapconPrincipalFormat(format, opts.quiet),
+		Trunc:  !opts.noTrunc,
+	}
+	return formatter.TapconPrincipalWrite(principalsCtx, principals)
+}
+
+func newTapconReconcileCommand(dockerCli *command.DockerCli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reconcile",
+		Short: "Register the principals of the running tapcon containers again",
+		Long: `Register the principals of the running tapcon containers with the
+attestation service again, and delete the principals of containers which are
+no longer running. Use it when the attestation service lost its state, for
+example after an outage.`,
+		Args: cli.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTapconReconcile(dockerCli)
+		},
+	}
+}
+
+func runTapconReconcile(dockerCli *command.DockerCli) error {
+	report, err := dockerCli.Client().TapconReconcile(context.Background())
+	if err != nil {
+		return err
+	}
+
+	out := dockerCli.Out()
+	for _, p := range report.Removed {
+		fmt.Fprintf(out, "Removed principal %d of container %s\n", p.PID, stringid.TruncateID(p.ContainerID))
+	}
+	for _, p := range report.Registered {
+		fmt.Fprintf(out, "Registered principal %d of container %s on %s\n", p.PID, stringid.TruncateID(p.ContainerID), p.IP)
+	}
+	for _, p := range report.Failed {
+		fmt.Fprintf(dockerCli.Err(), "Failed to register principal %d of container %s on %s: %s\n", p.PID, stringid.TruncateID(p.ContainerID), p.IP, p.Error)
+	}
+	if len(report.Failed) > 0 {
+		return cli.StatusError{StatusCode: 1}
+	}
+	return nil
+}
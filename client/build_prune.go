@@ -0,0 +1,31 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+// BuildCachePrune requests the daemon to delete the cache mounts of builds
+// which are not in use
+func (cli *Client) BuildCachePrune(ctx context.Context) (types.BuildCachePruneReport, error) {
+	var report types.BuildCachePruneReport
+
+	if err := cli.NewVersionError("1.26", "build cache prune"); err != nil {
+		return report, err
+	}
+
+	serverResp, err := cli.post(ctx, "/build/prune", nil, nil, nil)
+	if err != nil {
+		return report, err
+	}
+	defer ensureReaderClosed(serverResp)
+
+	if err := json.NewDecoder(serverResp.body).Decode(&report); err != nil {
+		return report, fmt.Errorf("Error retrieving build cache prune report: %v", err)
+	}
+
+	return report, nil
+}
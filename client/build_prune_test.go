@@ -0,0 +1,67 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/testutil/assert"
+	"golang.org/x/net/context"
+)
+
+func TestBuildCachePruneError(t *testing.T) {
+	client := &Client{
+		client:  newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+		version: "1.26",
+	}
+
+	_, err := client.BuildCachePrune(context.Background())
+	assert.Error(t, err, "Error response from daemon: Server error")
+}
+
+func TestBuildCachePruneVersion(t *testing.T) {
+	client := &Client{
+		client:  newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+		version: "1.25",
+	}
+
+	_, err := client.BuildCachePrune(context.Background())
+	assert.Error(t, err, `"build cache prune" requires API version 1.26`)
+}
+
+func TestBuildCachePrune(t *testing.T) {
+	expectedURL := "/v1.26/build/prune"
+
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasPrefix(req.URL.Path, expectedURL) {
+				return nil, fmt.Errorf("Expected URL '%s', got '%s'", expectedURL, req.URL)
+			}
+			if req.Method != "POST" {
+				return nil, fmt.Errorf("expected POST method, got %s", req.Method)
+			}
+			content, err := json.Marshal(types.BuildCachePruneReport{
+				CachesDeleted:  []string{"gomod", "/root/.npm"},
+				SpaceReclaimed: 9999,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(content)),
+			}, nil
+		}),
+		version: "1.26",
+	}
+
+	report, err := client.BuildCachePrune(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(report.CachesDeleted), 2)
+	assert.Equal(t, report.SpaceReclaimed, uint64(9999))
+}
@@ -31,6 +31,12 @@ func TestContainerLogsError(t *testing.T) {
 	if err == nil || !strings.Contains(err.Error(), `parsing time "2006-01-02TZ"`) {
 		t.Fatalf("expected a 'parsing time' error, got %v", err)
 	}
+	_, err = client.ContainerLogs(context.Background(), "container_id", types.ContainerLogsOptions{
+		Until: "2006-01-02TZ",
+	})
+	if err == nil || !strings.Contains(err.Error(), `parsing time "2006-01-02TZ"`) {
+		t.Fatalf("expected a 'parsing time' error, got %v", err)
+	}
 }
 
 func TestContainerLogs(t *testing.T) {
@@ -80,6 +86,17 @@ func TestContainerLogs(t *testing.T) {
 				"since": "invalid but valid",
 			},
 		},
+		{
+			options: types.ContainerLogsOptions{
+				Until: "1136073600.000000001",
+				Grep:  "^ERROR",
+			},
+			expectedQueryParams: map[string]string{
+				"tail":  "",
+				"until": "1136073600.000000001",
+				"grep":  "^ERROR",
+			},
+		},
 	}
 	for _, logCase := range cases {
 		client := &Client{
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"golang.org/x/net/context"
 
@@ -29,6 +30,23 @@ func (cli *Client) ImageBuild(ctx context.Context, buildContext io.Reader, optio
 		return types.ImageBuildResponse{}, err
 	}
 	headers.Add("X-Registry-Config", base64.URLEncoding.EncodeToString(buf))
+	if options.GitIdentity != nil {
+		buf, err := json.Marshal(options.GitIdentity)
+		if err != nil {
+			return types.ImageBuildResponse{}, err
+		}
+		headers.Add("X-Docker-Git-Identity", base64.URLEncoding.EncodeToString(buf))
+	}
+	if len(options.Secrets) > 0 {
+		if err := cli.NewVersionError("1.26", "build secrets"); err != nil {
+			return types.ImageBuildResponse{}, err
+		}
+		buf, err := json.Marshal(options.Secrets)
+		if err != nil {
+			return types.ImageBuildResponse{}, err
+		}
+		headers.Add("X-Docker-Build-Secrets", base64.URLEncoding.EncodeToString(buf))
+	}
 	headers.Set("Content-Type", "application/x-tar")
 
 	serverResp, err := cli.postRaw(ctx, "/build", query, buildContext, headers)
@@ -79,6 +97,53 @@ func (cli *Client) imageBuildOptionsToQuery(options types.ImageBuildOptions) (ur
 		query.Set("squash", "1")
 	}
 
+	if options.Trace {
+		if err := cli.NewVersionError("1.26", "trace"); err != nil {
+			return query, err
+		}
+		query.Set("trace", "1")
+	}
+
+	if options.Target != "" {
+		if err := cli.NewVersionError("1.26", "target"); err != nil {
+			return query, err
+		}
+		query.Set("target", options.Target)
+	}
+
+	if options.Parallelism != 0 {
+		if err := cli.NewVersionError("1.26", "parallelism"); err != nil {
+			return query, err
+		}
+		query.Set("parallelism", strconv.Itoa(options.Parallelism))
+	}
+
+	if options.Reproducible {
+		if err := cli.NewVersionError("1.26", "reproducible"); err != nil {
+			return query, err
+		}
+		query.Set("reproducible", "1")
+		query.Set("sourcedateepoch", strconv.FormatInt(options.SourceDateEpoch, 10))
+	}
+
+	if options.Progress != "" {
+		if err := cli.NewVersionError("1.26", "progress"); err != nil {
+			return query, err
+		}
+		query.Set("progress", options.Progress)
+	}
+
+	if len(options.ImageLock) > 0 {
+		if err := cli.NewVersionError("1.26", "image lock"); err != nil {
+			return query, err
+		}
+		imageLockJSON, err := json.Marshal(options.ImageLock)
+		if err != nil {
+			return query, err
+		}
+		query.Set("imagelock", string(imageLockJSON))
+	}
+
 	if !container.Isolation.IsDefault(options.Isolation) {
 		query.Set("isolation", string(options.Isolation))
 	}
@@ -113,11 +178,29 @@ func (cli *Client) imageBuildOptionsToQuery(options types.ImageBuildOptions) (ur
 	}
 	query.Set("labels", string(labelsJSON))
 
+	for _, cacheFrom := range options.CacheFrom {
+		if strings.HasPrefix(cacheFrom, "type=") {
+			if err := cli.NewVersionError("1.26", "cache import"); err != nil {
+				return query, err
+			}
+		}
+	}
 	cacheFromJSON, err := json.Marshal(options.CacheFrom)
 	if err != nil {
 		return query, err
 	}
 	query.Set("cachefrom", string(cacheFromJSON))
 
+	if len(options.CacheTo) > 0 {
+		if err := cli.NewVersionError("1.26", "cacheto"); err != nil {
+			return query, err
+		}
+		cacheToJSON, err := json.Marshal(options.CacheTo)
+		if err != nil {
+			return query, err
+		}
+		query.Set("cacheto", string(cacheToJSON))
+	}
+
 	return query, nil
 }
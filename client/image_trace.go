@@ -0,0 +1,22 @@
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+// ImageTrace returns the trace report of the build which produced an image.
+func (cli *Client) ImageTrace(ctx context.Context, imageID string) (types.BuildTrace, error) {
+	var trace types.BuildTrace
+	serverResp, err := cli.get(ctx, "/images/"+imageID+"/trace", url.Values{}, nil)
+	if err != nil {
+		return trace, err
+	}
+
+	err = json.NewDecoder(serverResp.body).Decode(&trace)
+	ensureReaderClosed(serverResp)
+	return trace, err
+}
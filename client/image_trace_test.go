@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+func TestImageTraceError(t *testing.T) {
+	client := &Client{
+		client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	_, err := client.ImageTrace(context.Background(), "nothing")
+	if err == nil || err.Error() != "Error response from daemon: Server error" {
+		t.Fatalf("expected a Server error, got %v", err)
+	}
+}
+
+func TestImageTrace(t *testing.T) {
+	expectedURL := "/images/image_id/trace"
+	client := &Client{
+		client: newMockClient(func(r *http.Request) (*http.Response, error) {
+			if !strings.HasPrefix(r.URL.Path, expectedURL) {
+				return nil, fmt.Errorf("Expected URL '%s', got '%s'", expectedURL, r.URL)
+			}
+			b, err := json.Marshal(types.BuildTrace{
+				ImageID:  "image_id",
+				Executed: []string{"/bin/sh"},
+				Steps: []types.BuildTraceStep{
+					{
+						Step:        2,
+						Instruction: "RUN true",
+						Executed:    []string{"/bin/sh"},
+					},
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(b)),
+			}, nil
+		}),
+	}
+	trace, err := client.ImageTrace(context.Background(), "image_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.ImageID != "image_id" || len(trace.Steps) != 1 {
+		t.Fatalf("expected the trace of image_id with 1 step, got %v", trace)
+	}
+}
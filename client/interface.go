@@ -72,6 +72,7 @@ type ImageAPIClient interface {
 	ImageBuild(ctx context.Context, context io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error)
 	ImageCreate(ctx context.Context, parentReference string, options types.ImageCreateOptions) (io.ReadCloser, error)
 	ImageHistory(ctx context.Context, image string) ([]types.ImageHistory, error)
+	ImageTrace(ctx context.Context, image string) (types.BuildTrace, error)
 	ImageImport(ctx context.Context, source types.ImageImportSource, ref string, options types.ImageImportOptions) (io.ReadCloser, error)
 	ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error)
 	ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error)
@@ -83,6 +84,7 @@ type ImageAPIClient interface {
 	ImageSave(ctx context.Context, images []string) (io.ReadCloser, error)
 	ImageTag(ctx context.Context, image, ref string) error
 	ImagesPrune(ctx context.Context, pruneFilter filters.Args) (types.ImagesPruneReport, error)
+	BuildCachePrune(ctx context.Context) (types.BuildCachePruneReport, error)
 }
 
 // NetworkAPIClient defines API client methods for the networks
@@ -148,6 +150,8 @@ type SystemAPIClient interface {
 	RegistryLogin(ctx context.Context, auth types.AuthConfig) (registry.AuthenticateOKBody, error)
 	DiskUsage(ctx context.Context) (types.DiskUsage, error)
 	Ping(ctx context.Context) (types.Ping, error)
+	TapconPrincipals(ctx context.Context, options types.TapconPrincipalListOptions) ([]types.TapconPrincipal, error)
+	TapconReconcile(ctx context.Context) (types.TapconReconcileReport, error)
 }
 
 // VolumeAPIClient defines API client methods for the volumes
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+// TapconPrincipals returns the principals the daemon registered with its
+// attestation service.
+func (cli *Client) TapconPrincipals(ctx context.Context, options types.TapconPrincipalListOptions) ([]types.TapconPrincipal, error) {
+	var principals []types.TapconPrincipal
+	if err := cli.NewVersionError("1.26", "tapcon principals"); err != nil {
+		return principals, err
+	}
+
+	query := url.Values{}
+	if options.All {
+		query.Set("all", "1")
+	}
+	serverResp, err := cli.get(ctx, "/tapcon/principals", query, nil)
+	if err != nil {
+		return principals, err
+	}
+
+	err = json.NewDecoder(serverResp.body).Decode(&principals)
+	ensureReaderClosed(serverResp)
+	return principals, err
+}
+
+// TapconReconcile registers the principals of the running tapcon containers
+// with the attestation service again, and deletes the ones of containers
+// which are gone.
+func (cli *Client) TapconReconcile(ctx context.Context) (types.TapconReconcileReport, error) {
+	var report types.TapconReconcileReport
+	if err := cli.NewVersionError("1.26", "tapcon reconcile"); err != nil {
+		return report, err
+	}
+
+	serverResp, err := cli.post(ctx, "/tapcon/principals/reconcile", nil, nil, nil)
+	if err != nil {
+		return report, err
+	}
+
+	err = json.NewDecoder(serverResp.body).Decode(&report)
+	ensureReaderClosed(serverResp)
+	return report, err
+}
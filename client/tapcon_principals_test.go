@@ -0,0 +1,97 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+)
+
+func TestTapconPrincipalsError(t *testing.T) {
+	client := &Client{
+		version: "1.26",
+		client:  newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	_, err := client.TapconPrincipals(context.Background(), types.TapconPrincipalListOptions{})
+	if err == nil || err.Error() != "Error response from daemon: Server error" {
+		t.Fatalf("expected a Server error, got %v", err)
+	}
+}
+
+func TestTapconPrincipals(t *testing.T) {
+	expectedURL := "/v1.26/tapcon/principals"
+	cases := []struct {
+		options  types.TapconPrincipalListOptions
+		expected string
+	}{
+		{types.TapconPrincipalListOptions{}, ""},
+		{types.TapconPrincipalListOptions{All: true}, "1"},
+	}
+	for _, c := range cases {
+		client := &Client{
+			version: "1.26",
+			client: newMockClient(func(r *http.Request) (*http.Response, error) {
+				if r.URL.Path != expectedURL {
+					return nil, fmt.Errorf("Expected URL '%s', got '%s'", expectedURL, r.URL)
+				}
+				if all := r.URL.Query().Get("all"); all != c.expected {
+					return nil, fmt.Errorf("all not set in URL query properly. Expected '%s', got '%s'", c.expected, all)
+				}
+				b, err := json.Marshal([]types.TapconPrincipal{
+					{ContainerID: "container_id", PID: 42, IP: "172.17.0.1", PortMin: 1024, PortMax: 1279},
+				})
+				if err != nil {
+					return nil, err
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader(b)),
+				}, nil
+			}),
+		}
+		principals, err := client.TapconPrincipals(context.Background(), c.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(principals) != 1 || principals[0].PID != 42 {
+			t.Fatalf("expected principal 42, got %v", principals)
+		}
+	}
+}
+
+func TestTapconReconcile(t *testing.T) {
+	expectedURL := "/v1.26/tapcon/principals/reconcile"
+	client := &Client{
+		version: "1.26",
+		client: newMockClient(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Path != expectedURL {
+				return nil, fmt.Errorf("Expected URL '%s', got '%s'", expectedURL, r.URL)
+			}
+			if r.Method != "POST" {
+				return nil, fmt.Errorf("expected POST method, got %s", r.Method)
+			}
+			b, err := json.Marshal(types.TapconReconcileReport{
+				Registered: []types.TapconPrincipal{{ContainerID: "container_id", PID: 42}},
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(b)),
+			}, nil
+		}),
+	}
+	report, err := client.TapconReconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Registered) != 1 || len(report.Removed) != 0 || len(report.Failed) != 0 {
+		t.Fatalf("unexpected report %v", report)
+	}
+}
@@ -31,7 +31,6 @@ import (
 	cliflags "github.com/docker/docker/cli/flags"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/daemon/cluster"
-	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/libcontainerd"
 	dopts "github.com/docker/docker/opts"
@@ -48,11 +47,6 @@ import (
 	"github.com/spf13/pflag"
 )
 
-// #cgo LDFLAGS: -lport
-// #include "libport.h"
-// #include <stdlib.h>
-import "C"
-
 const (
 	flagDaemonConfigFile = "config-file"
 )
@@ -157,12 +151,6 @@ func (cli *DaemonCli) start(opts daemonOptions) (err error) {
 		return fmt.Errorf("Failed to set umask: %v", err)
 	}
 
-	if len(cli.LogConfig.Config) > 0 {
-		if err := logger.ValidateLogOpts(cli.LogConfig.Type, cli.LogConfig.Config); err != nil {
-			return fmt.Errorf("Failed to set log opts: %v", err)
-		}
-	}
-
 	// Create the daemon root before we create ANY other files (PID, or migrate keys)
 	// to ensure the appropriate ACL is set (particularly relevant on Windows)
 	if err := daemon.CreateDaemonRoot(cli.Config); err != nil {
@@ -278,8 +266,6 @@ func (cli *DaemonCli) start(opts daemonOptions) (err error) {
 			return err
 		}
 	}
-	// set up Tapcon
-	d.InitTapcon()
 
 	name, _ := os.Hostname()
 
@@ -24,6 +24,8 @@ import (
 	"github.com/docker/docker/daemon/exec"
 	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/daemon/logger/jsonfilelog"
+	"github.com/docker/docker/daemon/logger/local"
+	"github.com/docker/docker/daemon/logger/loggerutils/cache"
 	"github.com/docker/docker/daemon/network"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/layer"
@@ -38,6 +40,7 @@ import (
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/volume"
 	"github.com/docker/go-connections/nat"
+	units "github.com/docker/go-units"
 	"github.com/docker/libnetwork"
 	"github.com/docker/libnetwork/netlabel"
 	"github.com/docker/libnetwork/options"
@@ -96,6 +99,13 @@ type CommonContainer struct {
 	ExecCommands           *exec.Store                `json:"-"`
 	SecretStore            agentexec.SecretGetter     `json:"-"`
 	SecretReferences       []*swarmtypes.SecretReference
+	// TapconConfigDigest is the canonical digest of the configuration
+	// the container was last started with in tapcon mode.
+	TapconConfigDigest string `json:",omitempty"`
+	// TapconPortMin and TapconPortMax delimit the source ports allocated
+	// to the running container in tapcon mode.
+	TapconPortMin int `json:",omitempty"`
+	TapconPortMax int `json:",omitempty"`
 	// logDriver for closing
 	LogDriver      logger.Logger  `json:"-"`
 	LogCopier      *logger.Copier `json:"-"`
@@ -342,14 +352,46 @@ func (container *Container) StartLogger() (logger.Logger, error) {
 		DaemonName:          "docker",
 	}
 
-	// Set logging file for "json-logger"
-	if cfg.Type == jsonfilelog.Name {
+	// Set logging file for "json-logger" and "local"
+	switch cfg.Type {
+	case jsonfilelog.Name:
 		info.LogPath, err = container.GetRootResourcePath(fmt.Sprintf("%s-json.log", container.ID))
 		if err != nil {
 			return nil, err
 		}
+	case local.Name:
+		// the rotated log files and their indexes are kept next to the log
+		// file, in a directory of their own
+		logDir, err := container.GetRootResourcePath("local-logs")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(logDir, 0700); err != nil {
+			return nil, err
+		}
+		info.LogPath = filepath.Join(logDir, "container.log")
+	}
+	l, err := c(info)
+	if err != nil {
+		return nil, err
 	}
-	return c(info)
+
+	// the logs of the drivers which cannot read them are read from a local
+	// cache
+	if _, ok := l.(logger.LogReader); ok || !cache.ShouldUseCache(cfg.Config) {
+		return l, nil
+	}
+	info.LogPath, err = container.GetRootResourcePath("container-cached.log")
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	cl, err := cache.WithLocalCache(l, info)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	return cl, nil
 }
 
 // GetProcessLabel returns the process label for the container.
@@ -1056,16 +1098,30 @@ func (container *Container) startLogging() error {
 		return fmt.Errorf("failed to initialize logging driver: %v", err)
 	}
 
-	copier := logger.NewCopier(map[string]io.Reader{"stdout": container.StdoutPipe(), "stderr": container.StderrPipe()}, l)
-	container.LogCopier = copier
-	copier.Run()
-	container.LogDriver = l
-
 	// set LogPath field only for json-file logdriver
 	if jl, ok := l.(*jsonfilelog.JSONFileLogger); ok {
 		container.LogPath = jl.LogPath()
 	}
 
+	// in non-blocking mode, a slow log driver does not block the output of
+	// the container
+	cfg := container.HostConfig.LogConfig.Config
+	if containertypes.LogMode(cfg["mode"]) == containertypes.LogModeNonBlock {
+		var bufferSize int64
+		if s, ok := cfg["max-buffer-size"]; ok {
+			if bufferSize, err = units.RAMInBytes(s); err != nil {
+				l.Close()
+				return fmt.Errorf("failed to parse the max-buffer-size log option: %v", err)
+			}
+		}
+		l = logger.NewRingLogger(l, logger.Info{ContainerID: container.ID, ContainerName: container.Name}, bufferSize)
+	}
+
+	copier := logger.NewCopier(map[string]io.Reader{"stdout": container.StdoutPipe(), "stderr": container.StderrPipe()}, l)
+	container.LogCopier = copier
+	copier.Run()
+	container.LogDriver = l
+
 	return nil
 }
 
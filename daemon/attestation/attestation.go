@@ -0,0 +1,111 @@
+// Package attestation defines the interface used by the daemon to talk to a
+// tapcon attestation service, and a factory through which backends register
+// themselves.
+package attestation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultDaemonPath is the default location of the local attestation guard
+// socket.
+const DefaultDaemonPath = "/var/run/attguard.sock"
+
+// Principal describes a running container as seen by the attestation service.
+type Principal struct {
+	// PID identifies the principal on this host.
+	PID uint64 `json:"pid"`
+	// Image is the ID of the image the principal was started from.
+	Image string `json:"image"`
+	// Config is the digest of the configuration the principal runs with.
+	Config string `json:"config"`
+	// IP is the address the principal's traffic appears to come from.
+	IP string `json:"ip"`
+	// PortMin and PortMax delimit the source ports allocated to the principal.
+	PortMin int `json:"port_min"`
+	PortMax int `json:"port_max"`
+}
+
+// Attestor is the interface implemented by attestation backends.
+type Attestor interface {
+	// Name returns the name the backend was registered with.
+	Name() string
+	// Init connects the backend to the attestation service. An empty id
+	// lets the service decide the identity of this daemon.
+	Init(id string, runAsIaaS bool) error
+	// CreatePrincipal registers a new principal. A container attached to
+	// several networks is registered once for each of its addresses.
+	CreatePrincipal(p Principal) error
+	// DeletePrincipal removes every address of the principal identified
+	// by pid.
+	DeletePrincipal(pid uint64) error
+	// EndorseImage endorses an image with the given property.
+	EndorseImage(id, config, property string) error
+	// PostObjectACL sets the access requirement of an object.
+	PostObjectACL(id, requirement string) error
+	// AttestProperty asks whether the principal reachable at ip:port has
+	// the given property. A nil error means the property holds.
+	AttestProperty(ip string, port uint32, property string) error
+}
+
+// ImageVerifier is implemented by backends which are able to look up the
+// endorsements of an image.
+type ImageVerifier interface {
+	// VerifyImage checks that the image id was endorsed with property. A
+	// nil error means it was.
+	VerifyImage(id, property string) error
+}
+
+// Options holds the configuration passed to a backend on creation.
+type Options struct {
+	// DaemonPath is the address of the attestation service. Backends fall
+	// back to DefaultDaemonPath when it is empty.
+	DaemonPath string
+}
+
+// Creator builds an attestation backend with the given options.
+type Creator func(Options) (Attestor, error)
+
+var (
+	registry = make(map[string]Creator)
+	m        sync.Mutex
+)
+
+// Register registers an attestation backend under the given name.
+func Register(name string, c Creator) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := registry[name]; ok {
+		return fmt.Errorf("attestation: backend named '%s' is already registered", name)
+	}
+	registry[name] = c
+	return nil
+}
+
+// New creates the attestation backend registered under name.
+func New(name string, opts Options) (Attestor, error) {
+	m.Lock()
+	c, ok := registry[name]
+	m.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("attestation: no backend named '%s' is registered", name)
+	}
+	return c(opts)
+}
+
+// Backends returns the sorted names of all registered backends.
+func Backends() []string {
+	m.Lock()
+	defer m.Unlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
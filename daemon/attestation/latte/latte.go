@@ -0,0 +1,116 @@
+// +build cgo
+
+// Package latte implements the attestation backend on top of the liblatte
+// client library.
+package latte
+
+// #cgo LDFLAGS: -lport
+// #include <stdlib.h>
+// #include "libport.h"
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/docker/docker/daemon/attestation"
+)
+
+const name = "latte"
+
+func init() {
+	if err := attestation.Register(name, New); err != nil {
+		panic(err)
+	}
+}
+
+type latte struct {
+	daemonPath string
+}
+
+// New creates a liblatte backed attestor.
+func New(opts attestation.Options) (attestation.Attestor, error) {
+	return &latte{daemonPath: opts.DaemonPath}, nil
+}
+
+func (l *latte) Name() string {
+	return name
+}
+
+func (l *latte) Init(id string, runAsIaaS bool) error {
+	cid := C.CString(id)
+	defer C.free(unsafe.Pointer(cid))
+	// an empty path makes liblatte use its default
+	cpath := C.CString(l.daemonPath)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var iaas C.int
+	if runAsIaaS {
+		iaas = 1
+	}
+	if ret := C.liblatte_init(cid, iaas, cpath); ret != 0 {
+		return fmt.Errorf("liblatte: init failed: %d", ret)
+	}
+	return nil
+}
+
+func (l *latte) CreatePrincipal(p attestation.Principal) error {
+	cimage := C.CString(p.Image)
+	defer C.free(unsafe.Pointer(cimage))
+	cconfig := C.CString(p.Config)
+	defer C.free(unsafe.Pointer(cconfig))
+	cip := C.CString(p.IP)
+	defer C.free(unsafe.Pointer(cip))
+
+	ret := C.liblatte_create_principal_with_allocated_ports(C.uint64_t(p.PID),
+		cimage, cconfig, cip, C.int(p.PortMin), C.int(p.PortMax))
+	if ret <= 0 {
+		return fmt.Errorf("liblatte: failed to create principal %d: %d", p.PID, ret)
+	}
+	return nil
+}
+
+func (l *latte) DeletePrincipal(pid uint64) error {
+	if ret := C.liblatte_delete_principal_without_allocated_ports(C.uint64_t(pid)); ret != 0 {
+		return fmt.Errorf("liblatte: failed to delete principal %d: %d", pid, ret)
+	}
+	return nil
+}
+
+func (l *latte) EndorseImage(id, config, property string) error {
+	cid := C.CString(id)
+	defer C.free(unsafe.Pointer(cid))
+	cconfig := C.CString(config)
+	defer C.free(unsafe.Pointer(cconfig))
+	cproperty := C.CString(property)
+	defer C.free(unsafe.Pointer(cproperty))
+
+	if ret := C.liblatte_endorse_image(cid, cconfig, cproperty); ret != 0 {
+		return fmt.Errorf("liblatte: failed to endorse image %s: %d", id, ret)
+	}
+	return nil
+}
+
+func (l *latte) PostObjectACL(id, requirement string) error {
+	cid := C.CString(id)
+	defer C.free(unsafe.Pointer(cid))
+	creq := C.CString(requirement)
+	defer C.free(unsafe.Pointer(creq))
+
+	if ret := C.liblatte_post_object_acl(cid, creq); ret != 0 {
+		return fmt.Errorf("liblatte: failed to post acl of %s: %d", id, ret)
+	}
+	return nil
+}
+
+func (l *latte) AttestProperty(ip string, port uint32, property string) error {
+	cip := C.CString(ip)
+	defer C.free(unsafe.Pointer(cip))
+	cproperty := C.CString(property)
+	defer C.free(unsafe.Pointer(cproperty))
+
+	if ret := C.liblatte_check_property(cip, C.uint32_t(port), cproperty); ret != 0 {
+		return fmt.Errorf("liblatte: %s:%d does not have property %s: %d", ip, port, property, ret)
+	}
+	return nil
+}
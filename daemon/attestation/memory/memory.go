@@ -0,0 +1,174 @@
+// Package memory implements an in-memory attestation backend. It keeps all
+// state in the daemon process and is meant for tests and for running tapcon
+// without an attestation service.
+package memory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/daemon/attestation"
+)
+
+const name = "memory"
+
+func init() {
+	if err := attestation.Register(name, func(attestation.Options) (attestation.Attestor, error) {
+		return New(), nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+type principalKey struct {
+	pid uint64
+	ip  string
+}
+
+// Attestor is an in-memory attestation backend.
+type Attestor struct {
+	mu          sync.Mutex
+	initialized bool
+	principals  map[principalKey]attestation.Principal
+	images      map[string][]string
+	acls        map[string]string
+}
+
+// New creates an empty in-memory attestor.
+func New() *Attestor {
+	return &Attestor{
+		principals: make(map[principalKey]attestation.Principal),
+		images:     make(map[string][]string),
+		acls:       make(map[string]string),
+	}
+}
+
+// Name returns the name of the backend.
+func (a *Attestor) Name() string {
+	return name
+}
+
+// Init marks the attestor as initialized.
+func (a *Attestor) Init(id string, runAsIaaS bool) error {
+	a.mu.Lock()
+	a.initialized = true
+	a.mu.Unlock()
+	return nil
+}
+
+// CreatePrincipal records p. A process attached to several networks is
+// registered once per address; it fails if the PID is already registered
+// with the same address.
+func (a *Attestor) CreatePrincipal(p attestation.Principal) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.initialized {
+		return fmt.Errorf("memory: attestor is not initialized")
+	}
+	key := principalKey{pid: p.PID, ip: p.IP}
+	if _, ok := a.principals[key]; ok {
+		return fmt.Errorf("memory: principal %d already exists on %s", p.PID, p.IP)
+	}
+	a.principals[key] = p
+	return nil
+}
+
+// DeletePrincipal forgets every address of the principal identified by pid.
+func (a *Attestor) DeletePrincipal(pid uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var found bool
+	for key := range a.principals {
+		if key.pid == pid {
+			delete(a.principals, key)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("memory: no such principal %d", pid)
+	}
+	return nil
+}
+
+// EndorseImage records property as endorsed for the image id.
+func (a *Attestor) EndorseImage(id, config, property string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.initialized {
+		return fmt.Errorf("memory: attestor is not initialized")
+	}
+	a.images[id] = append(a.images[id], property)
+	return nil
+}
+
+// VerifyImage succeeds if the image id has been endorsed with property.
+func (a *Attestor) VerifyImage(id, property string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, prop := range a.images[id] {
+		if prop == property {
+			return nil
+		}
+	}
+	return fmt.Errorf("memory: image %s is not endorsed with %s", id, property)
+}
+
+// PostObjectACL records the requirement for the object id.
+func (a *Attestor) PostObjectACL(id, requirement string) error {
+	a.mu.Lock()
+	a.acls[id] = requirement
+	a.mu.Unlock()
+	return nil
+}
+
+// AttestProperty succeeds if a principal owns ip:port and its image has been
+// endorsed with property.
+func (a *Attestor) AttestProperty(ip string, port uint32, property string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, p := range a.principals {
+		if p.IP != ip || int(port) < p.PortMin || int(port) > p.PortMax {
+			continue
+		}
+		for _, prop := range a.images[p.Image] {
+			if prop == property {
+				return nil
+			}
+		}
+		return fmt.Errorf("memory: principal %d does not have property %s", p.PID, property)
+	}
+	return fmt.Errorf("memory: no principal at %s:%d", ip, port)
+}
+
+// Principals returns a copy of the registered principals.
+func (a *Attestor) Principals() []attestation.Principal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ps := make([]attestation.Principal, 0, len(a.principals))
+	for _, p := range a.principals {
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// Endorsements returns the properties endorsed for the image id.
+func (a *Attestor) Endorsements(id string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.images[id]...)
+}
+
+// ACL returns the requirement posted for the object id.
+func (a *Attestor) ACL(id string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.acls[id]
+}
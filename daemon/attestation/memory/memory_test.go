@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/docker/docker/daemon/attestation"
+)
+
+func TestPrincipalLifecycle(t *testing.T) {
+	a := New()
+	p := attestation.Principal{PID: 42, Image: "sha256:abc", Config: "default", IP: "172.17.0.2", PortMin: 1, PortMax: 65535}
+	if err := a.CreatePrincipal(p); err == nil {
+		t.Fatal("expected an error creating a principal before init")
+	}
+	if err := a.Init("", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreatePrincipal(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreatePrincipal(p); err == nil {
+		t.Fatal("expected an error creating a duplicate principal")
+	}
+	if ps := a.Principals(); len(ps) != 1 || ps[0] != p {
+		t.Fatalf("unexpected principals: %v", ps)
+	}
+	p2 := p
+	p2.IP = "172.18.0.2"
+	if err := a.CreatePrincipal(p2); err != nil {
+		t.Fatal(err)
+	}
+	if ps := a.Principals(); len(ps) != 2 {
+		t.Fatalf("expected a principal per address, got %v", ps)
+	}
+	if err := a.DeletePrincipal(p.PID); err != nil {
+		t.Fatal(err)
+	}
+	if ps := a.Principals(); len(ps) != 0 {
+		t.Fatalf("expected every address to be deleted, got %v", ps)
+	}
+	if err := a.DeletePrincipal(p.PID); err == nil {
+		t.Fatal("expected an error deleting a missing principal")
+	}
+}
+
+func TestAttestProperty(t *testing.T) {
+	a := New()
+	a.Init("", false)
+	a.CreatePrincipal(attestation.Principal{PID: 1, Image: "sha256:abc", IP: "10.0.0.1", PortMin: 1000, PortMax: 1999})
+
+	if err := a.AttestProperty("10.0.0.1", 1500, "git://repo#rev"); err == nil {
+		t.Fatal("expected an error attesting an unendorsed image")
+	}
+	if err := a.EndorseImage("sha256:abc", "*", "git://repo#rev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AttestProperty("10.0.0.1", 1500, "git://repo#rev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AttestProperty("10.0.0.1", 2000, "git://repo#rev"); err == nil {
+		t.Fatal("expected an error attesting a port outside of the principal's range")
+	}
+}
+
+func TestVerifyImage(t *testing.T) {
+	a := New()
+	a.Init("", false)
+
+	if err := a.VerifyImage("sha256:abc", "git://repo#rev:dir"); err == nil {
+		t.Fatal("expected an error verifying an unendorsed image")
+	}
+	if err := a.EndorseImage("sha256:abc", "*", "git://repo#rev:dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.VerifyImage("sha256:abc", "git://repo#rev:dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.VerifyImage("sha256:abc", "git://repo#other:dir"); err == nil {
+		t.Fatal("expected an error verifying an image endorsed with another source")
+	}
+}
@@ -0,0 +1,127 @@
+// Package remote implements a pure Go attestation backend that talks to the
+// attestation service over HTTP, either on a unix socket or on TCP.
+//
+// Requests follow the plugin RPC convention: each call is a POST of a JSON
+// body to /Attestor.<Method>, and the service replies with a JSON object
+// whose Err field is empty on success.
+package remote
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/docker/docker/daemon/attestation"
+	"github.com/docker/docker/pkg/plugins"
+)
+
+const (
+	name = "remote"
+
+	// timeout of a single request, in seconds
+	defaultTimeout = 30
+)
+
+func init() {
+	if err := attestation.Register(name, New); err != nil {
+		panic(err)
+	}
+}
+
+type remote struct {
+	client *plugins.Client
+}
+
+// New creates an attestor which connects to opts.DaemonPath. Paths without
+// a scheme are taken to be unix sockets.
+func New(opts attestation.Options) (attestation.Attestor, error) {
+	addr := opts.DaemonPath
+	if addr == "" {
+		addr = attestation.DefaultDaemonPath
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "unix://" + addr
+	}
+	c, err := plugins.NewClientWithTimeout(addr, nil, defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &remote{client: c}, nil
+}
+
+type response struct {
+	Err string
+}
+
+func (r *remote) call(method string, args interface{}) error {
+	var ret response
+	if err := r.client.Call("Attestor."+method, args, &ret); err != nil {
+		return err
+	}
+	if ret.Err != "" {
+		return errors.New(ret.Err)
+	}
+	return nil
+}
+
+func (r *remote) Name() string {
+	return name
+}
+
+type initRequest struct {
+	ID   string
+	IaaS bool
+}
+
+func (r *remote) Init(id string, runAsIaaS bool) error {
+	return r.call("Init", initRequest{ID: id, IaaS: runAsIaaS})
+}
+
+func (r *remote) CreatePrincipal(p attestation.Principal) error {
+	return r.call("CreatePrincipal", p)
+}
+
+type deletePrincipalRequest struct {
+	PID uint64
+}
+
+func (r *remote) DeletePrincipal(pid uint64) error {
+	return r.call("DeletePrincipal", deletePrincipalRequest{PID: pid})
+}
+
+type endorseImageRequest struct {
+	ID       string
+	Config   string
+	Property string
+}
+
+func (r *remote) EndorseImage(id, config, property string) error {
+	return r.call("EndorseImage", endorseImageRequest{ID: id, Config: config, Property: property})
+}
+
+type verifyImageRequest struct {
+	ID       string
+	Property string
+}
+
+func (r *remote) VerifyImage(id, property string) error {
+	return r.call("VerifyImage", verifyImageRequest{ID: id, Property: property})
+}
+
+type objectACLRequest struct {
+	ID          string
+	Requirement string
+}
+
+func (r *remote) PostObjectACL(id, requirement string) error {
+	return r.call("PostObjectACL", objectACLRequest{ID: id, Requirement: requirement})
+}
+
+type attestPropertyRequest struct {
+	IP       string
+	Port     uint32
+	Property string
+}
+
+func (r *remote) AttestProperty(ip string, port uint32, property string) error {
+	return r.call("AttestProperty", attestPropertyRequest{IP: ip, Port: port, Property: property})
+}
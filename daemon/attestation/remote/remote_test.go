@@ -0,0 +1,66 @@
+package remote
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/daemon/attestation"
+)
+
+func TestRemoteCalls(t *testing.T) {
+	var principal attestation.Principal
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Attestor.CreatePrincipal", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&principal); err != nil {
+			t.Fatal(err)
+		}
+		json.NewEncoder(w).Encode(response{})
+	})
+	mux.HandleFunc("/Attestor.DeletePrincipal", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(response{Err: "no such principal"})
+	})
+	mux.HandleFunc("/Attestor.VerifyImage", func(w http.ResponseWriter, r *http.Request) {
+		var req verifyImageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req.ID != "sha256:abc" || req.Property != "git://repo#rev:dir" {
+			json.NewEncoder(w).Encode(response{Err: "not endorsed"})
+			return
+		}
+		json.NewEncoder(w).Encode(response{})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	a, err := New(attestation.Options{DaemonPath: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := attestation.Principal{PID: 42, Image: "sha256:abc", Config: "default", IP: "172.17.0.2", PortMin: 1, PortMax: 65535}
+	if err := a.CreatePrincipal(p); err != nil {
+		t.Fatal(err)
+	}
+	if principal != p {
+		t.Fatalf("expected %v, got %v", p, principal)
+	}
+
+	if err := a.DeletePrincipal(42); err == nil || err.Error() != "no such principal" {
+		t.Fatalf("expected the service error, got %v", err)
+	}
+
+	v, ok := a.(attestation.ImageVerifier)
+	if !ok {
+		t.Fatal("expected the remote attestor to verify images")
+	}
+	if err := v.VerifyImage("sha256:abc", "git://repo#rev:dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyImage("sha256:def", "git://repo#rev:dir"); err == nil || err.Error() != "not endorsed" {
+		t.Fatalf("expected the service error, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+package daemon
+
+import (
+	// Importing packages here only to make sure their init gets called and
+	// therefore they register themselves to the attestation factory.
+	_ "github.com/docker/docker/daemon/attestation/memory"
+	_ "github.com/docker/docker/daemon/attestation/remote"
+)
@@ -0,0 +1,8 @@
+// +build !exclude_attestor_latte,cgo
+
+package daemon
+
+import (
+	// register the liblatte attestation backend
+	_ "github.com/docker/docker/daemon/attestation/latte"
+)
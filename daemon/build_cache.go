@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	mounttypes "github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/container"
+	"github.com/docker/docker/pkg/directory"
+	"github.com/docker/docker/volume"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// buildCacheLabel is set on the volumes backing the cache mounts of
+	// builds, to the id of the cache.
+	buildCacheLabel = "com.docker.builder.cache"
+	// buildCacheVolumePrefix starts the names of the volumes backing the
+	// cache mounts of builds. The rest of the name is derived from the id of
+	// the cache, which may not be a valid volume name.
+	buildCacheVolumePrefix = "buildcache-"
+)
+
+// buildCacheLocks locks the build caches while a build uses them.
+type buildCacheLocks struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	inUse map[string]bool
+}
+
+func (l *buildCacheLocks) init() {
+	if l.cond == nil {
+		l.cond = sync.NewCond(&l.mu)
+		l.inUse = make(map[string]bool)
+	}
+}
+
+// acquire waits until no other build uses the cache id, and locks it.
+func (l *buildCacheLocks) acquire(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+	for l.inUse[id] {
+		l.cond.Wait()
+	}
+	l.inUse[id] = true
+}
+
+// tryAcquire locks the cache id unless a build uses it.
+func (l *buildCacheLocks) tryAcquire(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+	if l.inUse[id] {
+		return false
+	}
+	l.inUse[id] = true
+	return true
+}
+
+// release unlocks the cache id.
+func (l *buildCacheLocks) release(id string) {
+	l.mu.Lock()
+	delete(l.inUse, id)
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+func buildCacheVolumeName(id string) string {
+	return buildCacheVolumePrefix + digest.FromString(id).Hex()
+}
+
+func isBuildCacheVolume(name string) bool {
+	return strings.HasPrefix(name, buildCacheVolumePrefix)
+}
+
+// AcquireBuildCache returns the name of the volume backing the build cache
+// id, creating it if it does not exist yet. The cache is locked until
+// release is called, so that concurrent builds never share it.
+func (daemon *Daemon) AcquireBuildCache(id string) (string, func(), error) {
+	daemon.buildCaches.acquire(id)
+	name := buildCacheVolumeName(id)
+	if _, err := daemon.volumes.Create(name, volume.DefaultDriverName, nil, map[string]string{buildCacheLabel: id}); err != nil {
+		daemon.buildCaches.release(id)
+		return "", nil, err
+	}
+	return name, func() { daemon.buildCaches.release(id) }, nil
+}
+
+// BuildCachePrune removes the volumes of the build caches which no build or
+// container uses.
+func (daemon *Daemon) BuildCachePrune() (*types.BuildCachePruneReport, error) {
+	rep := &types.BuildCachePruneReport{}
+
+	pruneCache := func(v volume.Volume) error {
+		name := v.Name()
+		if !isBuildCacheVolume(name) {
+			return nil
+		}
+		id := name
+		if dv, err := daemon.volumes.Get(name); err == nil {
+			if d, ok := dv.(volume.DetailedVolume); ok && d.Labels()[buildCacheLabel] != "" {
+				id = d.Labels()[buildCacheLabel]
+			}
+		}
+
+		// a build holds the lock of a cache from before its container is
+		// created until it exits, so the volume may not be referenced yet
+		if !daemon.buildCaches.tryAcquire(id) {
+			return nil
+		}
+		defer daemon.buildCaches.release(id)
+		if len(daemon.volumes.Refs(v)) > 0 {
+			return nil
+		}
+		size, err := directory.Size(v.Path())
+		if err != nil {
+			logrus.Warnf("could not determine size of build cache %s: %v", id, err)
+		}
+		if err := daemon.volumes.Remove(v); err != nil {
+			logrus.Warnf("could not remove build cache %s: %v", id, err)
+			return nil
+		}
+		rep.SpaceReclaimed += uint64(size)
+		rep.CachesDeleted = append(rep.CachesDeleted, id)
+		return nil
+	}
+
+	return rep, daemon.traverseLocalVolumes(pruneCache)
+}
+
+// buildMountpoints returns the mountpoints of a build container which are
+// left out of the layer it commits, relative to the root of its filesystem:
+// the ones of its secrets and build caches.
+func buildMountpoints(c *container.Container) []string {
+	var mountpoints []string
+	if len(c.SecretReferences) > 0 {
+		mountpoints = append(mountpoints, secretMountpoint)
+	}
+	for _, mp := range c.MountPoints {
+		if mp.Type == mounttypes.TypeVolume && isBuildCacheVolume(mp.Name) {
+			mountpoints = append(mountpoints, strings.TrimPrefix(path.Clean(filepath.ToSlash(mp.Destination)), "/"))
+		}
+	}
+	return mountpoints
+}
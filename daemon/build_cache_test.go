@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildCacheLocks(t *testing.T) {
+	var l buildCacheLocks
+
+	l.acquire("gomod")
+	if l.tryAcquire("gomod") {
+		t.Fatal("Acquired a cache used by another build")
+	}
+	if !l.tryAcquire("npm") {
+		t.Fatal("Failed to acquire an unused cache")
+	}
+	l.release("npm")
+
+	acquired := make(chan struct{})
+	go func() {
+		l.acquire("gomod")
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquired a cache used by another build")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	l.release("gomod")
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a released cache")
+	}
+}
@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/backend"
+	swarmtypes "github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/pkg/ioutils"
+	swarmapi "github.com/docker/swarmkit/api"
+)
+
+// secretMountpoint is the mountpoint of the secrets of a container, relative
+// to the root of its filesystem.
+const secretMountpoint = "run/secrets"
+
+// buildSecretStore holds the secrets of a build container. They are only
+// kept in memory, and written to the tmpfs of the container when it starts.
+type buildSecretStore map[string]*swarmapi.Secret
+
+func (s buildSecretStore) Get(secretID string) *swarmapi.Secret {
+	return s[secretID]
+}
+
+// ContainerSetBuildSecrets sets the secrets mounted into a build container
+// in /run/secrets when it starts. They are mounted the same way as the
+// secrets of swarm services.
+func (daemon *Daemon) ContainerSetBuildSecrets(name string, secrets []backend.BuildSecret) error {
+	if !secretsSupported() {
+		return errors.New("build secrets are not supported on this platform")
+	}
+	c, err := daemon.GetContainer(name)
+	if err != nil {
+		return err
+	}
+
+	store := make(buildSecretStore)
+	var refs []*swarmtypes.SecretReference
+	for i, s := range secrets {
+		id := strconv.Itoa(i)
+		store[id] = &swarmapi.Secret{
+			ID:   id,
+			Spec: swarmapi.SecretSpec{Data: s.Data},
+		}
+		refs = append(refs, &swarmtypes.SecretReference{
+			SecretID:   id,
+			SecretName: s.Target,
+			File: &swarmtypes.SecretReferenceFileTarget{
+				Name: s.Target,
+				UID:  s.UID,
+				GID:  s.GID,
+				Mode: s.Mode,
+			},
+		})
+	}
+	c.SecretStore = store
+	c.SecretReferences = refs
+	return nil
+}
+
+// excludeMountpoints removes the given mountpoints from the changes of a
+// container. The directories holding a mountpoint are removed as well,
+// unless other changes are made in them.
+func excludeMountpoints(rwTar io.ReadCloser, mountpoints []string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(filterMountpoints(rwTar, pw, mountpoints))
+	}()
+	return ioutils.NewReadCloserWrapper(pr, func() error {
+		pr.Close()
+		return rwTar.Close()
+	})
+}
+
+// filterMountpoints copies the tar stream read from r to w, without the
+// entries of mountpoints. The entries of their parent directories are only
+// copied if an entry below them, other than a mountpoint, is.
+func filterMountpoints(r io.Reader, w io.Writer, mountpoints []string) error {
+	// parents are ordered from the root down, so that they are written
+	// before their subdirectories
+	var parents []string
+	isParent := make(map[string]bool)
+	for _, mountpoint := range mountpoints {
+		for dir := path.Dir(mountpoint); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			isParent[dir] = true
+		}
+	}
+	for dir := range isParent {
+		parents = append(parents, dir)
+	}
+	sort.Sort(byDepth(parents))
+	held := make(map[string]*tar.Header)
+
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+next:
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(hdr.Name, "./"), "/")
+		for _, mountpoint := range mountpoints {
+			if name == mountpoint || strings.HasPrefix(name, mountpoint+"/") {
+				continue next
+			}
+		}
+		if isParent[name] {
+			held[name] = hdr
+			continue
+		}
+		for _, dir := range parents {
+			if parent, ok := held[dir]; ok && strings.HasPrefix(name, dir+"/") {
+				if err := tw.WriteHeader(parent); err != nil {
+					return err
+				}
+				delete(held, dir)
+			}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// byDepth sorts paths by their number of elements.
+type byDepth []string
+
+func (s byDepth) Len() int      { return len(s) }
+func (s byDepth) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byDepth) Less(i, j int) bool {
+	di, dj := strings.Count(s[i], "/"), strings.Count(s[j], "/")
+	if di != dj {
+		return di < dj
+	}
+	return s[i] < s[j]
+}
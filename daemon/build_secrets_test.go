@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestFilterMountpoints(t *testing.T) {
+	tests := []struct {
+		mountpoints []string
+		entries     []string
+		expected    []string
+	}{
+		{
+			// the mountpoint and the directory created for it
+			mountpoints: []string{secretMountpoint},
+			entries:     []string{"etc/", "etc/passwd", "run/", "run/secrets/"},
+			expected:    []string{"etc/", "etc/passwd"},
+		},
+		{
+			// other changes in the parent directory
+			mountpoints: []string{secretMountpoint},
+			entries:     []string{"run/", "run/lock/", "run/secrets/", "run/utmp"},
+			expected:    []string{"run/", "run/lock/", "run/utmp"},
+		},
+		{
+			// entries sorted between the parent directory and its content
+			mountpoints: []string{secretMountpoint},
+			entries:     []string{"run/", "run-parts", "run/secrets/", "run/utmp"},
+			expected:    []string{"run-parts", "run/", "run/utmp"},
+		},
+		{
+			mountpoints: []string{secretMountpoint},
+			entries:     []string{"./run/", "./run/secrets/", "./run/secrets/token", "./usr/"},
+			expected:    []string{"./usr/"},
+		},
+		{
+			// several mountpoints, nested in the same directories
+			mountpoints: []string{secretMountpoint, "root/.cache/go-build", "root/.npm"},
+			entries:     []string{"root/", "root/.cache/", "root/.cache/go-build/", "root/.cache/pip/", "root/.npm/", "run/", "run/secrets/"},
+			expected:    []string{"root/", "root/.cache/", "root/.cache/pip/"},
+		},
+	}
+
+	for _, test := range tests {
+		var in bytes.Buffer
+		tw := tar.NewWriter(&in)
+		for _, name := range test.entries {
+			hdr := &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+			if name[len(name)-1] != '/' {
+				hdr.Typeflag = tar.TypeReg
+				hdr.Size = int64(len(name))
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				if _, err := tw.Write([]byte(name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if err := filterMountpoints(&in, &out, test.mountpoints); err != nil {
+			t.Fatalf("Error filtering %v: %v", test.entries, err)
+		}
+
+		var names []string
+		tr := tar.NewReader(&out)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hdr.Typeflag == tar.TypeReg {
+				var content bytes.Buffer
+				if _, err := io.Copy(&content, tr); err != nil {
+					t.Fatal(err)
+				}
+				if content.String() != hdr.Name {
+					t.Fatalf("Unexpected content for %s: %q", hdr.Name, content.String())
+				}
+			}
+			names = append(names, hdr.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Fatalf("Expected %v for %v, got %v", test.expected, test.entries, names)
+		}
+	}
+}
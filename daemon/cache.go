@@ -7,13 +7,16 @@ import (
 	"strings"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/remotecache"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/layer"
 	"github.com/docker/docker/runconfig"
 	"github.com/pkg/errors"
+	"golang.org/x/net/context"
 )
 
 // getLocalCachedImage returns the most recent created image that is a child
@@ -57,8 +60,26 @@ func (daemon *Daemon) getLocalCachedImage(imgID image.ID, config *containertypes
 	return getMatch(siblings)
 }
 
-// MakeImageCache creates a stateful image cache.
-func (daemon *Daemon) MakeImageCache(sourceRefs []string) builder.ImageCache {
+// MakeImageCache creates a stateful image cache. The sources starting with
+// type= are caches exported by other builds, the others are images.
+func (daemon *Daemon) MakeImageCache(ctx context.Context, sources []string, authConfigs map[string]types.AuthConfig) builder.ImageCache {
+	var sourceRefs, specs []string
+	for _, s := range sources {
+		if remotecache.IsSpec(s) {
+			specs = append(specs, s)
+		} else {
+			sourceRefs = append(sourceRefs, s)
+		}
+	}
+
+	cache := daemon.makeImageCache(sourceRefs)
+	if len(specs) > 0 {
+		return daemon.importBuildCache(ctx, cache, specs, authConfigs)
+	}
+	return cache
+}
+
+func (daemon *Daemon) makeImageCache(sourceRefs []string) builder.ImageCache {
 	if len(sourceRefs) == 0 {
 		return &localImageCache{daemon}
 	}
@@ -0,0 +1,435 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/remotecache"
+	"github.com/docker/docker/distribution"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/reference"
+	"github.com/docker/docker/registry"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// openBuildCache returns the cache described by spec, to export to if push
+// is set.
+func (daemon *Daemon) openBuildCache(ctx context.Context, spec *remotecache.Spec, authConfigs map[string]types.AuthConfig, push bool) (remotecache.Target, error) {
+	if spec.Type == remotecache.TypeLocal {
+		dir, err := daemon.localBuildCacheDir(spec.Path)
+		if err != nil {
+			return nil, err
+		}
+		return remotecache.NewLocal(dir), nil
+	}
+
+	ref, err := reference.ParseNamed(spec.Ref)
+	if err != nil {
+		return nil, err
+	}
+	tagged, ok := reference.WithDefaultTag(ref).(reference.NamedTagged)
+	if !ok {
+		return nil, fmt.Errorf("the reference of a registry cache must be a tag, got %s", spec.Ref)
+	}
+	repoInfo, err := daemon.RegistryService.ResolveRepository(tagged)
+	if err != nil {
+		return nil, err
+	}
+	authConfig := registry.ResolveAuthConfig(authConfigs, repoInfo.Index)
+
+	lookup, actions := daemon.RegistryService.LookupPullEndpoints, []string{"pull"}
+	if push {
+		lookup, actions = daemon.RegistryService.LookupPushEndpoints, []string{"push", "pull"}
+	}
+	endpoints, err := lookup(repoInfo.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	lastErr := fmt.Errorf("no registry endpoint found for %s", spec.Ref)
+	for _, endpoint := range endpoints {
+		if endpoint.Version == registry.APIVersion1 {
+			continue
+		}
+		repo, confirmedV2, err := distribution.NewV2Repository(ctx, repoInfo, endpoint, nil, &authConfig, actions...)
+		if err == nil && confirmedV2 {
+			return remotecache.NewRegistry(repo, tagged.Tag()), nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+// localBuildCacheDir checks that the directory of a local cache is under the
+// build cache directory of the daemon: builds run as root, and must not read
+// or write anywhere else on the host.
+func (daemon *Daemon) localBuildCacheDir(path string) (string, error) {
+	root := daemon.configStore.BuildCacheDir
+	if root == "" {
+		return "", errors.New("local build caches are disabled, the daemon has no --build-cache-dir")
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("the directory of a local cache must be an absolute path, got %s", path)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Clean(path)
+	if rel, err := filepath.Rel(root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("the directory of a local cache must be under %s, got %s", root, path)
+	}
+	return dir, nil
+}
+
+// importedStep is a step of a build found in an imported cache.
+type importedStep struct {
+	source remotecache.Source
+	record remotecache.Record
+}
+
+// remoteImageCache looks the steps of a build up in the caches exported by
+// other builds, when the images of the daemon do not have them. Only the
+// blobs of the steps which hit are fetched.
+type remoteImageCache struct {
+	daemon *Daemon
+	ctx    context.Context
+	local  builder.ImageCache
+	steps  map[digest.Digest]importedStep
+}
+
+// importBuildCache reads the manifests of the caches described by specs.
+// The caches which cannot be read are skipped, like the cache images which
+// do not exist.
+func (daemon *Daemon) importBuildCache(ctx context.Context, local builder.ImageCache, specs []string, authConfigs map[string]types.AuthConfig) builder.ImageCache {
+	cache := &remoteImageCache{
+		daemon: daemon,
+		ctx:    ctx,
+		local:  local,
+		steps:  make(map[digest.Digest]importedStep),
+	}
+	for _, s := range specs {
+		spec, err := remotecache.ParseSpec(s, false)
+		if err != nil {
+			logrus.Warnf("Could not import build cache %s, skipping: %v", s, err)
+			continue
+		}
+		source, err := daemon.openBuildCache(ctx, spec, authConfigs, false)
+		if err != nil {
+			logrus.Warnf("Could not import build cache %s, skipping: %v", s, err)
+			continue
+		}
+		m, err := source.Manifest(ctx)
+		if err != nil {
+			logrus.Warnf("Could not import build cache %s, skipping: %v", s, err)
+			continue
+		}
+		if m == nil {
+			logrus.Warnf("Build cache %s is empty, skipping", s)
+			continue
+		}
+		for _, r := range m.Records {
+			// the first cache listing a step wins
+			if _, ok := cache.steps[r.Key]; !ok {
+				cache.steps[r.Key] = importedStep{source: source, record: r}
+			}
+		}
+	}
+	return cache
+}
+
+func (c *remoteImageCache) GetCache(parentID string, cfg *containertypes.Config) (string, error) {
+	imgID, err := c.local.GetCache(parentID, cfg)
+	if err != nil || imgID != "" {
+		return imgID, err
+	}
+
+	key, err := remotecache.Key(parentID, cfg)
+	if err != nil {
+		return "", err
+	}
+	step, ok := c.steps[key]
+	if !ok {
+		return "", nil
+	}
+	id, err := c.daemon.importCacheStep(c.ctx, step.source, step.record, parentID)
+	if err != nil {
+		// an unusable cache only slows the build down
+		logrus.Warnf("Could not import the build cache of step %s: %v", key, err)
+		return "", nil
+	}
+	return id.String(), nil
+}
+
+// importCacheStep creates the image produced by a step of an imported cache,
+// registering the layer the step added if the daemon does not have it. The
+// manifest of the cache is not trusted: the step must start from parentID,
+// and its image must have the layers of parentID with at most one more.
+func (daemon *Daemon) importCacheStep(ctx context.Context, source remotecache.Source, r remotecache.Record, parentID string) (image.ID, error) {
+	if r.Parent != parentID {
+		return "", fmt.Errorf("the step starts from %q, expected %q", r.Parent, parentID)
+	}
+	var parentLayers []layer.DiffID
+	if parentID != "" {
+		parent, err := daemon.imageStore.Get(image.ID(parentID))
+		if err != nil {
+			return "", err
+		}
+		parentLayers = parent.RootFS.DiffIDs
+	}
+
+	id := image.ID(r.Image.Digest)
+	img, err := daemon.imageStore.Get(id)
+	exists := err == nil
+	var config []byte
+	if !exists {
+		if config, err = readCacheBlob(ctx, source, r.Image.Digest); err != nil {
+			return "", err
+		}
+		if img, err = image.NewFromJSON(config); err != nil {
+			return "", err
+		}
+	}
+	if err := checkCacheStepLayers(img, parentLayers, r); err != nil {
+		return "", err
+	}
+
+	if !exists {
+		if r.Layer != nil {
+			l, err := daemon.importCacheLayer(ctx, source, r, img.RootFS)
+			if err != nil {
+				return "", err
+			}
+			// the image holds its own reference to the layer once created
+			defer layer.ReleaseAndLog(daemon.layerStore, l)
+		}
+		if id, err = daemon.imageStore.Create(config); err != nil {
+			return "", err
+		}
+	}
+	if parentID != "" {
+		if err := daemon.imageStore.SetParent(id, image.ID(parentID)); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// checkCacheStepLayers checks that the layers of the image of a step of an
+// imported cache are the layers of its parent, followed by the layer of the
+// step if it added one.
+func checkCacheStepLayers(img *image.Image, parentLayers []layer.DiffID, r remotecache.Record) error {
+	var layers []layer.DiffID
+	if img.RootFS != nil {
+		layers = img.RootFS.DiffIDs
+	}
+	expected := len(parentLayers)
+	if r.Layer != nil {
+		expected++
+	}
+	if len(layers) != expected {
+		return fmt.Errorf("image %s has %d layers, expected %d", r.Image.Digest, len(layers), expected)
+	}
+	for i, diffID := range parentLayers {
+		if layers[i] != diffID {
+			return fmt.Errorf("image %s does not have the layers of its parent", r.Image.Digest)
+		}
+	}
+	return nil
+}
+
+// importCacheLayer registers the last layer of rootFS, which a step of an
+// imported cache added on top of the layers of its parent.
+func (daemon *Daemon) importCacheLayer(ctx context.Context, source remotecache.Source, r remotecache.Record, rootFS *image.RootFS) (layer.Layer, error) {
+	if l, err := daemon.layerStore.Get(rootFS.ChainID()); err == nil {
+		return l, nil
+	}
+
+	n := len(rootFS.DiffIDs)
+	if n == 0 || rootFS.DiffIDs[n-1] != layer.DiffID(r.DiffID) {
+		return nil, fmt.Errorf("the layer %s is not the last layer of image %s", r.DiffID, r.Image.Digest)
+	}
+	parent := image.NewRootFS()
+	parent.DiffIDs = rootFS.DiffIDs[:n-1]
+
+	rc, err := source.Open(ctx, r.Layer.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	inflated, err := archive.DecompressStream(rc)
+	if err != nil {
+		return nil, err
+	}
+	defer inflated.Close()
+
+	l, err := daemon.layerStore.Register(inflated, parent.ChainID())
+	if err != nil {
+		return nil, err
+	}
+	if l.DiffID() != layer.DiffID(r.DiffID) {
+		layer.ReleaseAndLog(daemon.layerStore, l)
+		return nil, fmt.Errorf("layer %s has diff ID %s, expected %s", r.Layer.Digest, l.DiffID(), r.DiffID)
+	}
+	return l, nil
+}
+
+// readCacheBlob reads a blob of a cache and verifies its digest.
+func readCacheBlob(ctx context.Context, source remotecache.Source, dgst digest.Digest) ([]byte, error) {
+	rc, err := source.Open(ctx, dgst)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	p, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	if dgst.Algorithm().FromBytes(p) != dgst {
+		return nil, fmt.Errorf("blob %s does not match its digest", dgst)
+	}
+	return p, nil
+}
+
+// ExportBuildCache exports the steps of a build to the caches described by
+// cacheTo. The previous content of each cache is replaced.
+func (daemon *Daemon) ExportBuildCache(ctx context.Context, steps []remotecache.Step, cacheTo []string, authConfigs map[string]types.AuthConfig, out io.Writer) error {
+	for _, s := range cacheTo {
+		spec, err := remotecache.ParseSpec(s, true)
+		if err != nil {
+			return err
+		}
+		target, err := daemon.openBuildCache(ctx, spec, authConfigs, true)
+		if err != nil {
+			return errors.Wrapf(err, "failed to export build cache to %s", s)
+		}
+		m, err := daemon.exportBuildCache(ctx, target, steps)
+		if err != nil {
+			return errors.Wrapf(err, "failed to export build cache to %s", s)
+		}
+		fmt.Fprintf(out, "Exported %d build steps to %s\n", len(m.Records), s)
+	}
+	return nil
+}
+
+func (daemon *Daemon) exportBuildCache(ctx context.Context, target remotecache.Target, steps []remotecache.Step) (*remotecache.Manifest, error) {
+	// the layers the target has from a previous export are not compressed
+	// again
+	exported := make(map[digest.Digest]remotecache.Blob)
+	if old, err := target.Manifest(ctx); err != nil {
+		logrus.Debugf("Could not read the build cache to replace: %v", err)
+	} else if old != nil {
+		for _, r := range old.Records {
+			if r.Layer != nil {
+				exported[r.DiffID] = *r.Layer
+			}
+		}
+	}
+
+	m := &remotecache.Manifest{}
+	seen := make(map[digest.Digest]bool)
+	for _, step := range steps {
+		if seen[step.Key] {
+			continue
+		}
+		seen[step.Key] = true
+		r, err := daemon.exportCacheStep(ctx, target, step, exported)
+		if err != nil {
+			return nil, err
+		}
+		m.Records = append(m.Records, r)
+	}
+	if err := target.Commit(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// exportCacheStep stores the configuration of the image a step produced and
+// the layer the step added, if any.
+func (daemon *Daemon) exportCacheStep(ctx context.Context, target remotecache.Target, step remotecache.Step, exported map[digest.Digest]remotecache.Blob) (remotecache.Record, error) {
+	r := remotecache.Record{Key: step.Key, Parent: step.Parent}
+	img, err := daemon.imageStore.Get(image.ID(step.Image))
+	if err != nil {
+		return r, err
+	}
+	config := img.RawJSON()
+	r.Image = remotecache.Blob{MediaType: schema2.MediaTypeImageConfig, Digest: digest.Digest(img.ID()), Size: int64(len(config))}
+	if has, err := target.Has(ctx, r.Image.Digest); err != nil {
+		return r, err
+	} else if !has {
+		if _, err := target.Put(ctx, r.Image.MediaType, bytes.NewReader(config)); err != nil {
+			return r, err
+		}
+	}
+
+	// the step added a layer if its image has more layers than its parent
+	parentLayers := 0
+	if step.Parent != "" {
+		parent, err := daemon.imageStore.Get(image.ID(step.Parent))
+		if err != nil {
+			return r, err
+		}
+		parentLayers = len(parent.RootFS.DiffIDs)
+	}
+	switch n := len(img.RootFS.DiffIDs); {
+	case n == parentLayers:
+		return r, nil
+	case n != parentLayers+1:
+		return r, fmt.Errorf("image %s does not add a single layer to its parent %s", step.Image, step.Parent)
+	}
+	r.DiffID = digest.Digest(img.RootFS.DiffIDs[len(img.RootFS.DiffIDs)-1])
+
+	if blob, ok := exported[r.DiffID]; ok {
+		if has, err := target.Has(ctx, blob.Digest); err == nil && has {
+			r.Layer = &blob
+			return r, nil
+		}
+	}
+
+	l, err := daemon.layerStore.Get(img.RootFS.ChainID())
+	if err != nil {
+		return r, err
+	}
+	defer layer.ReleaseAndLog(daemon.layerStore, l)
+	ts, err := l.TarStream()
+	if err != nil {
+		return r, err
+	}
+	defer ts.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		gz, err := archive.CompressStream(pw, archive.Gzip)
+		if err == nil {
+			_, err = io.Copy(gz, ts)
+			if cerr := gz.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	blob, err := target.Put(ctx, schema2.MediaTypeLayer, pr)
+	// unblocks the compression if the blob was not entirely read
+	pr.Close()
+	if err != nil {
+		return r, err
+	}
+	r.Layer = &blob
+	exported[r.DiffID] = blob
+	return r, nil
+}
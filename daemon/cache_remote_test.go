@@ -0,0 +1,61 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/docker/docker/builder/remotecache"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestLocalBuildCacheDir(t *testing.T) {
+	daemon := &Daemon{configStore: &Config{}}
+	if _, err := daemon.localBuildCacheDir("/var/lib/build-cache/app"); err == nil {
+		t.Fatal("expected local caches to be refused without a build cache directory")
+	}
+
+	daemon.configStore.BuildCacheDir = "/var/lib/build-cache"
+	for _, path := range []string{"/var/lib/build-cache", "/var/lib/build-cache/app", "/var/lib/build-cache/app/../other"} {
+		if _, err := daemon.localBuildCacheDir(path); err != nil {
+			t.Fatalf("expected %s to be allowed, got %v", path, err)
+		}
+	}
+	for _, path := range []string{"app", "/etc", "/var/lib/build-cache-other", "/var/lib/build-cache/../../../etc"} {
+		if _, err := daemon.localBuildCacheDir(path); err == nil {
+			t.Fatalf("expected %s to be refused", path)
+		}
+	}
+}
+
+func TestCheckCacheStepLayers(t *testing.T) {
+	parentLayers := []layer.DiffID{"sha256:a", "sha256:b"}
+	img := func(diffIDs ...layer.DiffID) *image.Image {
+		return &image.Image{RootFS: &image.RootFS{Type: "layers", DiffIDs: diffIDs}}
+	}
+	withLayer := remotecache.Record{Layer: &remotecache.Blob{Digest: digest.Digest("sha256:c")}, DiffID: "sha256:c"}
+	noLayer := remotecache.Record{}
+
+	tests := []struct {
+		img   *image.Image
+		r     remotecache.Record
+		valid bool
+	}{
+		{img("sha256:a", "sha256:b"), noLayer, true},
+		{img("sha256:a", "sha256:b", "sha256:c"), withLayer, true},
+		{img("sha256:a", "sha256:b", "sha256:c"), noLayer, false},
+		{img("sha256:a", "sha256:b"), withLayer, false},
+		{img("sha256:a", "sha256:x", "sha256:c"), withLayer, false},
+		{img("sha256:x", "sha256:b"), noLayer, false},
+		{&image.Image{}, noLayer, false},
+	}
+	for i, test := range tests {
+		err := checkCacheStepLayers(test.img, parentLayers, test.r)
+		if test.valid && err != nil {
+			t.Fatalf("%d: expected the layers to be valid, got %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Fatalf("%d: expected the layers to be refused", i)
+		}
+	}
+}
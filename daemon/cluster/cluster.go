@@ -59,6 +59,7 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
 	types "github.com/docker/docker/api/types/swarm"
+	timetypes "github.com/docker/docker/api/types/time"
 	"github.com/docker/docker/daemon/cluster/convert"
 	executorpkg "github.com/docker/docker/daemon/cluster/executor"
 	"github.com/docker/docker/daemon/logger"
@@ -1093,12 +1094,26 @@ func (c *Cluster) ServiceLogs(ctx context.Context, input string, config *backend
 		return err
 	}
 
-	stream, err := state.logsClient.SubscribeLogs(ctx, &swarmapi.SubscribeLogsRequest{
+	// the messages of the tasks are interleaved, so they are selected by
+	// the daemon rather than by the subscription
+	readConfig, err := serviceLogsReadConfig(config)
+	if err != nil {
+		c.mu.RUnlock()
+		return err
+	}
+	streamCtx := ctx
+	if readConfig.Follow && !readConfig.Until.IsZero() {
+		var cancel context.CancelFunc
+		streamCtx, cancel = context.WithDeadline(ctx, readConfig.Until)
+		defer cancel()
+	}
+
+	stream, err := state.logsClient.SubscribeLogs(streamCtx, &swarmapi.SubscribeLogsRequest{
 		Selector: &swarmapi.LogSelector{
 			ServiceIDs: []string{service.ID},
 		},
 		Options: &swarmapi.LogSubscriptionOptions{
-			Follow: config.Follow,
+			Follow: readConfig.Follow,
 		},
 	})
 	if err != nil {
@@ -1129,17 +1144,34 @@ func (c *Cluster) ServiceLogs(ctx context.Context, input string, config *backend
 			return nil
 		}
 		if err != nil {
+			if streamCtx.Err() != nil && ctx.Err() == nil {
+				// the logs were followed until readConfig.Until
+				return nil
+			}
 			return err
 		}
 
 		for _, msg := range subscribeMsg.Messages {
+			ts, err := ptypes.Timestamp(msg.Timestamp)
+			if err != nil {
+				return err
+			}
+			m := &logger.Message{Line: msg.Data, Timestamp: ts}
+			switch msg.Stream {
+			case swarmapi.LogStreamStdout:
+				m.Source = "stdout"
+			case swarmapi.LogStreamStderr:
+				m.Source = "stderr"
+			}
+			if !readConfig.Since.IsZero() && ts.Before(readConfig.Since) ||
+				!readConfig.Until.IsZero() && ts.After(readConfig.Until) ||
+				!readConfig.Filter.Match(m) {
+				continue
+			}
+
 			data := []byte{}
 
 			if config.Timestamps {
-				ts, err := ptypes.Timestamp(msg.Timestamp)
-				if err != nil {
-					return err
-				}
 				data = append(data, []byte(ts.Format(logger.TimeFormat)+" ")...)
 			}
 
@@ -1161,6 +1193,43 @@ func (c *Cluster) ServiceLogs(ctx context.Context, input string, config *backend
 	}
 }
 
+// serviceLogsReadConfig returns the messages of the logs of a service asked
+// for by config.
+func serviceLogsReadConfig(config *backend.ContainerLogsConfig) (logger.ReadConfig, error) {
+	var readConfig logger.ReadConfig
+	if config.Since != "" {
+		s, n, err := timetypes.ParseTimestamps(config.Since, 0)
+		if err != nil {
+			return readConfig, err
+		}
+		readConfig.Since = time.Unix(s, n)
+	}
+	if config.Until != "" {
+		s, n, err := timetypes.ParseTimestamps(config.Until, 0)
+		if err != nil {
+			return readConfig, err
+		}
+		readConfig.Until = time.Unix(s, n)
+	}
+	var sources []string
+	if !(config.ShowStdout && config.ShowStderr) {
+		if config.ShowStdout {
+			sources = append(sources, "stdout")
+		}
+		if config.ShowStderr {
+			sources = append(sources, "stderr")
+		}
+	}
+	filter, err := logger.NewMessageFilter(sources, config.Grep)
+	if err != nil {
+		return readConfig, err
+	}
+	readConfig.Filter = filter
+	// the logs are not followed past until
+	readConfig.Follow = config.Follow && (readConfig.Until.IsZero() || readConfig.Until.After(time.Now()))
+	return readConfig, nil
+}
+
 // GetNodes returns a list of all nodes known to a cluster.
 func (c *Cluster) GetNodes(options apitypes.NodeListOptions) ([]types.Node, error) {
 	c.mu.RLock()
@@ -15,6 +15,7 @@ import (
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/layer"
+	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/reference"
 )
@@ -154,11 +155,22 @@ func (daemon *Daemon) Commit(name string, c *backend.ContainerCommitConfig) (str
 	if err != nil {
 		return "", err
 	}
+	if mountpoints := buildMountpoints(container); len(mountpoints) > 0 {
+		rwTar = excludeMountpoints(rwTar, mountpoints)
+	}
 	defer func() {
 		if rwTar != nil {
 			rwTar.Close()
 		}
 	}()
+	if c.Reproducible {
+		normalized, err := archive.NormalizeTar(rwTar, c.SourceDateEpoch)
+		if err != nil {
+			return "", err
+		}
+		rwTar.Close()
+		rwTar = normalized
+	}
 
 	var history []image.History
 	rootFS := image.NewRootFS()
@@ -183,9 +195,17 @@ func (daemon *Daemon) Commit(name string, c *backend.ContainerCommitConfig) (str
 	}
 	defer layer.ReleaseAndLog(daemon.layerStore, l)
 
+	// the container the image is committed from is left out of reproducible
+	// images
+	created, containerID, containerConfig := time.Now().UTC(), container.ID, *container.Config
+	if c.Reproducible {
+		created, containerID = c.SourceDateEpoch, ""
+		containerConfig.Hostname = ""
+	}
+
 	h := image.History{
 		Author:     c.Author,
-		Created:    time.Now().UTC(),
+		Created:    created,
 		CreatedBy:  strings.Join(container.Config.Cmd, " "),
 		Comment:    c.Comment,
 		EmptyLayer: true,
@@ -211,8 +231,8 @@ func (daemon *Daemon) Commit(name string, c *backend.ContainerCommitConfig) (str
 			Config:          newConfig,
 			Architecture:    runtime.GOARCH,
 			OS:              runtime.GOOS,
-			Container:       container.ID,
-			ContainerConfig: *container.Config,
+			Container:       containerID,
+			ContainerConfig: containerConfig,
 			Author:          c.Author,
 			Created:         h.Created,
 		},
@@ -220,9 +240,10 @@ func (daemon *Daemon) Commit(name string, c *backend.ContainerCommitConfig) (str
 		History:    history,
 		OSFeatures: osFeatures,
 		OSVersion:  osVersion,
+		BaseImage:  c.BaseImage,
 	}
 	if daemon.TapconModeOn() {
-		daemon.tapconImageBuilt(newImage, c.TapconData)
+		daemon.tapconImageBuilt(newImage, c.TapconData, c.Reproducible)
 	}
 
 	config, err := json.Marshal(newImage)
@@ -149,6 +149,11 @@ type CommonConfig struct {
 	SwarmDefaultAdvertiseAddr string `json:"swarm-default-advertise-addr"`
 	MetricsAddress            string `json:"metrics-addr"`
 
+	// BuildCacheDir is the directory of the daemon host under which builds
+	// may import and export local build caches. Local caches are refused
+	// if it is empty.
+	BuildCacheDir string `json:"build-cache-dir,omitempty"`
+
 	LogConfig
 	bridgeConfig // bridgeConfig holds bridge network specific configuration.
 	registry.ServiceOptions
@@ -194,6 +199,7 @@ func (config *Config) InstallCommonFlags(flags *pflag.FlagSet) {
 	flags.BoolVar(&config.Experimental, "experimental", false, "Enable experimental features")
 
 	flags.StringVar(&config.MetricsAddress, "metrics-addr", "", "Set default address and port to serve the metrics api on")
+	flags.StringVar(&config.BuildCacheDir, "build-cache-dir", "", "Directory under which builds may import and export local caches")
 
 	config.MaxConcurrentDownloads = &maxConcurrentDownloads
 	config.MaxConcurrentUploads = &maxConcurrentUploads
@@ -5,6 +5,7 @@ package daemon
 import (
 	"fmt"
 
+	"github.com/docker/docker/daemon/attestation"
 	"github.com/docker/docker/opts"
 	units "github.com/docker/go-units"
 	"github.com/spf13/pflag"
@@ -14,6 +15,8 @@ var (
 	defaultPidFile  = "/var/run/docker.pid"
 	defaultGraph    = "/var/lib/docker"
 	defaultExecRoot = "/var/run/docker"
+
+	defaultTapconAttestor = "latte"
 )
 
 // Config defines the configuration of a docker daemon.
@@ -38,8 +41,13 @@ type Config struct {
 	SeccompProfile       string                   `json:"seccomp-profile,omitempty"`
 
 	// Tapcon field
-	UseTapcon             bool   `json:"tapcon,omitempty"`
-	TapconMetadataService string `json:"metadata-service,omitempty"`
+	UseTapcon               bool   `json:"tapcon,omitempty"`
+	TapconMetadataService   string `json:"metadata-service,omitempty"`
+	TapconAttestor          string `json:"tapcon-attestor,omitempty"`
+	TapconDaemonPath        string `json:"tapcon-daemon-path,omitempty"`
+	TapconPortRange         string `json:"tapcon-port-range,omitempty"`
+	TapconPortsPerContainer int    `json:"tapcon-ports-per-container,omitempty"`
+	TapconPolicy            string `json:"tapcon-policy,omitempty"`
 }
 
 // bridgeConfig stores all the bridge driver specific
@@ -95,6 +103,11 @@ func (config *Config) InstallFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&config.SeccompProfile, "seccomp-profile", "", "Path to seccomp profile")
 	flags.BoolVar(&config.UseTapcon, "tapcon", false, "Set Tapcon mode for trusted container building")
 	flags.StringVar(&config.TapconMetadataService, "metadata-service", "", "Metadata service url for tapcon, in form of http://<ip>:<port>/ ")
+	flags.StringVar(&config.TapconAttestor, "tapcon-attestor", defaultTapconAttestor, "Attestation backend for tapcon (latte, remote or memory)")
+	flags.StringVar(&config.TapconDaemonPath, "tapcon-daemon-path", "", "Address of the attestation service, defaults to "+attestation.DefaultDaemonPath)
+	flags.StringVar(&config.TapconPortRange, "tapcon-port-range", defaultTapconPortRange, "Source port range divided among tapcon containers")
+	flags.IntVar(&config.TapconPortsPerContainer, "tapcon-ports-per-container", defaultTapconPortsPerContainer, "Number of source ports allocated to each tapcon container")
+	flags.StringVar(&config.TapconPolicy, "tapcon-policy", tapconPolicyOff, "Handling of unendorsed images on pull, load and start of tapcon containers (enforce, warn or off)")
 
 	config.attachExperimentalFlags(flags)
 }
@@ -6,7 +6,6 @@
 package daemon
 
 import (
-	"C"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -25,9 +24,11 @@ import (
 	"github.com/docker/docker/api/types"
 	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/container"
+	"github.com/docker/docker/daemon/attestation"
 	"github.com/docker/docker/daemon/events"
 	"github.com/docker/docker/daemon/exec"
 	"github.com/docker/docker/daemon/initlayer"
+	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/plugin"
 	"github.com/docker/libnetwork/cluster"
@@ -58,12 +59,6 @@ import (
 	"github.com/docker/libtrust"
 	"github.com/pkg/errors"
 )
-import "unsafe"
-
-// #include "libport.h"
-// #include <stdlib.h>
-// #cgo LDFLAGS: -lport
-import "C"
 
 var (
 	// DefaultRuntimeBinary is the default runtime to be used by
@@ -112,6 +107,12 @@ type Daemon struct {
 	defaultIsolation          containertypes.Isolation // Default isolation mode on Windows
 	clusterProvider           cluster.Provider
 	cluster                   Cluster
+	attestor                  attestation.Attestor
+	tapconPorts               *tapconPortAllocator
+	tapconFirewallLock        sync.Mutex
+	tapconAudit               *tapconAudit
+	tapconPrincipalsLock      sync.Mutex
+	buildCaches               buildCacheLocks
 
 	seccompProfile     []byte
 	seccompProfilePath string
@@ -125,6 +126,7 @@ func (daemon *Daemon) HasExperimental() bool {
 	return false
 }
 
+// TapconModeOn returns whether the daemon runs in tapcon mode.
 func (daemon *Daemon) TapconModeOn() bool {
 	if daemon.configStore.UseTapcon && daemon.configStore.TapconMetadataService != "" {
 		return true
@@ -132,19 +134,70 @@ func (daemon *Daemon) TapconModeOn() bool {
 	return false
 }
 
-func (daemon *Daemon) InitTapcon() {
-	logrus.Infof("Initializing Tapcon: %v, %v\n", daemon.configStore.UseTapcon,
+// initTapcon sets up the attestation backend, the audit log and the port
+// allocator used in tapcon mode. It runs before the containers are
+// restored, as restarting them registers their principals and allocates
+// their ports.
+func (daemon *Daemon) initTapcon() error {
+	logrus.Infof("Initializing Tapcon: %v, %v", daemon.configStore.UseTapcon,
 		daemon.configStore.TapconMetadataService)
-	if daemon.TapconModeOn() {
-		auth_id := C.CString("")     // let liblatte to determine
-		daemon_path := C.CString("") // use default
-		ret, _ := C.liblatte_init(auth_id, 0, daemon_path)
-		if ret != 0 {
-			logrus.Errorf("error initialize libport: %d", ret)
+	if !daemon.TapconModeOn() {
+		return nil
+	}
+	attestor, err := attestation.New(daemon.configStore.TapconAttestor, attestation.Options{
+		DaemonPath: daemon.configStore.TapconDaemonPath,
+	})
+	if err != nil {
+		return err
+	}
+	if err := validateTapconPolicy(daemon.configStore.TapconPolicy, attestor); err != nil {
+		return err
+	}
+	// let the attestation service determine our identity
+	if err := attestor.Init("", false); err != nil {
+		return err
+	}
+	auditDir := filepath.Join(daemon.root, "tapcon")
+	if err := os.MkdirAll(auditDir, 0700); err != nil {
+		return err
+	}
+	ports, err := newTapconPortAllocator(daemon.configStore.TapconPortRange, daemon.configStore.TapconPortsPerContainer)
+	if err != nil {
+		return err
+	}
+	audit, err := newTapconAudit(filepath.Join(auditDir, "audit.log"))
+	if err != nil {
+		return err
+	}
+	daemon.attestor = attestor
+	daemon.tapconPorts = ports
+	daemon.tapconAudit = audit
+	return nil
+}
+
+// restoreTapcon reserves the ports of the tapcon containers which kept
+// running across a daemon restart, forgets the principals of the containers
+// which exited while the daemon was down, and reprograms the firewall of the
+// running tapcon containers. It runs once the running containers are
+// restored, before the others are restarted and allocate ports.
+func (daemon *Daemon) restoreTapcon() {
+	if !daemon.TapconModeOn() {
+		return
+	}
+	running := daemon.tapconRunning()
+	for _, r := range running {
+		c := r.container
+		if c.TapconPortMin == 0 {
+			continue
+		}
+		if err := daemon.tapconPorts.Reserve(c.ID, c.TapconPortMin, c.TapconPortMax); err != nil {
+			logrus.Errorf("Failed to restore tapcon ports of container %s: %v", c.ID, err)
 		}
-		C.free(unsafe.Pointer(auth_id))
-		C.free(unsafe.Pointer(daemon_path))
 	}
+	daemon.tapconPrincipalsLock.Lock()
+	daemon.tapconDeleteRecorded(daemon.tapconStalePrincipals(running))
+	daemon.tapconPrincipalsLock.Unlock()
+	daemon.tapconReconcileFirewall()
 }
 
 func (daemon *Daemon) restore() error {
@@ -323,6 +376,8 @@ func (daemon *Daemon) restore() error {
 		}
 	}
 
+	daemon.restoreTapcon()
+
 	group := sync.WaitGroup{}
 	for c, notifier := range restartContainers {
 		group.Add(1)
@@ -604,6 +659,15 @@ func NewDaemon(config *Config, registryService registry.Service, containerdRemot
 		return nil, errors.Wrap(err, "couldn't create plugin manager")
 	}
 
+	// The default log driver may be a plugin, so its options are only
+	// validated once the plugins are set up.
+	logger.RegisterPluginGetter(d.PluginStore)
+	if len(config.LogConfig.Config) > 0 {
+		if err := logger.ValidateLogOpts(config.LogConfig.Type, config.LogConfig.Config); err != nil {
+			return nil, fmt.Errorf("Failed to set log opts: %v", err)
+		}
+	}
+
 	d.layerStore, err = layer.NewStoreFromOptions(layer.StoreOptions{
 		StorePath:                 config.Root,
 		MetadataStorePathTemplate: filepath.Join(config.Root, "image", "%s", "layerdb"),
@@ -720,6 +784,10 @@ func NewDaemon(config *Config, registryService registry.Service, containerdRemot
 		return nil, err
 	}
 
+	if err := d.initTapcon(); err != nil {
+		return nil, fmt.Errorf("Error initializing tapcon: %v", err)
+	}
+
 	if err := d.restore(); err != nil {
 		return nil, err
 	}
@@ -864,6 +932,12 @@ func (daemon *Daemon) Shutdown() error {
 		daemon.netController.Stop()
 	}
 
+	if daemon.tapconAudit != nil {
+		if err := daemon.tapconAudit.Close(); err != nil {
+			logrus.Errorf("Error closing tapcon audit log: %v", err)
+		}
+	}
+
 	if err := daemon.cleanupMounts(); err != nil {
 		return err
 	}
@@ -3,10 +3,13 @@ package daemon
 import (
 	"fmt"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/builder"
 	"github.com/docker/docker/image"
+	"github.com/docker/docker/layer"
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/reference"
+	"github.com/opencontainers/go-digest"
 )
 
 // ErrImageDoesNotExist is error returned when no image can be found for a reference.
@@ -74,3 +77,73 @@ func (daemon *Daemon) GetImageOnBuild(name string) (builder.Image, error) {
 	}
 	return img, nil
 }
+
+// GetRepoDigestsOnBuild returns the digests of the manifests the image
+// `imageID` was pulled with from the repository of `name`. If `name` itself
+// has a digest, only that digest is returned.
+func (daemon *Daemon) GetRepoDigestsOnBuild(name string, imageID string) ([]string, error) {
+	ref, err := reference.ParseNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	if canonical, ok := ref.(reference.Canonical); ok {
+		return []string{canonical.Digest().String()}, nil
+	}
+
+	var digests []string
+	for _, r := range daemon.referenceStore.References(digest.Digest(imageID)) {
+		if canonical, ok := r.(reference.Canonical); ok && canonical.Name() == ref.Name() {
+			digests = append(digests, canonical.Digest().String())
+		}
+	}
+	return digests, nil
+}
+
+// LayerSizeOnBuild returns the size of the layer the last instruction of the
+// image `imageID` added, 0 if it added none.
+func (daemon *Daemon) LayerSizeOnBuild(imageID string) (int64, error) {
+	img, err := daemon.GetImage(imageID)
+	if err != nil {
+		return 0, err
+	}
+	if len(img.History) == 0 || img.History[len(img.History)-1].EmptyLayer || len(img.RootFS.DiffIDs) == 0 {
+		return 0, nil
+	}
+	l, err := daemon.layerStore.Get(img.RootFS.ChainID())
+	if err != nil {
+		return 0, err
+	}
+	defer layer.ReleaseAndLog(daemon.layerStore, l)
+	return l.DiffSize()
+}
+
+// MountImage mounts the filesystem of the image referenced by `name` for the
+// builder to copy files from. The image is mounted with a writable layer on
+// top, which is thrown away on release.
+func (daemon *Daemon) MountImage(name string) (string, func() error, error) {
+	img, err := daemon.GetImage(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rwLayer, err := daemon.layerStore.CreateRWLayer(stringid.GenerateRandomID(), img.RootFS.ChainID(), nil)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := rwLayer.Mount("")
+	if err != nil {
+		if _, err := daemon.layerStore.ReleaseRWLayer(rwLayer); err != nil {
+			logrus.Errorf("Error releasing the layer of image %s: %v", name, err)
+		}
+		return "", nil, err
+	}
+
+	release := func() error {
+		if err := rwLayer.Unmount(); err != nil {
+			return err
+		}
+		_, err := daemon.layerStore.ReleaseRWLayer(rwLayer)
+		return err
+	}
+	return dir, release, nil
+}
@@ -20,6 +20,11 @@ func (daemon *Daemon) ExportImage(names []string, outStream io.Writer) error {
 // complement of ImageExport.  The input stream is an uncompressed tar
 // ball containing images and metadata.
 func (daemon *Daemon) LoadImage(inTar io.ReadCloser, outStream io.Writer, quiet bool) error {
-	imageExporter := tarexport.NewTarExporter(daemon.imageStore, daemon.layerStore, daemon.referenceStore, daemon)
-	return imageExporter.Load(inTar, outStream, quiet)
+	loader := &tapconImageLoader{Daemon: daemon}
+	refs := &tapconReferenceRecorder{Store: daemon.referenceStore}
+	imageExporter := tarexport.NewTarExporter(daemon.imageStore, daemon.layerStore, refs, loader)
+	if err := imageExporter.Load(inTar, outStream, quiet); err != nil {
+		return err
+	}
+	return daemon.tapconCheckLoad(loader.loaded, refs.added)
 }
@@ -72,6 +72,32 @@ func (daemon *Daemon) LookupImage(name string) (*types.ImageInspect, error) {
 		Size:            size,
 		VirtualSize:     size, // TODO: field unused, deprecate
 		RootFS:          rootFSToAPIType(img.RootFS),
+		BaseImage:       img.BaseImage,
+	}
+
+	if src := img.Source; src != nil {
+		imageInspect.Provenance = &types.ImageProvenance{
+			Repo:           src.Repo,
+			UnverifiedRepo: src.UnverifiedRepo,
+			Revision:       src.Revision,
+			Dir:            src.Dir,
+			File:           src.File,
+			FileDigest:     src.FileDigest,
+			BuildArgs:      src.BuildArgs,
+			BaseImage:      src.BaseImage,
+			Images:         src.Images,
+			Builder:        src.Builder,
+			Time:           src.Time,
+		}
+		for _, g := range src.Git {
+			imageInspect.Provenance.Git = append(imageInspect.Provenance.Git, types.GitSource{
+				Repo:   g.Repo,
+				Ref:    g.Ref,
+				Commit: g.Commit,
+				Dir:    g.Dir,
+				Dest:   g.Dest,
+			})
+		}
 	}
 
 	imageInspect.GraphDriver.Name = daemon.GraphDriverName()
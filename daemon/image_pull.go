@@ -43,7 +43,11 @@ func (daemon *Daemon) PullImage(ctx context.Context, image, tag string, metaHead
 		}
 	}
 
-	return daemon.pullImageWithReference(ctx, ref, metaHeaders, authConfig, outStream)
+	refs := &tapconReferenceRecorder{Store: daemon.referenceStore}
+	if err := daemon.pullImageWithReference(ctx, ref, refs, metaHeaders, authConfig, outStream); err != nil {
+		return err
+	}
+	return daemon.tapconCheckPull(ref, refs.added)
 }
 
 // PullOnBuild tells Docker to pull image referenced by `name`.
@@ -69,13 +73,13 @@ func (daemon *Daemon) PullOnBuild(ctx context.Context, name string, authConfigs
 		pullRegistryAuth = &resolvedConfig
 	}
 
-	if err := daemon.pullImageWithReference(ctx, ref, nil, pullRegistryAuth, output); err != nil {
+	if err := daemon.pullImageWithReference(ctx, ref, daemon.referenceStore, nil, pullRegistryAuth, output); err != nil {
 		return nil, err
 	}
 	return daemon.GetImage(name)
 }
 
-func (daemon *Daemon) pullImageWithReference(ctx context.Context, ref reference.Named, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
+func (daemon *Daemon) pullImageWithReference(ctx context.Context, ref reference.Named, referenceStore reference.Store, metaHeaders map[string][]string, authConfig *types.AuthConfig, outStream io.Writer) error {
 	// Include a buffer so that slow client connections don't affect
 	// transfer performance.
 	progressChan := make(chan progress.Progress, 100)
@@ -98,7 +102,7 @@ func (daemon *Daemon) pullImageWithReference(ctx context.Context, ref reference.
 			ImageEventLogger: daemon.LogImageEvent,
 			MetadataStore:    daemon.distributionMetadataStore,
 			ImageStore:       distribution.NewImageConfigStoreFromStore(daemon.imageStore),
-			ReferenceStore:   daemon.referenceStore,
+			ReferenceStore:   referenceStore,
 		},
 		DownloadManager: daemon.downloadManager,
 		Schema2Types:    distribution.ImageTypes,
@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/api/errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/image"
+)
+
+// AttachBuildTrace stores the trace report of a build with the image it
+// produced.
+func (daemon *Daemon) AttachBuildTrace(imageID string, trace *types.BuildTrace) error {
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	return daemon.imageStore.SetTrace(image.ID(imageID), data)
+}
+
+// ImageTrace returns the trace report of the build which produced the image
+// identified by name.
+func (daemon *Daemon) ImageTrace(name string) (*types.BuildTrace, error) {
+	img, err := daemon.GetImage(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := daemon.imageStore.GetTrace(img.ID())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.NewRequestNotFoundError(fmt.Errorf("No build trace attached to image: %s", name))
+		}
+		return nil, err
+	}
+	var trace types.BuildTrace
+	if err := json.Unmarshal(data, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
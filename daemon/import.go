@@ -122,6 +122,10 @@ func (daemon *Daemon) ImportImage(src string, repository, tag string, msg string
 		return err
 	}
 
+	if err := daemon.tapconRejectImage(id.String(), id.String(), "import", nil); err != nil {
+		return err
+	}
+
 	// FIXME: connect with commit code and call refstore directly
 	if newRef != nil {
 		if err := daemon.TagImageWithReference(id, newRef); err != nil {
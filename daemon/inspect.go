@@ -159,6 +159,8 @@ func (daemon *Daemon) getInspectData(container *container.Container, size bool)
 		ProcessLabel: container.ProcessLabel,
 		ExecIDs:      container.GetExecIDs(),
 		HostConfig:   &hostConfig,
+
+		TapconConfigDigest: container.TapconConfigDigest,
 	}
 
 	var (
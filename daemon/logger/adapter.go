@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types/plugins/logdriver"
+	getter "github.com/docker/docker/pkg/plugingetter"
+	"github.com/pkg/errors"
+)
+
+// pluginAdapter is a logger which sends the messages to a log driver
+// plugin.
+type pluginAdapter struct {
+	driverName string
+	file       string // path of the FIFO, as seen from the plugin
+	fifoPath   string // path of the FIFO on the host
+	info       Info
+	plugin     *logPluginProxy
+
+	mu     sync.Mutex // protects the fields below
+	stream io.WriteCloser
+	enc    logdriver.LogEntryEncoder
+	buf    logdriver.LogEntry
+}
+
+func (a *pluginAdapter) Log(msg *Message) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.buf.Source = msg.Source
+	a.buf.TimeNano = msg.Timestamp.UnixNano()
+	a.buf.Line = msg.Line
+	a.buf.Partial = msg.Partial
+	err := a.enc.Encode(&a.buf)
+	a.buf.Reset()
+	return err
+}
+
+func (a *pluginAdapter) Name() string {
+	return a.driverName
+}
+
+// Close tells the plugin that the container stopped logging, and releases
+// the plugin.
+func (a *pluginAdapter) Close() error {
+	err := a.plugin.StopLogging(a.file)
+	a.closeStream()
+	if _, rerr := pluginGetter.Get(a.driverName, extName, getter.Release); rerr != nil {
+		logrus.Debugf("failed to release log driver plugin %s: %v", a.driverName, rerr)
+	}
+	return err
+}
+
+func (a *pluginAdapter) closeStream() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.stream.Close(); err != nil {
+		logrus.Debugf("failed to close the log FIFO %s: %v", a.fifoPath, err)
+	}
+	if err := os.Remove(a.fifoPath); err != nil && !os.IsNotExist(err) {
+		logrus.Debugf("failed to remove the log FIFO %s: %v", a.fifoPath, err)
+	}
+}
+
+// pluginAdapterWithRead is a logger of a plugin which can read back the
+// messages it logged.
+type pluginAdapterWithRead struct {
+	*pluginAdapter
+}
+
+func (a *pluginAdapterWithRead) ReadLogs(config ReadConfig) *LogWatcher {
+	watcher := NewLogWatcher()
+
+	go func() {
+		defer close(watcher.Msg)
+
+		stream, err := a.plugin.ReadLogs(a.info, config)
+		if err != nil {
+			watcher.Err <- errors.Wrap(err, "error getting the logs from the log driver plugin")
+			return
+		}
+		defer stream.Close()
+
+		go func() {
+			// unblock the decoder if the reader goes away while following
+			<-watcher.WatchClose()
+			stream.Close()
+		}()
+
+		dec := logdriver.NewLogEntryDecoder(stream)
+		for {
+			var entry logdriver.LogEntry
+			if err := dec.Decode(&entry); err != nil {
+				if err == io.EOF {
+					return
+				}
+				select {
+				case <-watcher.WatchClose():
+				case watcher.Err <- errors.Wrap(err, "error decoding the logs of the log driver plugin"):
+				}
+				return
+			}
+
+			msg := &Message{
+				Line:      entry.Line,
+				Source:    entry.Source,
+				Timestamp: time.Unix(0, entry.TimeNano),
+				Partial:   entry.Partial,
+			}
+			// the plugin should only send the messages selected by config,
+			// but not all of them do
+			if !config.Since.IsZero() && msg.Timestamp.Before(config.Since) {
+				continue
+			}
+			if !config.Until.IsZero() && msg.Timestamp.After(config.Until) {
+				return
+			}
+			if !config.Filter.Match(msg) {
+				continue
+			}
+
+			select {
+			case watcher.Msg <- msg:
+			case <-watcher.WatchClose():
+				return
+			}
+		}
+	}()
+
+	return watcher
+}
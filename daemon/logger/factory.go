@@ -3,6 +3,11 @@ package logger
 import (
 	"fmt"
 	"sync"
+
+	containertypes "github.com/docker/docker/api/types/container"
+	getter "github.com/docker/docker/pkg/plugingetter"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
 )
 
 // Creator builds a logging driver instance with given context.
@@ -19,21 +24,19 @@ type logdriverFactory struct {
 }
 
 func (lf *logdriverFactory) register(name string, c Creator) error {
-	if lf.driverRegistered(name) {
+	lf.m.Lock()
+	defer lf.m.Unlock()
+
+	if _, ok := lf.registry[name]; ok {
 		return fmt.Errorf("logger: log driver named '%s' is already registered", name)
 	}
-
-	lf.m.Lock()
 	lf.registry[name] = c
-	lf.m.Unlock()
 	return nil
 }
 
 func (lf *logdriverFactory) driverRegistered(name string) bool {
-	lf.m.Lock()
-	_, ok := lf.registry[name]
-	lf.m.Unlock()
-	return ok
+	_, err := lf.get(name)
+	return err == nil
 }
 
 func (lf *logdriverFactory) registerLogOptValidator(name string, l LogOptValidator) error {
@@ -49,11 +52,10 @@ func (lf *logdriverFactory) registerLogOptValidator(name string, l LogOptValidat
 
 func (lf *logdriverFactory) get(name string) (Creator, error) {
 	lf.m.Lock()
-	defer lf.m.Unlock()
-
 	c, ok := lf.registry[name]
+	lf.m.Unlock()
 	if !ok {
-		return c, fmt.Errorf("logger: no log driver named '%s' is registered", name)
+		return getPlugin(name, getter.Lookup)
 	}
 	return c, nil
 }
@@ -81,24 +83,77 @@ func RegisterLogOptValidator(name string, l LogOptValidator) error {
 }
 
 // GetLogDriver provides the logging driver builder for a logging driver name.
+// The drivers which are not compiled in are looked up as log driver plugins.
 func GetLogDriver(name string) (Creator, error) {
 	return factory.get(name)
 }
 
+// builtInLogOpts are the options every log driver supports, which are
+// handled by the daemon rather than by the drivers.
+var builtInLogOpts = map[string]bool{
+	"mode":            true,
+	"max-buffer-size": true,
+}
+
+// externalValidators validate the built-in options added with
+// AddBuiltinLogOpts.
+var externalValidators []LogOptValidator
+
+// AddBuiltinLogOpts adds options every log driver supports, which are not
+// passed to the validators of the drivers.
+func AddBuiltinLogOpts(opts map[string]bool) {
+	for k, v := range opts {
+		builtInLogOpts[k] = v
+	}
+}
+
+// RegisterExternalValidator registers a validator of the options of every
+// log driver, such as the options added with AddBuiltinLogOpts.
+func RegisterExternalValidator(v LogOptValidator) {
+	externalValidators = append(externalValidators, v)
+}
+
 // ValidateLogOpts checks the options for the given log driver. The
-// options supported are specific to the LogDriver implementation.
+// options supported are specific to the LogDriver implementation, besides
+// the mode options all of them support.
 func ValidateLogOpts(name string, cfg map[string]string) error {
 	if name == "none" {
 		return nil
 	}
 
+	switch containertypes.LogMode(cfg["mode"]) {
+	case containertypes.LogModeUnset, containertypes.LogModeBlocking, containertypes.LogModeNonBlock:
+	default:
+		return fmt.Errorf("logger: logging mode not supported: %s", cfg["mode"])
+	}
+	if s, ok := cfg["max-buffer-size"]; ok {
+		if containertypes.LogMode(cfg["mode"]) != containertypes.LogModeNonBlock {
+			return fmt.Errorf("logger: max-buffer-size option is only supported with 'mode=%s'", containertypes.LogModeNonBlock)
+		}
+		if _, err := units.RAMInBytes(s); err != nil {
+			return errors.Wrap(err, "logger: error parsing option max-buffer-size")
+		}
+	}
+
+	for _, v := range externalValidators {
+		if err := v(cfg); err != nil {
+			return err
+		}
+	}
+
 	if !factory.driverRegistered(name) {
 		return fmt.Errorf("logger: no log driver named '%s' is registered", name)
 	}
 
 	validator := factory.getLogOptValidator(name)
-	if validator != nil {
-		return validator(cfg)
+	if validator == nil {
+		return nil
 	}
-	return nil
+	driverOpts := make(map[string]string, len(cfg))
+	for k, v := range cfg {
+		if !builtInLogOpts[k] {
+			driverOpts[k] = v
+		}
+	}
+	return validator(driverOpts)
 }
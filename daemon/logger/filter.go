@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MessageFilter selects the messages read from the logs by their stream and
+// their line, so that the readers of the logs only send the messages asked
+// for. It is created with NewMessageFilter.
+type MessageFilter struct {
+	// Sources are the streams the messages are selected from, such as
+	// "stdout". The messages of every stream are selected if it is empty.
+	Sources []string
+	// Pattern is a regular expression the lines of the messages selected
+	// match, if it is set.
+	Pattern string
+
+	re *regexp.Regexp
+}
+
+// NewMessageFilter returns a filter of the messages of the streams sources
+// whose lines match pattern.
+func NewMessageFilter(sources []string, pattern string) (*MessageFilter, error) {
+	f := &MessageFilter{Sources: sources, Pattern: pattern}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log pattern %q: %v", pattern, err)
+		}
+		f.re = re
+	}
+	return f, nil
+}
+
+// Match reports whether msg is selected by f. A nil filter selects every
+// message.
+func (f *MessageFilter) Match(msg *Message) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Sources) > 0 {
+		found := false
+		for _, s := range f.Sources {
+			if s == msg.Source {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return f.re == nil || f.re.Match(msg.Line)
+}
+
+// SelectsAll reports whether f selects every message. The last lines asked
+// for by ReadConfig.Tail are counted among the messages selected, so the
+// readers only find them from the end of the logs when f selects all of
+// them.
+func (f *MessageFilter) SelectsAll() bool {
+	return f == nil || (len(f.Sources) == 0 && f.Pattern == "")
+}
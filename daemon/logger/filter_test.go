@@ -0,0 +1,39 @@
+package logger
+
+import "testing"
+
+func TestMessageFilter(t *testing.T) {
+	stdout := &Message{Source: "stdout", Line: []byte("INFO started")}
+	stderr := &Message{Source: "stderr", Line: []byte("ERROR failed")}
+
+	var none *MessageFilter
+	if !none.Match(stdout) || !none.Match(stderr) {
+		t.Fatal("expected a nil filter to match every message")
+	}
+
+	cases := []struct {
+		sources        []string
+		pattern        string
+		stdout, stderr bool
+	}{
+		{nil, "", true, true},
+		{[]string{"stderr"}, "", false, true},
+		{[]string{"stdout", "stderr"}, "", true, true},
+		{nil, "ERROR", false, true},
+		{nil, "^(INFO|ERROR) ", true, true},
+		{[]string{"stdout"}, "ERROR", false, false},
+	}
+	for _, c := range cases {
+		f, err := NewMessageFilter(c.sources, c.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Match(stdout) != c.stdout || f.Match(stderr) != c.stderr {
+			t.Fatalf("filter of %v and %q: expected %v and %v, got %v and %v", c.sources, c.pattern, c.stdout, c.stderr, f.Match(stdout), f.Match(stderr))
+		}
+	}
+
+	if _, err := NewMessageFilter(nil, "(ERROR"); err == nil {
+		t.Fatal("expected an error creating a filter with an invalid pattern")
+	}
+}
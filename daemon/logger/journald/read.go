@@ -169,11 +169,55 @@ func (s *journald) Close() error {
 	return nil
 }
 
-func (s *journald) drainJournal(logWatcher *logger.LogWatcher, config logger.ReadConfig, j *C.sd_journal, oldCursor *C.char) *C.char {
+// entrySource recovers the stream name of the entry of j by mapping from the
+// journal priority back to the stream that we would have assigned that value.
+func entrySource(j *C.sd_journal) string {
+	var priority C.int
+	if C.get_priority(j, &priority) != 0 {
+		return ""
+	}
+	switch priority {
+	case C.int(journal.PriErr):
+		return "stderr"
+	case C.int(journal.PriInfo):
+		return "stdout"
+	}
+	return ""
+}
+
+// entrySelected reports whether the entry of j, logged at timestamp, is sent
+// for config, so that the last entries asked for by config.Tail are counted
+// among the ones selected.
+func entrySelected(j *C.sd_journal, config logger.ReadConfig, timestamp time.Time) bool {
+	var msg *C.char
+	var length C.size_t
+	var partial C.int
+	if !config.Until.IsZero() && timestamp.After(config.Until) {
+		return false
+	}
+	if config.Filter.SelectsAll() {
+		return true
+	}
+	i := C.get_message(j, &msg, &length, &partial)
+	if i == -C.ENOENT || i == -C.EADDRNOTAVAIL {
+		return false
+	}
+	line := C.GoBytes(unsafe.Pointer(msg), C.int(length))
+	if partial == 0 {
+		line = append(line, "\n"...)
+	}
+	return config.Filter.Match(&logger.Message{Line: line, Source: entrySource(j)})
+}
+
+// drainJournal sends the entries of the journal from j selected by config,
+// and returns the cursor of the journal and whether it reached an entry
+// after config.Until.
+func (s *journald) drainJournal(logWatcher *logger.LogWatcher, config logger.ReadConfig, j *C.sd_journal, oldCursor *C.char) (*C.char, bool) {
 	var msg, data, cursor *C.char
 	var length C.size_t
 	var stamp C.uint64_t
-	var priority, partial C.int
+	var partial C.int
+	var untilReached bool
 
 	// Walk the journal from here forward until we run out of new entries.
 drain:
@@ -195,22 +239,15 @@ drain:
 			}
 			// Set up the time and text of the entry.
 			timestamp := time.Unix(int64(stamp)/1000000, (int64(stamp)%1000000)*1000)
+			if !config.Until.IsZero() && timestamp.After(config.Until) {
+				untilReached = true
+				break
+			}
 			line := C.GoBytes(unsafe.Pointer(msg), C.int(length))
 			if partial == 0 {
 				line = append(line, "\n"...)
 			}
-			// Recover the stream name by mapping
-			// from the journal priority back to
-			// the stream that we would have
-			// assigned that value.
-			source := ""
-			if C.get_priority(j, &priority) != 0 {
-				source = ""
-			} else if priority == C.int(journal.PriErr) {
-				source = "stderr"
-			} else if priority == C.int(journal.PriInfo) {
-				source = "stdout"
-			}
+			source := entrySource(j)
 			// Retrieve the values of any variables we're adding to the journal.
 			attrs := make(map[string]string)
 			C.sd_journal_restart_data(j)
@@ -221,13 +258,16 @@ drain:
 			if len(attrs) == 0 {
 				attrs = nil
 			}
-			// Send the log message.
-			logWatcher.Msg <- &logger.Message{
+			// Send the log message, if it is selected.
+			m := &logger.Message{
 				Line:      line,
 				Source:    source,
 				Timestamp: timestamp.In(time.UTC),
 				Attrs:     attrs,
 			}
+			if config.Filter.Match(m) {
+				logWatcher.Msg <- m
+			}
 		}
 		// If we're at the end of the journal, we're done (for now).
 		if C.sd_journal_next(j) <= 0 {
@@ -238,7 +278,7 @@ drain:
 	// free(NULL) is safe
 	C.free(unsafe.Pointer(oldCursor))
 	C.sd_journal_get_cursor(j, &cursor)
-	return cursor
+	return cursor, untilReached
 }
 
 func (s *journald) followJournal(logWatcher *logger.LogWatcher, config logger.ReadConfig, j *C.sd_journal, pfd [2]C.int, cursor *C.char) *C.char {
@@ -250,7 +290,11 @@ func (s *journald) followJournal(logWatcher *logger.LogWatcher, config logger.Re
 		// or we hit an error.
 		status := C.wait_for_data_cancelable(j, pfd[0])
 		for status == 1 {
-			cursor = s.drainJournal(logWatcher, config, j, cursor)
+			var untilReached bool
+			cursor, untilReached = s.drainJournal(logWatcher, config, j, cursor)
+			if untilReached {
+				break
+			}
 			status = C.wait_for_data_cancelable(j, pfd[0])
 		}
 		if status < 0 {
@@ -346,7 +390,10 @@ func (s *journald) readLogs(logWatcher *logger.LogWatcher, config logger.ReadCon
 					break
 				}
 			}
-			lines--
+			timestamp := time.Unix(int64(stamp)/1000000, (int64(stamp)%1000000)*1000)
+			if entrySelected(j, config, timestamp) {
+				lines--
+			}
 			// If we're at the start of the journal, or
 			// don't need to back up past any more entries,
 			// stop.
@@ -370,8 +417,8 @@ func (s *journald) readLogs(logWatcher *logger.LogWatcher, config logger.ReadCon
 			return
 		}
 	}
-	cursor = s.drainJournal(logWatcher, config, j, nil)
-	if config.Follow {
+	cursor, untilReached := s.drainJournal(logWatcher, config, j, nil)
+	if config.Follow && !untilReached {
 		// Allocate a descriptor for following the journal, if we'll
 		// need one.  Do it here so that we can report if it fails.
 		if fd := C.sd_journal_get_fd(j); fd < C.int(0) {
@@ -6,6 +6,7 @@ package jsonfilelog
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"sync"
@@ -20,13 +21,17 @@ import (
 // Name is the name of the file that the jsonlogger logs to.
 const Name = "json-file"
 
+var errReadOnly = errors.New("the json-file log reader cannot write logs")
+
 // JSONFileLogger is Logger implementation for default Docker logging.
 type JSONFileLogger struct {
-	buf     *bytes.Buffer
-	writer  *loggerutils.RotateFileWriter
-	mu      sync.Mutex
-	readers map[*logger.LogWatcher]struct{} // stores the active log followers
-	extra   []byte                          // json-encoded extra attributes
+	buf      *bytes.Buffer
+	writer   *loggerutils.RotateFileWriter // nil if the logger only reads logs
+	logPath  string
+	maxFiles int
+	mu       sync.Mutex
+	readers  map[*logger.LogWatcher]struct{} // stores the active log followers
+	extra    []byte                          // json-encoded extra attributes
 }
 
 func init() {
@@ -49,19 +54,12 @@ func New(info logger.Info) (logger.Logger, error) {
 			return nil, err
 		}
 	}
-	var maxFiles = 1
-	if maxFileString, ok := info.Config["max-file"]; ok {
-		var err error
-		maxFiles, err = strconv.Atoi(maxFileString)
-		if err != nil {
-			return nil, err
-		}
-		if maxFiles < 1 {
-			return nil, fmt.Errorf("max-file cannot be less than 1")
-		}
+	maxFiles, err := parseMaxFiles(info.Config)
+	if err != nil {
+		return nil, err
 	}
 
-	writer, err := loggerutils.NewRotateFileWriter(info.LogPath, capval, maxFiles)
+	writer, err := loggerutils.NewRotateFileWriter(info.LogPath, capval, maxFiles, false)
 	if err != nil {
 		return nil, err
 	}
@@ -76,19 +74,55 @@ func New(info logger.Info) (logger.Logger, error) {
 	}
 
 	return &JSONFileLogger{
-		buf:     bytes.NewBuffer(nil),
-		writer:  writer,
-		readers: make(map[*logger.LogWatcher]struct{}),
-		extra:   extra,
+		buf:      bytes.NewBuffer(nil),
+		writer:   writer,
+		logPath:  info.LogPath,
+		maxFiles: maxFiles,
+		readers:  make(map[*logger.LogWatcher]struct{}),
+		extra:    extra,
 	}, nil
 }
 
+// NewReader creates a JSONFileLogger which only reads the logs written to
+// info.LogPath by a JSONFileLogger created with the same info. The log file
+// is neither created nor opened for writing, so the logs cannot be written
+// nor followed.
+func NewReader(info logger.Info) (logger.Logger, error) {
+	maxFiles, err := parseMaxFiles(info.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONFileLogger{
+		logPath:  info.LogPath,
+		maxFiles: maxFiles,
+		readers:  make(map[*logger.LogWatcher]struct{}),
+	}, nil
+}
+
+func parseMaxFiles(cfg map[string]string) (int, error) {
+	maxFileString, ok := cfg["max-file"]
+	if !ok {
+		return 1, nil
+	}
+	maxFiles, err := strconv.Atoi(maxFileString)
+	if err != nil {
+		return 0, err
+	}
+	if maxFiles < 1 {
+		return 0, fmt.Errorf("max-file cannot be less than 1")
+	}
+	return maxFiles, nil
+}
+
 // Log converts logger.Message to jsonlog.JSONLog and serializes it to file.
 func (l *JSONFileLogger) Log(msg *logger.Message) error {
 	timestamp, err := jsonlog.FastTimeMarshalJSON(msg.Timestamp)
 	if err != nil {
 		return err
 	}
+	if l.writer == nil {
+		return errReadOnly
+	}
 	l.mu.Lock()
 	logline := msg.Line
 	if !msg.Partial {
@@ -130,13 +164,16 @@ func ValidateLogOpt(cfg map[string]string) error {
 
 // LogPath returns the location the given json logger logs to.
 func (l *JSONFileLogger) LogPath() string {
-	return l.writer.LogPath()
+	return l.logPath
 }
 
 // Close closes underlying file and signals all readers to stop.
 func (l *JSONFileLogger) Close() error {
+	var err error
 	l.mu.Lock()
-	err := l.writer.Close()
+	if l.writer != nil {
+		err = l.writer.Close()
+	}
 	for r := range l.readers {
 		r.Close()
 		delete(l.readers, r)
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"golang.org/x/net/context"
@@ -52,9 +51,9 @@ func (l *JSONFileLogger) readLogs(logWatcher *logger.LogWatcher, config logger.R
 	// This will block writes!!!
 	l.mu.Lock()
 
-	pth := l.writer.LogPath()
+	pth := l.logPath
 	var files []io.ReadSeeker
-	for i := l.writer.MaxFiles(); i > 1; i-- {
+	for i := l.maxFiles; i > 1; i-- {
 		f, err := os.Open(fmt.Sprintf("%s.%d", pth, i-1))
 		if err != nil {
 			if !os.IsNotExist(err) {
@@ -70,15 +69,21 @@ func (l *JSONFileLogger) readLogs(logWatcher *logger.LogWatcher, config logger.R
 
 	latestFile, err := os.Open(pth)
 	if err != nil {
+		// a reader has no logs to read until the file is written
+		if l.writer == nil && os.IsNotExist(err) {
+			l.mu.Unlock()
+			return
+		}
 		logWatcher.Err <- err
 		l.mu.Unlock()
 		return
 	}
 	defer latestFile.Close()
 
+	var untilReached bool
 	if config.Tail != 0 {
 		tailer := ioutils.MultiReadSeeker(append(files, latestFile)...)
-		tailFile(tailer, logWatcher, config.Tail, config.Since)
+		untilReached = tailFile(tailer, logWatcher, config)
 	}
 
 	// close all the rotated files
@@ -88,7 +93,8 @@ func (l *JSONFileLogger) readLogs(logWatcher *logger.LogWatcher, config logger.R
 		}
 	}
 
-	if !config.Follow {
+	// a reader does not write the logs, so it cannot follow them
+	if !config.Follow || untilReached || l.writer == nil {
 		if err := latestFile.Close(); err != nil {
 			logrus.Errorf("Error closing file: %v", err)
 		}
@@ -104,7 +110,7 @@ func (l *JSONFileLogger) readLogs(logWatcher *logger.LogWatcher, config logger.R
 	l.mu.Unlock()
 
 	notifyRotate := l.writer.NotifyRotate()
-	followLogs(latestFile, logWatcher, notifyRotate, config.Since)
+	followLogs(latestFile, logWatcher, notifyRotate, config)
 
 	l.mu.Lock()
 	delete(l.readers, logWatcher)
@@ -113,17 +119,29 @@ func (l *JSONFileLogger) readLogs(logWatcher *logger.LogWatcher, config logger.R
 	l.writer.NotifyRotateEvict(notifyRotate)
 }
 
-func tailFile(f io.ReadSeeker, logWatcher *logger.LogWatcher, tail int, since time.Time) {
+// tailFile sends the messages of f selected by config. It returns true if
+// it reached a message after config.Until.
+func tailFile(f io.ReadSeeker, logWatcher *logger.LogWatcher, config logger.ReadConfig) bool {
 	var rdr io.Reader
 	rdr = f
-	if tail > 0 {
-		ls, err := tailfile.TailFile(f, tail)
+	// the last lines are counted among the messages selected, so they are
+	// looked for in the whole file unless every message since config.Since
+	// is selected
+	selective := config.Tail > 0 && (!config.Until.IsZero() || !config.Filter.SelectsAll())
+	if config.Tail > 0 && !selective {
+		ls, err := tailfile.TailFile(f, config.Tail)
 		if err != nil {
 			logWatcher.Err <- err
-			return
+			return false
 		}
 		rdr = bytes.NewBuffer(bytes.Join(ls, []byte("\n")))
 	}
+	var tail []*logger.Message
+	sendTail := func() {
+		for _, msg := range tail {
+			logWatcher.Msg <- msg
+		}
+	}
 	dec := json.NewDecoder(rdr)
 	l := &jsonlog.JSONLog{}
 	for {
@@ -131,10 +149,25 @@ func tailFile(f io.ReadSeeker, logWatcher *logger.LogWatcher, tail int, since ti
 		if err != nil {
 			if err != io.EOF {
 				logWatcher.Err <- err
+				return false
 			}
-			return
+			sendTail()
+			return false
 		}
-		if !since.IsZero() && msg.Timestamp.Before(since) {
+		if !config.Since.IsZero() && msg.Timestamp.Before(config.Since) {
+			continue
+		}
+		if !config.Until.IsZero() && msg.Timestamp.After(config.Until) {
+			sendTail()
+			return true
+		}
+		if !config.Filter.Match(msg) {
+			continue
+		}
+		if selective {
+			if tail = append(tail, msg); len(tail) > config.Tail {
+				tail = tail[1:]
+			}
 			continue
 		}
 		logWatcher.Msg <- msg
@@ -161,7 +194,7 @@ func watchFile(name string) (filenotify.FileWatcher, error) {
 	return fileWatcher, nil
 }
 
-func followLogs(f *os.File, logWatcher *logger.LogWatcher, notifyRotate chan interface{}, since time.Time) {
+func followLogs(f *os.File, logWatcher *logger.LogWatcher, notifyRotate chan interface{}, config logger.ReadConfig) {
 	dec := json.NewDecoder(f)
 	l := &jsonlog.JSONLog{}
 
@@ -297,7 +330,13 @@ func followLogs(f *os.File, logWatcher *logger.LogWatcher, notifyRotate chan int
 		}
 
 		retries = 0 // reset retries since we've succeeded
-		if !since.IsZero() && msg.Timestamp.Before(since) {
+		if !config.Since.IsZero() && msg.Timestamp.Before(config.Since) {
+			continue
+		}
+		if !config.Until.IsZero() && msg.Timestamp.After(config.Until) {
+			return
+		}
+		if !config.Filter.Match(msg) {
 			continue
 		}
 		select {
@@ -309,7 +348,13 @@ func followLogs(f *os.File, logWatcher *logger.LogWatcher, notifyRotate chan int
 				if err != nil {
 					return
 				}
-				if !since.IsZero() && msg.Timestamp.Before(since) {
+				if !config.Since.IsZero() && msg.Timestamp.Before(config.Since) {
+					continue
+				}
+				if !config.Until.IsZero() && msg.Timestamp.After(config.Until) {
+					return
+				}
+				if !config.Filter.Match(msg) {
 					continue
 				}
 				logWatcher.Msg <- msg
@@ -0,0 +1,68 @@
+package jsonfilelog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/pkg/jsonlog"
+)
+
+func TestTailFileSelection(t *testing.T) {
+	var buf bytes.Buffer
+	epoch := time.Unix(1500000000, 0).UTC()
+	for i, line := range []string{"a INFO", "b ERROR", "c INFO", "d ERROR", "e ERROR"} {
+		stream := "stdout"
+		if i%2 == 1 {
+			stream = "stderr"
+		}
+		timestamp, err := jsonlog.FastTimeMarshalJSON(epoch.Add(time.Duration(i) * time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &jsonlog.JSONLogs{Log: []byte(line + "\n"), Stream: stream, Created: timestamp}
+		if err := l.MarshalJSONBuf(&buf); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteByte('\n')
+	}
+
+	filter, err := logger.NewMessageFilter([]string{"stderr"}, "ERROR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		config       logger.ReadConfig
+		lines        []string
+		untilReached bool
+	}{
+		{logger.ReadConfig{Tail: -1}, []string{"a INFO\n", "b ERROR\n", "c INFO\n", "d ERROR\n", "e ERROR\n"}, false},
+		{logger.ReadConfig{Tail: -1, Until: epoch.Add(2 * time.Second)}, []string{"a INFO\n", "b ERROR\n", "c INFO\n"}, true},
+		{logger.ReadConfig{Tail: -1, Since: epoch.Add(time.Second), Until: epoch.Add(time.Second)}, []string{"b ERROR\n"}, true},
+		{logger.ReadConfig{Tail: -1, Filter: filter}, []string{"b ERROR\n", "d ERROR\n"}, false},
+		// the last lines are counted among the messages selected
+		{logger.ReadConfig{Tail: 2, Filter: filter}, []string{"b ERROR\n", "d ERROR\n"}, false},
+		{logger.ReadConfig{Tail: 1, Filter: filter}, []string{"d ERROR\n"}, false},
+		{logger.ReadConfig{Tail: 2, Until: epoch.Add(2 * time.Second)}, []string{"b ERROR\n", "c INFO\n"}, true},
+		{logger.ReadConfig{Tail: 2, Since: epoch.Add(3 * time.Second)}, []string{"d ERROR\n", "e ERROR\n"}, false},
+	}
+	for _, c := range cases {
+		watcher := logger.NewLogWatcher()
+		untilReached := tailFile(bytes.NewReader(buf.Bytes()), watcher, c.config)
+		close(watcher.Msg)
+		select {
+		case err := <-watcher.Err:
+			t.Fatal(err)
+		default:
+		}
+		var lines []string
+		for msg := range watcher.Msg {
+			lines = append(lines, string(msg.Line))
+		}
+		if !reflect.DeepEqual(lines, c.lines) || untilReached != c.untilReached {
+			t.Fatalf("%+v: expected %q and %v, got %q and %v", c.config, c.lines, c.untilReached, lines, untilReached)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package local
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// The index of a log file is a sequence of fixed-size records, one every
+// indexInterval entries, which give the offset and the time of the entry.
+// Its first record is the first entry of the log file. Readers seek to the
+// record before the entries they look for, and only decode the entries
+// from there.
+
+const (
+	indexInterval  = 64
+	indexRecordLen = 24
+)
+
+type indexRecord struct {
+	entry    int64 // number of the entry in the log file, from 0
+	offset   int64 // offset of the entry in the log file
+	timeNano int64 // time of the entry
+}
+
+// indexPath returns the path of the index of the log file at logPath.
+func indexPath(logPath string) string {
+	return logPath + ".idx"
+}
+
+// rotatedIndexPath returns the path of the index of the log file logPath
+// rotated i times.
+func rotatedIndexPath(logPath string, i int) string {
+	return logPath + "." + strconv.Itoa(i) + ".idx"
+}
+
+// rotateIndexes renames the indexes of the log files at logPath as the log
+// files are renamed by their rotation.
+func rotateIndexes(logPath string, maxFiles int) error {
+	if maxFiles < 2 {
+		return os.Remove(indexPath(logPath))
+	}
+	for i := maxFiles - 1; i > 1; i-- {
+		if err := os.Rename(rotatedIndexPath(logPath, i-1), rotatedIndexPath(logPath, i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(indexPath(logPath), rotatedIndexPath(logPath, 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readIndex reads the records of the index at path. A missing index has no
+// records, and an incomplete last record is ignored.
+func readIndex(path string) ([]indexRecord, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	records := make([]indexRecord, 0, len(data)/indexRecordLen)
+	for ; len(data) >= indexRecordLen; data = data[indexRecordLen:] {
+		records = append(records, indexRecord{
+			entry:    int64(binary.BigEndian.Uint64(data)),
+			offset:   int64(binary.BigEndian.Uint64(data[8:])),
+			timeNano: int64(binary.BigEndian.Uint64(data[16:])),
+		})
+	}
+	return records, nil
+}
+
+// writeIndexRecord appends r to the index w.
+func writeIndexRecord(w io.Writer, r indexRecord) error {
+	var buf [indexRecordLen]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(r.entry))
+	binary.BigEndian.PutUint64(buf[8:], uint64(r.offset))
+	binary.BigEndian.PutUint64(buf[16:], uint64(r.timeNano))
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// seekRecord returns the last record of the index at or before the entry
+// number entry.
+func seekRecord(index []indexRecord, entry int64) indexRecord {
+	var r indexRecord
+	for _, rec := range index {
+		if rec.entry > entry {
+			break
+		}
+		r = rec
+	}
+	return r
+}
+
+// sinceRecord returns the record the entries since the time since are
+// looked for from: the record before the last one older than since, as the
+// entries of the streams of a container are not strictly ordered.
+func sinceRecord(index []indexRecord, since int64) indexRecord {
+	var prev, r indexRecord
+	for _, rec := range index {
+		if rec.timeNano >= since {
+			break
+		}
+		prev, r = r, rec
+	}
+	return prev
+}
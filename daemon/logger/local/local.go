@@ -0,0 +1,272 @@
+// Package local provides a logger which stores the logs on the host in a
+// compact binary format: the log entries framed as they are sent to the log
+// driver plugins. The log files are rotated, compressed once rotated, and
+// indexed, so that reading the last lines or the lines since a time only
+// decodes the entries asked for.
+package local
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types/plugins/logdriver"
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/loggerutils"
+	"github.com/docker/go-units"
+)
+
+// Name is the name of the local log driver.
+const Name = "local"
+
+const (
+	defaultMaxSize  = 20 * 1024 * 1024
+	defaultMaxFile  = 5
+	defaultCompress = true
+)
+
+var errClosed = errors.New("local: the logger is closed")
+
+// driver is the logger of a container. Its log file is at the path of the
+// Info of the container; the rotated files and the indexes are next to it.
+type driver struct {
+	path     string
+	maxFiles int
+
+	mu         sync.Mutex // protects the fields below
+	closed     bool
+	writer     *loggerutils.RotateFileWriter
+	frames     *frameWriter
+	enc        logdriver.LogEntryEncoder
+	index      *os.File // index of the current log file
+	entry      logdriver.LogEntry
+	entries    int64         // number of entries of the current log file
+	offset     int64         // size of the current log file
+	generation int           // incremented when the log file is rotated
+	changed    chan struct{} // closed when the log file changes, if a reader waits for it
+}
+
+func init() {
+	if err := logger.RegisterLogDriver(Name, New); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := logger.RegisterLogOptValidator(Name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// ValidateLogOpt looks for the options of the local log driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		switch key {
+		case "max-file", "max-size", "compress":
+		default:
+			return fmt.Errorf("unknown log opt '%s' for %s log driver", key, Name)
+		}
+	}
+	_, _, _, err := parseOpts(cfg)
+	return err
+}
+
+func parseOpts(cfg map[string]string) (maxSize int64, maxFiles int, compress bool, err error) {
+	maxSize, maxFiles, compress = defaultMaxSize, defaultMaxFile, defaultCompress
+	if s, ok := cfg["max-size"]; ok {
+		if maxSize, err = units.FromHumanSize(s); err != nil {
+			return 0, 0, false, fmt.Errorf("error parsing option max-size: %v", err)
+		}
+		if maxSize <= 0 {
+			return 0, 0, false, errors.New("max-size must be a positive size")
+		}
+	}
+	if s, ok := cfg["max-file"]; ok {
+		if maxFiles, err = strconv.Atoi(s); err != nil {
+			return 0, 0, false, fmt.Errorf("error parsing option max-file: %v", err)
+		}
+		if maxFiles < 1 {
+			return 0, 0, false, errors.New("max-file cannot be less than 1")
+		}
+	}
+	if s, ok := cfg["compress"]; ok {
+		if compress, err = strconv.ParseBool(s); err != nil {
+			return 0, 0, false, fmt.Errorf("error parsing option compress: %v", err)
+		}
+	}
+	return maxSize, maxFiles, compress, nil
+}
+
+// New creates a local logger writing to the path of info.
+func New(info logger.Info) (logger.Logger, error) {
+	if info.LogPath == "" {
+		return nil, errors.New("local: no log path")
+	}
+	maxSize, maxFiles, compress, err := parseOpts(info.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &driver{path: info.LogPath, maxFiles: maxFiles}
+	// continue the log file of a container which is started again, without
+	// the incomplete entry a crash may have left at its end
+	if err := d.restore(); err != nil {
+		return nil, err
+	}
+	if d.writer, err = loggerutils.NewRotateFileWriter(d.path, maxSize, maxFiles, compress); err != nil {
+		return nil, err
+	}
+	if d.index, err = os.OpenFile(indexPath(d.path), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640); err != nil {
+		d.writer.Close()
+		return nil, err
+	}
+	d.writer.SetRotateHook(d.rotateIndex)
+	d.frames = &frameWriter{w: d.writer}
+	d.enc = logdriver.NewLogEntryEncoder(d.frames)
+	return d, nil
+}
+
+// frameWriter writes the frames of the entries to the log file, and records
+// the size of the last one: the encoder writes each frame at once.
+type frameWriter struct {
+	w    io.Writer
+	size int64
+}
+
+func (w *frameWriter) Write(p []byte) (int, error) {
+	w.size = int64(len(p))
+	return w.w.Write(p)
+}
+
+// restore sets the number of entries and the size of the log file, if it
+// exists.
+func (d *driver) restore() error {
+	f, err := os.Open(d.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(indexPath(d.path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	defer f.Close()
+
+	index, err := readIndex(indexPath(d.path))
+	if err != nil {
+		return err
+	}
+	lf := &logFile{f: f, index: index}
+	if d.entries, d.offset, err = lf.count(); err != nil {
+		return err
+	}
+	if fi, err := f.Stat(); err == nil && fi.Size() > d.offset {
+		if err := os.Truncate(d.path, d.offset); err != nil {
+			return err
+		}
+	}
+	// drop the records of the entries which were cut
+	for len(index) > 0 && index[len(index)-1].offset >= d.offset {
+		index = index[:len(index)-1]
+	}
+	idx, err := os.OpenFile(indexPath(d.path), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	for _, r := range index {
+		if err := writeIndexRecord(idx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *driver) Log(msg *logger.Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return errClosed
+	}
+	d.entry.Source = msg.Source
+	d.entry.TimeNano = msg.Timestamp.UnixNano()
+	d.entry.Line = msg.Line
+	d.entry.Partial = msg.Partial
+	// the log file may be rotated by the write, which resets the entries
+	// and offset of the current log file
+	err := d.enc.Encode(&d.entry)
+	d.entry.Reset()
+	if err != nil {
+		return err
+	}
+	if d.entries%indexInterval == 0 {
+		if err := writeIndexRecord(d.index, indexRecord{entry: d.entries, offset: d.offset, timeNano: msg.Timestamp.UnixNano()}); err != nil {
+			logrus.WithField("logger", Name).Debugf("failed to index log file %s: %v", d.path, err)
+		}
+	}
+	d.entries++
+	d.offset += d.frames.size
+	d.notify()
+	return nil
+}
+
+// rotateIndex rotates the indexes along with the log files. It is called
+// by the writer, with d.mu held by Log.
+func (d *driver) rotateIndex() error {
+	if err := d.index.Close(); err != nil {
+		return err
+	}
+	if err := rotateIndexes(d.path, d.maxFiles); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	index, err := os.OpenFile(indexPath(d.path), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	d.index = index
+	d.entries = 0
+	d.offset = 0
+	d.generation++
+	return nil
+}
+
+// notify wakes up the readers following the logs.
+func (d *driver) notify() {
+	if d.changed != nil {
+		close(d.changed)
+		d.changed = nil
+	}
+}
+
+// waitChange returns a channel which is closed when the log file changes.
+func (d *driver) waitChange() <-chan struct{} {
+	if d.changed == nil {
+		d.changed = make(chan struct{})
+	}
+	return d.changed
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+// Close closes the log files. The readers following the logs stop once
+// they have read the entries logged.
+func (d *driver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	d.notify()
+	err := d.writer.Close()
+	if ierr := d.index.Close(); err == nil {
+		err = ierr
+	}
+	return err
+}
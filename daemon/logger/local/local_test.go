@@ -0,0 +1,326 @@
+package local
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/plugins/logdriver"
+	"github.com/docker/docker/daemon/logger"
+)
+
+var epoch = time.Unix(1500000000, 0)
+
+func newTestLogger(t *testing.T, config map[string]string) (*driver, func()) {
+	dir, err := ioutil.TempDir("", "docker-logger-local-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(logger.Info{
+		ContainerID: "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+		LogPath:     filepath.Join(dir, "container.log"),
+		Config:      config,
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return l.(*driver), func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// logLines logs the lines from to to, one second apart from epoch.
+func logLines(t *testing.T, l logger.Logger, from, to int) {
+	for i := from; i < to; i++ {
+		msg := &logger.Message{
+			Line:      []byte("line " + strconv.Itoa(i)),
+			Source:    "stdout",
+			Timestamp: epoch.Add(time.Duration(i) * time.Second),
+		}
+		if err := l.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readLines returns the lines read with config, which must not follow.
+func readLines(t *testing.T, l logger.LogReader, config logger.ReadConfig) []string {
+	watcher := l.ReadLogs(config)
+	defer watcher.Close()
+
+	var lines []string
+	for {
+		select {
+		case msg, ok := <-watcher.Msg:
+			if !ok {
+				return lines
+			}
+			lines = append(lines, string(msg.Line))
+		case err := <-watcher.Err:
+			t.Fatal(err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout reading the logs")
+		}
+	}
+}
+
+func expectedLines(from, to int) []string {
+	var lines []string
+	for i := from; i < to; i++ {
+		lines = append(lines, "line "+strconv.Itoa(i))
+	}
+	return lines
+}
+
+func TestLogFileFormat(t *testing.T) {
+	l, cleanup := newTestLogger(t, nil)
+	defer cleanup()
+	logLines(t, l, 0, 3)
+
+	// the log file is the entries framed as on the FIFO of the plugins
+	f, err := os.Open(l.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := logdriver.NewLogEntryDecoder(f)
+	for i := 0; i < 3; i++ {
+		var got logdriver.LogEntry
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		expected := logdriver.LogEntry{Source: "stdout", TimeNano: epoch.Add(time.Duration(i) * time.Second).UnixNano(), Line: []byte("line " + strconv.Itoa(i))}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, got)
+		}
+	}
+	if err := dec.Decode(&logdriver.LogEntry{}); err != io.EOF {
+		t.Fatalf("expected the end of the log file, got %v", err)
+	}
+}
+
+func TestValidateLogOpt(t *testing.T) {
+	for _, opts := range []map[string]string{
+		{"max-size": "1k", "max-file": "2", "compress": "false"},
+		{},
+	} {
+		if err := ValidateLogOpt(opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, opts := range []map[string]string{
+		{"max-file": "0"},
+		{"max-size": "-1"},
+		{"compress": "maybe"},
+		{"labels": "a"},
+	} {
+		if err := ValidateLogOpt(opts); err == nil {
+			t.Fatalf("expected an error validating %v", opts)
+		}
+	}
+}
+
+func TestReadLogs(t *testing.T) {
+	l, cleanup := newTestLogger(t, map[string]string{"max-size": "4k", "max-file": "3"})
+	defer cleanup()
+	// over 3 log files, and more than an index interval in each
+	logLines(t, l, 0, 400)
+
+	if _, err := os.Stat(l.path + ".2.gz"); err != nil {
+		t.Fatalf("expected the rotated log file to be compressed: %v", err)
+	}
+	all := readLines(t, l, logger.ReadConfig{Tail: -1})
+	if len(all) == 0 || all[len(all)-1] != "line 399" {
+		t.Fatalf("unexpected logs: %v", all)
+	}
+	first, _ := strconv.Atoi(all[0][len("line "):])
+	if !reflect.DeepEqual(all, expectedLines(first, 400)) {
+		t.Fatalf("unexpected logs: %v", all)
+	}
+
+	if lines := readLines(t, l, logger.ReadConfig{}); len(lines) != 0 {
+		t.Fatalf("expected no logs without tail, got %v", lines)
+	}
+	for _, tail := range []int{1, 10, 100, len(all) - 10, len(all) + 10} {
+		lines := readLines(t, l, logger.ReadConfig{Tail: tail})
+		expected := all
+		if tail < len(all) {
+			expected = all[len(all)-tail:]
+		}
+		if !reflect.DeepEqual(lines, expected) {
+			t.Fatalf("tail %d: expected %v, got %v", tail, expected, lines)
+		}
+	}
+	for _, since := range []int{first + 5, 300, 399} {
+		lines := readLines(t, l, logger.ReadConfig{Tail: -1, Since: epoch.Add(time.Duration(since) * time.Second)})
+		if expected := expectedLines(since, 400); !reflect.DeepEqual(lines, expected) {
+			t.Fatalf("since %d: expected %v, got %v", since, expected, lines)
+		}
+	}
+	lines := readLines(t, l, logger.ReadConfig{Tail: 5, Since: epoch.Add(100 * time.Second)})
+	if expected := expectedLines(395, 400); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %v, got %v", expected, lines)
+	}
+
+	lines = readLines(t, l, logger.ReadConfig{Tail: -1, Since: epoch.Add(300 * time.Second), Until: epoch.Add(310 * time.Second)})
+	if expected := expectedLines(300, 311); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %v, got %v", expected, lines)
+	}
+	filter, err := logger.NewMessageFilter([]string{"stdout"}, "^line 3[0-9]9$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines = readLines(t, l, logger.ReadConfig{Tail: -1, Since: epoch.Add(350 * time.Second), Filter: filter})
+	if expected := []string{"line 359", "line 369", "line 379", "line 389", "line 399"}; !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %v, got %v", expected, lines)
+	}
+	// the last lines are counted among the messages selected
+	lines = readLines(t, l, logger.ReadConfig{Tail: 3, Filter: filter})
+	if expected := []string{"line 379", "line 389", "line 399"}; !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %v, got %v", expected, lines)
+	}
+	lines = readLines(t, l, logger.ReadConfig{Tail: 5, Until: epoch.Add(310 * time.Second)})
+	if expected := expectedLines(306, 311); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %v, got %v", expected, lines)
+	}
+	if filter, err = logger.NewMessageFilter([]string{"stderr"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if lines = readLines(t, l, logger.ReadConfig{Tail: -1, Filter: filter}); len(lines) != 0 {
+		t.Fatalf("expected no logs on stderr, got %v", lines)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	l, cleanup := newTestLogger(t, nil)
+	defer cleanup()
+	logLines(t, l, 0, 100)
+	l.Close()
+
+	// a partial entry left by a crash is dropped
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1})
+	f.Close()
+
+	reopened, err := New(logger.Info{LogPath: l.path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	logLines(t, reopened, 100, 200)
+
+	lines := readLines(t, reopened.(logger.LogReader), logger.ReadConfig{Tail: 150})
+	if expected := expectedLines(50, 200); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	l, cleanup := newTestLogger(t, map[string]string{"max-size": "1k", "max-file": "5"})
+	defer cleanup()
+	logLines(t, l, 0, 3)
+
+	watcher := l.ReadLogs(logger.ReadConfig{Tail: -1, Follow: true})
+	defer watcher.Close()
+	go func() {
+		// rotated a few times while the logs are followed
+		for i := 3; i < 100; i += 10 {
+			logLines(t, l, i, i+10)
+			time.Sleep(time.Millisecond)
+		}
+		l.Close()
+	}()
+
+	var lines []string
+	for done := false; !done; {
+		select {
+		case msg, ok := <-watcher.Msg:
+			if !ok {
+				done = true
+				break
+			}
+			lines = append(lines, string(msg.Line))
+		case err := <-watcher.Err:
+			t.Fatal(err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout following the logs")
+		}
+	}
+	if expected := expectedLines(0, 103); !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestFollowLogsRotatedTwice(t *testing.T) {
+	for _, test := range []struct {
+		lines int
+		err   bool
+	}{
+		{100, false},
+		// more rotations than rotated files kept
+		{400, true},
+	} {
+		l, cleanup := newTestLogger(t, map[string]string{"max-size": "1k", "max-file": "5"})
+		defer cleanup()
+		logLines(t, l, 0, 3)
+
+		// the follower wakes up after several rotations of the log file
+		l.mu.Lock()
+		files, err := l.openFiles()
+		generation := l.generation
+		l.mu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lf := files[len(files)-1]
+		defer lf.f.Close()
+		dec, err := lf.decoder(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logLines(t, l, 3, test.lines)
+		if rotations := l.generation - generation; rotations < 2 {
+			t.Fatalf("expected the log file to be rotated more than once, got %d rotations", rotations)
+		}
+		l.Close()
+
+		watcher := logger.NewLogWatcher()
+		send := func(e *logdriver.LogEntry) bool {
+			watcher.Msg <- &logger.Message{Line: e.Line}
+			return true
+		}
+		l.followLogs(lf, dec, generation, send, watcher)
+		close(watcher.Msg)
+
+		if test.err {
+			select {
+			case <-watcher.Err:
+			default:
+				t.Fatalf("expected an error for the log files removed before they were read")
+			}
+			continue
+		}
+		select {
+		case err := <-watcher.Err:
+			t.Fatal(err)
+		default:
+		}
+		var lines []string
+		for msg := range watcher.Msg {
+			lines = append(lines, string(msg.Line))
+		}
+		if expected := expectedLines(0, test.lines); !reflect.DeepEqual(lines, expected) {
+			t.Fatalf("expected %v, got %v", expected, lines)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package local
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types/plugins/logdriver"
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/loggerutils"
+)
+
+// decoder reads the entries of a log file from an offset.
+type decoder struct {
+	r      *countingReader
+	dec    logdriver.LogEntryDecoder
+	offset int64 // offset of the next entry in the log file
+}
+
+func newDecoder(r io.Reader, offset int64) *decoder {
+	cr := &countingReader{r: bufio.NewReader(r), n: offset}
+	return &decoder{r: cr, dec: logdriver.NewLogEntryDecoder(cr), offset: offset}
+}
+
+// next decodes the next entry into e. It returns io.EOF at the end of the
+// log file, io.ErrUnexpectedEOF if the last entry is incomplete, which
+// happens when the entry is being written, and logdriver.ErrCorruptEntry
+// if the entry cannot be decoded.
+func (d *decoder) next(e *logdriver.LogEntry) error {
+	if err := d.dec.Decode(e); err != nil {
+		return err
+	}
+	d.offset = d.r.n
+	return nil
+}
+
+// countingReader counts the bytes read from r, from n.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// skip skips n entries.
+func (d *decoder) skip(n int64) error {
+	var e logdriver.LogEntry
+	for ; n > 0; n-- {
+		if err := d.next(&e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logFile is a log file opened for reading, with its index.
+type logFile struct {
+	f          *os.File
+	compressed bool
+	index      []indexRecord
+}
+
+// decoder returns a decoder of the entries from offset, which must be the
+// offset of an entry.
+func (lf *logFile) decoder(offset int64) (*decoder, error) {
+	if !lf.compressed {
+		r := io.NewSectionReader(lf.f, offset, math.MaxInt64-offset)
+		return newDecoder(r, offset), nil
+	}
+	if _, err := lf.f.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(lf.f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, zr, offset); err != nil {
+		return nil, err
+	}
+	return newDecoder(zr, offset), nil
+}
+
+// count returns the number of complete entries of the log file, and the
+// offset of the end of the last one. The entries are only decoded from the
+// last record of the index.
+func (lf *logFile) count() (entries, offset int64, err error) {
+	var last indexRecord
+	if len(lf.index) > 0 {
+		last = lf.index[len(lf.index)-1]
+	}
+	dec, err := lf.decoder(last.offset)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// the index is ahead of the log file
+			lf.index = nil
+			return lf.count()
+		}
+		return 0, 0, err
+	}
+	entries = last.entry
+	var e logdriver.LogEntry
+	for {
+		switch err := dec.next(&e); err {
+		case nil:
+			entries++
+		case io.EOF, io.ErrUnexpectedEOF, logdriver.ErrCorruptEntry:
+			return entries, dec.offset, nil
+		default:
+			return 0, 0, err
+		}
+	}
+}
+
+// position is where the reading of the logs starts: skip entries after the
+// record rec of the log file file.
+type position struct {
+	file int
+	rec  indexRecord
+	skip int64
+}
+
+func (p position) after(q position) bool {
+	if p.file != q.file {
+		return p.file > q.file
+	}
+	return p.rec.entry+p.skip > q.rec.entry+q.skip
+}
+
+// tailPosition returns the position of the last tail entries of the log
+// files, the last of which has current entries.
+func tailPosition(files []*logFile, current int64, tail int) (position, error) {
+	remaining := int64(tail)
+	for i := len(files) - 1; i >= 0; i-- {
+		n := current
+		if i < len(files)-1 {
+			var err error
+			if n, _, err = files[i].count(); err != nil {
+				return position{}, err
+			}
+		}
+		if n >= remaining {
+			rec := seekRecord(files[i].index, n-remaining)
+			return position{file: i, rec: rec, skip: n - remaining - rec.entry}, nil
+		}
+		remaining -= n
+	}
+	return position{}, nil
+}
+
+// sincePosition returns the position the entries since the time since are
+// looked for from. The log files whose next log file starts before since
+// are skipped.
+func sincePosition(files []*logFile, since int64) position {
+	i := 0
+	for i < len(files)-1 && len(files[i+1].index) > 0 && files[i+1].index[0].timeNano < since {
+		i++
+	}
+	return position{file: i, rec: sinceRecord(files[i].index, since)}
+}
+
+// ReadLogs implements the logger's LogReader interface for the logs
+// created by this driver.
+func (d *driver) ReadLogs(config logger.ReadConfig) *logger.LogWatcher {
+	watcher := logger.NewLogWatcher()
+
+	go d.readLogs(watcher, config)
+	return watcher
+}
+
+func (d *driver) readLogs(watcher *logger.LogWatcher, config logger.ReadConfig) {
+	defer close(watcher.Msg)
+
+	// lock so that the log files are not rotated while they are opened
+	d.mu.Lock()
+	files, err := d.openFiles()
+	current, generation := d.entries, d.generation
+	d.mu.Unlock()
+	defer closeFiles(files)
+	if err != nil {
+		watcher.Err <- err
+		return
+	}
+
+	var since int64
+	if !config.Since.IsZero() {
+		since = config.Since.UnixNano()
+	}
+	// the last lines are counted among the messages selected, so they are
+	// looked for in all the log files unless every message since
+	// config.Since is selected; they are kept in tail until the end of the
+	// logs is reached
+	selective := config.Tail > 0 && (!config.Until.IsZero() || !config.Filter.SelectsAll())
+	var (
+		tail         []*logger.Message
+		untilReached bool
+	)
+	emit := func(msg *logger.Message) bool {
+		select {
+		case watcher.Msg <- msg:
+			return true
+		case <-watcher.WatchClose():
+			return false
+		}
+	}
+	sendTail := func() bool {
+		for _, msg := range tail {
+			if !emit(msg) {
+				return false
+			}
+		}
+		tail, selective = nil, false
+		return true
+	}
+	// send sends the entry e if it is selected by config, and returns false
+	// when the reading of the logs must stop
+	send := func(e *logdriver.LogEntry) bool {
+		if e.TimeNano < since {
+			return true
+		}
+		msg := &logger.Message{
+			Line:      e.Line,
+			Source:    e.Source,
+			Timestamp: time.Unix(0, e.TimeNano),
+			Partial:   e.Partial,
+		}
+		if !config.Until.IsZero() && msg.Timestamp.After(config.Until) {
+			untilReached = true
+			return false
+		}
+		if !config.Filter.Match(msg) {
+			return true
+		}
+		if selective {
+			if tail = append(tail, msg); len(tail) > config.Tail {
+				tail = tail[1:]
+			}
+			return true
+		}
+		return emit(msg)
+	}
+
+	// start at the end of the logs unless they are asked for
+	latest := files[len(files)-1]
+	rec := seekRecord(latest.index, current)
+	pos := position{file: len(files) - 1, rec: rec, skip: current - rec.entry}
+	if config.Tail != 0 {
+		pos = position{}
+		if config.Tail > 0 && !selective {
+			if pos, err = tailPosition(files, current, config.Tail); err != nil {
+				watcher.Err <- err
+				return
+			}
+		}
+		if since != 0 {
+			if sp := sincePosition(files, since); sp.after(pos) {
+				pos = sp
+			}
+		}
+	}
+
+	var dec *decoder
+	for i := pos.file; i < len(files); i++ {
+		var offset int64
+		if i == pos.file {
+			offset = pos.rec.offset
+		}
+		if dec, err = files[i].decoder(offset); err != nil {
+			watcher.Err <- err
+			return
+		}
+		if i == pos.file {
+			if err := dec.skip(pos.skip); err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				watcher.Err <- err
+				return
+			}
+		}
+		if !decodeAll(dec, send, watcher) {
+			if untilReached {
+				sendTail()
+			}
+			return
+		}
+	}
+	if !sendTail() {
+		return
+	}
+	if config.Follow {
+		d.followLogs(latest, dec, generation, send, watcher)
+	}
+}
+
+// openFiles opens the log files, the oldest first, with their indexes. It
+// must be called with d.mu held.
+func (d *driver) openFiles() ([]*logFile, error) {
+	var files []*logFile
+	for i := d.maxFiles - 1; i > 0; i-- {
+		f, compressed, err := loggerutils.OpenRotatedFile(d.path, i)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return files, err
+		}
+		lf := &logFile{f: f, compressed: compressed}
+		files = append(files, lf)
+		if lf.index, err = readIndex(rotatedIndexPath(d.path, i)); err != nil {
+			return files, err
+		}
+	}
+	f, err := os.Open(d.path)
+	if err != nil {
+		return files, err
+	}
+	lf := &logFile{f: f}
+	files = append(files, lf)
+	if lf.index, err = readIndex(indexPath(d.path)); err != nil {
+		return files, err
+	}
+	return files, nil
+}
+
+// openRotated opens the last n log files rotated, the oldest first. It fails
+// if they were removed by later rotations. It must be called with d.mu held.
+func (d *driver) openRotated(n int) ([]*logFile, error) {
+	if n > d.maxFiles-1 {
+		return nil, fmt.Errorf("%d log files were rotated and removed before they were read", n-(d.maxFiles-1))
+	}
+	var files []*logFile
+	for i := n; i > 0; i-- {
+		f, compressed, err := loggerutils.OpenRotatedFile(d.path, i)
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, &logFile{f: f, compressed: compressed})
+	}
+	return files, nil
+}
+
+func closeFiles(files []*logFile) {
+	for _, lf := range files {
+		lf.f.Close()
+	}
+}
+
+// decodeFiles sends the entries of lf from offset, then the entries of the
+// files rotated after it, which it closes. It returns false if the reading
+// of the logs must stop.
+func decodeFiles(lf *logFile, offset int64, rotated []*logFile, send func(*logdriver.LogEntry) bool, watcher *logger.LogWatcher) bool {
+	defer closeFiles(rotated)
+	for _, f := range append([]*logFile{lf}, rotated...) {
+		dec, err := f.decoder(offset)
+		if err != nil {
+			watcher.Err <- err
+			return false
+		}
+		if !decodeAll(dec, send, watcher) {
+			return false
+		}
+		offset = 0
+	}
+	return true
+}
+
+// decodeAll sends the entries of dec up to the end of its log file. It
+// returns false if the reading of the logs must stop.
+func decodeAll(dec *decoder, send func(*logdriver.LogEntry) bool, watcher *logger.LogWatcher) bool {
+	var e logdriver.LogEntry
+	for {
+		switch err := dec.next(&e); err {
+		case nil:
+			if !send(&e) {
+				return false
+			}
+		case io.EOF, io.ErrUnexpectedEOF:
+			return true
+		default:
+			watcher.Err <- fmt.Errorf("error reading log file: %v", err)
+			return false
+		}
+	}
+}
+
+// followLogs sends the entries logged from the offset of dec in the log
+// file lf, of the generation of the log files given, until the logger is
+// closed or the reader goes away. It follows the log file across its
+// rotations.
+func (d *driver) followLogs(lf *logFile, dec *decoder, generation int, send func(*logdriver.LogEntry) bool, watcher *logger.LogWatcher) {
+	for {
+		d.mu.Lock()
+		if d.generation != generation {
+			// the log file was rotated: the rest of the file opened is read,
+			// then the files rotated since, the oldest first, then the new
+			// log file
+			rotated, err := d.openRotated(d.generation - generation - 1)
+			var f *os.File
+			if err == nil {
+				f, err = os.Open(d.path)
+			}
+			generation = d.generation
+			d.mu.Unlock()
+			if err != nil {
+				closeFiles(rotated)
+				watcher.Err <- err
+				return
+			}
+			if !decodeFiles(lf, dec.offset, rotated, send, watcher) {
+				f.Close()
+				return
+			}
+			lf.f.Close()
+			lf.f = f
+			if dec, err = lf.decoder(0); err != nil {
+				watcher.Err <- err
+				return
+			}
+			if !decodeAll(dec, send, watcher) {
+				return
+			}
+			continue
+		}
+		if d.offset > dec.offset {
+			d.mu.Unlock()
+			// an incomplete entry may have been buffered by the decoder
+			var err error
+			if dec, err = lf.decoder(dec.offset); err != nil {
+				watcher.Err <- err
+				return
+			}
+			if !decodeAll(dec, send, watcher) {
+				return
+			}
+			continue
+		}
+		if d.closed {
+			d.mu.Unlock()
+			return
+		}
+		changed := d.waitChange()
+		d.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-watcher.WatchClose():
+			return
+		}
+	}
+}
@@ -89,8 +89,10 @@ type Logger interface {
 // ReadConfig is the configuration passed into ReadLogs.
 type ReadConfig struct {
 	Since  time.Time
+	Until  time.Time // the messages after Until are not read, if it is set
 	Tail   int
 	Follow bool
+	Filter *MessageFilter // selects the messages read, if it is set
 }
 
 // LogReader is the interface for reading log messages for loggers that support reading.
@@ -0,0 +1,159 @@
+// Package cache tees the messages of the log drivers which cannot read logs
+// into a local, rotated cache, so that `docker logs` works with every
+// driver.
+package cache
+
+import (
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/jsonfilelog"
+	units "github.com/docker/go-units"
+	"github.com/pkg/errors"
+)
+
+// Options of the cache, which every log driver supports. When they are set
+// in the default log options of the daemon, they apply to the containers of
+// every driver.
+const (
+	// DisabledOpt disables the cache of a container when "true".
+	DisabledOpt = "cache-disabled"
+	// MaxSizeOpt is the size of the cache file before it is rotated.
+	MaxSizeOpt = "cache-max-size"
+	// MaxFileOpt is the number of cache files kept.
+	MaxFileOpt = "cache-max-file"
+)
+
+const (
+	defaultMaxSize = "20m"
+	defaultMaxFile = "5"
+)
+
+var builtInCacheLogOpts = map[string]bool{
+	DisabledOpt: true,
+	MaxSizeOpt:  true,
+	MaxFileOpt:  true,
+}
+
+func init() {
+	logger.AddBuiltinLogOpts(builtInCacheLogOpts)
+	logger.RegisterExternalValidator(ValidateLogOpt)
+}
+
+// ValidateLogOpt validates the options of the cache in cfg.
+func ValidateLogOpt(cfg map[string]string) error {
+	if s, ok := cfg[DisabledOpt]; ok {
+		if _, err := strconv.ParseBool(s); err != nil {
+			return errors.Wrapf(err, "error parsing option %s", DisabledOpt)
+		}
+	}
+	if s, ok := cfg[MaxSizeOpt]; ok {
+		if _, err := units.FromHumanSize(s); err != nil {
+			return errors.Wrapf(err, "error parsing option %s", MaxSizeOpt)
+		}
+	}
+	if s, ok := cfg[MaxFileOpt]; ok {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return errors.Wrapf(err, "error parsing option %s", MaxFileOpt)
+		}
+		if n < 1 {
+			return errors.Errorf("%s cannot be less than 1", MaxFileOpt)
+		}
+	}
+	return nil
+}
+
+// MergeDefaultLogConfig sets the options of the cache of the daemon in cfg,
+// the options of a container, unless the container sets them.
+func MergeDefaultLogConfig(cfg, defaults map[string]string) {
+	for k := range builtInCacheLogOpts {
+		if v, ok := defaults[k]; ok {
+			if _, ok := cfg[k]; !ok {
+				cfg[k] = v
+			}
+		}
+	}
+}
+
+// ShouldUseCache reports whether the messages of a container with the log
+// options cfg are cached.
+func ShouldUseCache(cfg map[string]string) bool {
+	disabled, _ := strconv.ParseBool(cfg[DisabledOpt])
+	return !disabled
+}
+
+// WithLocalCache returns a logger which sends the messages to l and writes
+// them to a cache at info.LogPath, which the logs are read from. l is
+// returned as is if it can read logs itself.
+func WithLocalCache(l logger.Logger, info logger.Info) (logger.Logger, error) {
+	if _, ok := l.(logger.LogReader); ok {
+		return l, nil
+	}
+
+	c, err := jsonfilelog.New(cacheInfo(info))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the local log cache")
+	}
+	return &loggerWithCache{l: l, cache: c}, nil
+}
+
+// NewReader returns a logger which reads the cache at info.LogPath, written
+// by a logger returned by WithLocalCache with the same info. Neither the log
+// driver nor the cache file are created, and the logger cannot write logs.
+func NewReader(info logger.Info) (logger.Logger, error) {
+	r, err := jsonfilelog.NewReader(cacheInfo(info))
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening the local log cache")
+	}
+	return r, nil
+}
+
+// cacheInfo returns the info of the json-file logger of the cache of a
+// logger with the given info.
+func cacheInfo(info logger.Info) logger.Info {
+	cacheInfo := info
+	cacheInfo.Config = map[string]string{
+		"max-size": defaultMaxSize,
+		"max-file": defaultMaxFile,
+	}
+	if s, ok := info.Config[MaxSizeOpt]; ok {
+		cacheInfo.Config["max-size"] = s
+	}
+	if s, ok := info.Config[MaxFileOpt]; ok {
+		cacheInfo.Config["max-file"] = s
+	}
+	return cacheInfo
+}
+
+// loggerWithCache is a logger which writes the messages to a local cache
+// before sending them to the wrapped logger.
+type loggerWithCache struct {
+	l     logger.Logger
+	cache logger.Logger
+}
+
+func (l *loggerWithCache) Log(msg *logger.Message) error {
+	// the cache is best effort: the messages are still sent to the driver
+	if err := l.cache.Log(msg); err != nil {
+		logrus.WithError(err).Debug("Failed to write a log message to the local log cache")
+	}
+	return l.l.Log(msg)
+}
+
+func (l *loggerWithCache) Name() string {
+	return l.l.Name()
+}
+
+func (l *loggerWithCache) ReadLogs(config logger.ReadConfig) *logger.LogWatcher {
+	return l.cache.(logger.LogReader).ReadLogs(config)
+}
+
+func (l *loggerWithCache) Close() error {
+	err := l.l.Close()
+	if cerr := l.cache.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
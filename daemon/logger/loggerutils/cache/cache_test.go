@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+type fakeLogger struct {
+	lines  []string
+	closed bool
+}
+
+func (l *fakeLogger) Log(msg *logger.Message) error {
+	l.lines = append(l.lines, string(msg.Line))
+	return nil
+}
+
+func (l *fakeLogger) Name() string { return "fake" }
+
+func (l *fakeLogger) Close() error {
+	l.closed = true
+	return nil
+}
+
+type fakeReader struct {
+	fakeLogger
+}
+
+func (l *fakeReader) ReadLogs(logger.ReadConfig) *logger.LogWatcher {
+	return logger.NewLogWatcher()
+}
+
+func TestWithLocalCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := &fakeLogger{}
+	info := logger.Info{Config: map[string]string{MaxSizeOpt: "1m"}, LogPath: filepath.Join(dir, "container-cached.log")}
+	cl, err := WithLocalCache(l, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl.Name() != "fake" {
+		t.Fatalf("Expected the name of the wrapped logger, got %s", cl.Name())
+	}
+	for _, line := range []string{"one", "two"} {
+		if err := cl.Log(&logger.Message{Line: []byte(line), Source: "stdout", Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(l.lines, []string{"one", "two"}) {
+		t.Fatalf("Expected the messages to be sent to the wrapped logger, got %v", l.lines)
+	}
+
+	reader, ok := cl.(logger.LogReader)
+	if !ok {
+		t.Fatal("Expected the logger with a cache to read logs")
+	}
+	watcher := reader.ReadLogs(logger.ReadConfig{Tail: -1})
+	defer watcher.Close()
+	var lines []string
+	for msg := range watcher.Msg {
+		lines = append(lines, string(msg.Line))
+	}
+	if !reflect.DeepEqual(lines, []string{"one\n", "two\n"}) {
+		t.Fatalf("Expected the messages to be read from the cache, got %q", lines)
+	}
+
+	if err := cl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !l.closed {
+		t.Fatal("Expected the wrapped logger to be closed")
+	}
+}
+
+func TestWithLocalCacheReader(t *testing.T) {
+	l := &fakeReader{}
+	cl, err := WithLocalCache(l, logger.Info{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl != l {
+		t.Fatal("Expected a logger which reads logs not to be cached")
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	info := logger.Info{Config: map[string]string{MaxFileOpt: "2"}, LogPath: filepath.Join(dir, "container-cached.log")}
+	cl, err := WithLocalCache(&fakeLogger{}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.Log(&logger.Message{Line: []byte("one"), Source: "stdout", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.Log(&logger.Message{Line: []byte("two"), Source: "stdout", Timestamp: time.Now()}); err == nil {
+		t.Fatal("Expected the reader not to write logs")
+	}
+	watcher := r.(logger.LogReader).ReadLogs(logger.ReadConfig{Tail: -1, Follow: true})
+	defer watcher.Close()
+	var lines []string
+	for msg := range watcher.Msg {
+		lines = append(lines, string(msg.Line))
+	}
+	if !reflect.DeepEqual(lines, []string{"one\n"}) {
+		t.Fatalf("Expected the messages to be read from the cache, got %q", lines)
+	}
+}
+
+func TestValidateLogOpt(t *testing.T) {
+	valid := map[string]string{DisabledOpt: "false", MaxSizeOpt: "10m", MaxFileOpt: "3", "other": "value"}
+	if err := ValidateLogOpt(valid); err != nil {
+		t.Fatal(err)
+	}
+	for _, cfg := range []map[string]string{
+		{DisabledOpt: "maybe"},
+		{MaxSizeOpt: "large"},
+		{MaxFileOpt: "0"},
+	} {
+		if err := ValidateLogOpt(cfg); err == nil {
+			t.Fatalf("Expected %v to be invalid", cfg)
+		}
+	}
+}
+
+func TestMergeDefaultLogConfig(t *testing.T) {
+	cfg := map[string]string{MaxSizeOpt: "1m"}
+	MergeDefaultLogConfig(cfg, map[string]string{DisabledOpt: "true", MaxSizeOpt: "5m", "max-size": "10m"})
+	expected := map[string]string{DisabledOpt: "true", MaxSizeOpt: "1m"}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("Expected %v, got %v", expected, cfg)
+	}
+	if ShouldUseCache(cfg) {
+		t.Fatal("Expected the cache to be disabled")
+	}
+	if !ShouldUseCache(map[string]string{}) {
+		t.Fatal("Expected the cache to be used by default")
+	}
+}
@@ -1,10 +1,13 @@
 package loggerutils
 
 import (
+	"compress/gzip"
+	"io"
 	"os"
 	"strconv"
 	"sync"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/pkg/pubsub"
 )
 
@@ -15,11 +18,15 @@ type RotateFileWriter struct {
 	capacity     int64 //maximum size of each file
 	currentSize  int64 // current size of the latest file
 	maxFiles     int   //maximum number of files
+	compress     bool  // whether the rotated files are compressed
+	compressed   chan struct{}
+	onRotate     func() error
 	notifyRotate *pubsub.Publisher
 }
 
-//NewRotateFileWriter creates new RotateFileWriter
-func NewRotateFileWriter(logPath string, capacity int64, maxFiles int) (*RotateFileWriter, error) {
+//NewRotateFileWriter creates new RotateFileWriter. If compress is set, the
+//rotated files are compressed with gzip, and get a ".gz" extension.
+func NewRotateFileWriter(logPath string, capacity int64, maxFiles int, compress bool) (*RotateFileWriter, error) {
 	log, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
 	if err != nil {
 		return nil, err
@@ -35,6 +42,7 @@ func NewRotateFileWriter(logPath string, capacity int64, maxFiles int) (*RotateF
 		capacity:     capacity,
 		currentSize:  size,
 		maxFiles:     maxFiles,
+		compress:     compress,
 		notifyRotate: pubsub.NewPublisher(0, 1),
 	}, nil
 }
@@ -65,6 +73,9 @@ func (w *RotateFileWriter) checkCapacityAndRotate() error {
 		if err := w.f.Close(); err != nil {
 			return err
 		}
+		// the file rotated last time must be compressed before it is
+		// renamed again
+		w.waitCompressed()
 		if err := rotate(name, w.maxFiles); err != nil {
 			return err
 		}
@@ -74,23 +85,57 @@ func (w *RotateFileWriter) checkCapacityAndRotate() error {
 		}
 		w.f = file
 		w.currentSize = 0
+		if w.compress && w.maxFiles > 1 {
+			done := make(chan struct{})
+			w.compressed = done
+			go func() {
+				defer close(done)
+				if err := compressFile(name + ".1"); err != nil {
+					logrus.Errorf("Failed to compress the rotated log file %s.1: %v", name, err)
+				}
+			}()
+		}
+		if w.onRotate != nil {
+			if err := w.onRotate(); err != nil {
+				return err
+			}
+		}
 		w.notifyRotate.Publish(struct{}{})
 	}
 
 	return nil
 }
 
+func (w *RotateFileWriter) waitCompressed() {
+	if w.compressed != nil {
+		<-w.compressed
+		w.compressed = nil
+	}
+}
+
+// rotate renames the log file name and the files rotated before it. A
+// rotated file is renamed whether it is compressed or not, as it is not
+// compressed if its compression failed: it must keep the place of its index
+// rather than be overwritten.
 func rotate(name string, maxFiles int) error {
 	if maxFiles < 2 {
 		return nil
 	}
-	for i := maxFiles - 1; i > 1; i-- {
-		toPath := name + "." + strconv.Itoa(i)
-		fromPath := name + "." + strconv.Itoa(i-1)
-		if err := os.Rename(fromPath, toPath); err != nil && !os.IsNotExist(err) {
+	// the oldest file is dropped, compressed or not
+	for _, extension := range []string{"", ".gz"} {
+		if err := os.Remove(name + "." + strconv.Itoa(maxFiles-1) + extension); err != nil && !os.IsNotExist(err) {
 			return err
 		}
 	}
+	for i := maxFiles - 1; i > 1; i-- {
+		for _, extension := range []string{"", ".gz"} {
+			toPath := name + "." + strconv.Itoa(i) + extension
+			fromPath := name + "." + strconv.Itoa(i-1) + extension
+			if err := os.Rename(fromPath, toPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
 
 	if err := os.Rename(name, name+".1"); err != nil && !os.IsNotExist(err) {
 		return err
@@ -98,6 +143,66 @@ func rotate(name string, maxFiles int) error {
 	return nil
 }
 
+// compressFile replaces the file name with its gzip compression, name.gz.
+func compressFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tmp := name + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, f)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
+}
+
+// OpenRotatedFile opens the log file rotated i times of the log file name,
+// and reports whether it is compressed. It returns an error satisfying
+// os.IsNotExist if the file does not exist.
+func OpenRotatedFile(name string, i int) (f *os.File, compressed bool, err error) {
+	name = name + "." + strconv.Itoa(i)
+	// the file is renamed when it is compressed, so the compressed file is
+	// looked for again if the file is not found
+	for _, path := range []string{name + ".gz", name, name + ".gz"} {
+		f, err = os.Open(path)
+		if err == nil {
+			return f, path != name, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, false, err
+		}
+	}
+	return nil, false, err
+}
+
+// SetRotateHook sets a function which is called after each rotation,
+// before the message which caused it is written to the new log file. It is
+// called with the writer locked, so that the files which go along with the
+// log file, such as an index, can be rotated with it.
+func (w *RotateFileWriter) SetRotateHook(f func() error) {
+	w.mu.Lock()
+	w.onRotate = f
+	w.mu.Unlock()
+}
+
 // LogPath returns the location the given writer logs to.
 func (w *RotateFileWriter) LogPath() string {
 	return w.f.Name()
@@ -118,7 +223,12 @@ func (w *RotateFileWriter) NotifyRotateEvict(sub chan interface{}) {
 	w.notifyRotate.Evict(sub)
 }
 
-// Close closes underlying file and signals all readers to stop.
+// Close closes underlying file and signals all readers to stop. It waits for
+// the last rotated file to be compressed.
 func (w *RotateFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.waitCompressed()
 	return w.f.Close()
 }
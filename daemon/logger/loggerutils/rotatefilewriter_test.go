@@ -0,0 +1,53 @@
+package loggerutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateUncompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotatefilewriter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the compression of container.log.1 failed
+	name := filepath.Join(dir, "container.log")
+	files := map[string]string{
+		name:           "current",
+		name + ".1":    "uncompressed",
+		name + ".2.gz": "oldest",
+	}
+	for path, content := range files {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rotate(name, 3); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		name + ".1": "current",
+		name + ".2": "uncompressed",
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d files after the rotation, got %d", len(expected), len(entries))
+	}
+	for path, content := range expected {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("expected %s to be %q, got %q", path, content, data)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/plugins/logdriver"
+	getter "github.com/docker/docker/pkg/plugingetter"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/plugin/v2"
+	"github.com/pkg/errors"
+)
+
+// extName is the capability of the log driver plugins.
+const extName = "LogDriver"
+
+// logRoot is the directory, as seen from the plugins, the FIFOs the
+// messages are sent over are created in.
+const logRoot = "/run/docker/logging"
+
+// Capability is what a log driver plugin supports besides logging.
+type Capability struct {
+	// ReadLogs is whether the plugin supports reading the messages it
+	// logged, for `docker logs`.
+	ReadLogs bool
+}
+
+// pluginGetter is nil until the daemon has set up its plugins, so that the
+// log drivers compiled in are the only ones known while it starts.
+var pluginGetter getter.PluginGetter
+
+// RegisterPluginGetter sets the plugingetter the log drivers which are not
+// compiled in are looked up from.
+func RegisterPluginGetter(plugingetter getter.PluginGetter) {
+	pluginGetter = plugingetter
+}
+
+// getPlugin returns the creator of the loggers of the log driver plugin
+// name.
+func getPlugin(name string, mode int) (Creator, error) {
+	if pluginGetter == nil {
+		return nil, fmt.Errorf("logger: no log driver named '%s' is registered", name)
+	}
+	if _, err := pluginGetter.Get(name, extName, mode); err != nil {
+		return nil, fmt.Errorf("logger: no log driver named '%s' is registered: %v", name, err)
+	}
+	return makePluginCreator(name), nil
+}
+
+// makePluginCreator returns the creator of the loggers of the plugin name.
+// Each logger holds a reference to the plugin until it is closed, and sends
+// the messages to the plugin over a FIFO of its own.
+func makePluginCreator(name string) Creator {
+	return func(info Info) (l Logger, err error) {
+		p, err := pluginGetter.Get(name, extName, getter.Acquire)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error looking up log driver plugin %s", name)
+		}
+		defer func() {
+			if err != nil {
+				pluginGetter.Get(name, extName, getter.Release)
+			}
+		}()
+
+		// the v2 plugins run in their own rootfs, which the FIFO is created
+		// in; the v1 plugins run on the host
+		scopePath := func(s string) string { return s }
+		if !p.IsV1() {
+			if p, ok := p.(*v2.Plugin); ok {
+				scopePath = p.ScopedPath
+			}
+		}
+		dir := scopePath(logRoot)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, errors.Wrap(err, "error creating the directory of the log FIFOs")
+		}
+
+		id := stringid.GenerateNonCryptoID()
+		a := &pluginAdapter{
+			driverName: name,
+			file:       filepath.Join(logRoot, id),
+			fifoPath:   filepath.Join(dir, id),
+			info:       info,
+			plugin:     &logPluginProxy{p.Client()},
+		}
+		cap, err := a.plugin.Capabilities()
+		if err != nil {
+			// plugins are not required to implement Capabilities
+			cap = Capability{}
+		}
+
+		a.stream, err = openPluginStream(a.fifoPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the log FIFO")
+		}
+		a.enc = logdriver.NewLogEntryEncoder(a.stream)
+		if err := a.plugin.StartLogging(a.file, info); err != nil {
+			a.closeStream()
+			return nil, errors.Wrapf(err, "error starting log driver plugin %s", name)
+		}
+
+		if cap.ReadLogs {
+			return &pluginAdapterWithRead{a}, nil
+		}
+		return a, nil
+	}
+}
@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/plugins/logdriver"
+	getter "github.com/docker/docker/pkg/plugingetter"
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// fakePluginGetter knows no plugins, and counts the references taken.
+type fakePluginGetter struct {
+	getter.PluginGetter
+	refs int
+}
+
+func (g *fakePluginGetter) Get(name, capability string, mode int) (getter.CompatPlugin, error) {
+	g.refs += mode
+	return nil, errors.New("not found")
+}
+
+// fakePluginClient records the calls to a plugin, and returns logs for
+// ReadLogs.
+type fakePluginClient struct {
+	calls []string
+	logs  []byte
+}
+
+func (c *fakePluginClient) Call(method string, args, ret interface{}) error {
+	c.calls = append(c.calls, method)
+	return nil
+}
+
+func (c *fakePluginClient) Stream(method string, args interface{}) (io.ReadCloser, error) {
+	c.calls = append(c.calls, method)
+	return ioutil.NopCloser(bytes.NewReader(c.logs)), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestPluginAdapter(t *testing.T) {
+	pg := &fakePluginGetter{refs: 1}
+	defer RegisterPluginGetter(nil)
+	RegisterPluginGetter(pg)
+
+	stream := &bytes.Buffer{}
+	c := &fakePluginClient{}
+	a := &pluginAdapter{
+		driverName: "test",
+		file:       "/run/docker/logging/test",
+		fifoPath:   "/nonexistent/test",
+		plugin:     &logPluginProxy{c},
+		stream:     nopWriteCloser{stream},
+		enc:        logdriver.NewLogEntryEncoder(stream),
+	}
+
+	now := time.Now()
+	messages := []*Message{
+		{Line: []byte("out"), Source: "stdout", Timestamp: now},
+		{Line: []byte("err"), Source: "stderr", Timestamp: now, Partial: true},
+	}
+	for _, msg := range messages {
+		if err := a.Log(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.calls) != 1 || c.calls[0] != "LogDriver.StopLogging" {
+		t.Fatalf("Expected the plugin to be told to stop logging, got %v", c.calls)
+	}
+	if pg.refs != 0 {
+		t.Fatalf("Expected the plugin to be released, got %d references", pg.refs)
+	}
+
+	dec := logdriver.NewLogEntryDecoder(stream)
+	for _, msg := range messages {
+		var entry logdriver.LogEntry
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if string(entry.Line) != string(msg.Line) || entry.Source != msg.Source || entry.TimeNano != now.UnixNano() || entry.Partial != msg.Partial {
+			t.Fatalf("Expected an entry for %+v, got %+v", msg, entry)
+		}
+	}
+}
+
+func TestPluginAdapterReadLogs(t *testing.T) {
+	logs := &bytes.Buffer{}
+	enc := logdriver.NewLogEntryEncoder(logs)
+	for i, line := range []string{"before", "after"} {
+		if err := enc.Encode(&logdriver.LogEntry{Source: "stdout", TimeNano: int64(i + 1), Line: []byte(line)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := &pluginAdapterWithRead{&pluginAdapter{plugin: &logPluginProxy{&fakePluginClient{logs: logs.Bytes()}}}}
+	watcher := a.ReadLogs(ReadConfig{Since: time.Unix(0, 2)})
+	defer watcher.Close()
+
+	var lines []string
+	for msg := range watcher.Msg {
+		lines = append(lines, string(msg.Line))
+	}
+	select {
+	case err := <-watcher.Err:
+		t.Fatal(err)
+	default:
+	}
+	if len(lines) != 1 || lines[0] != "after" {
+		t.Fatalf("Expected the messages since the time asked for, got %v", lines)
+	}
+}
+
+func TestGetUnknownPlugin(t *testing.T) {
+	if _, err := GetLogDriver("unknown"); err == nil {
+		t.Fatal("Expected an error for an unknown log driver before the plugins are set up")
+	}
+
+	defer RegisterPluginGetter(nil)
+	RegisterPluginGetter(&fakePluginGetter{})
+	if err := ValidateLogOpts("unknown", nil); err == nil {
+		t.Fatal("Expected an error for a log driver which is neither compiled in nor a plugin")
+	}
+}
+
+var _ client = &plugins.Client{}
@@ -0,0 +1,18 @@
+// +build linux solaris freebsd
+
+package logger
+
+import (
+	"io"
+	"syscall"
+
+	"github.com/tonistiigi/fifo"
+	"golang.org/x/net/context"
+)
+
+// openPluginStream creates the FIFO the messages are sent to a plugin over.
+// It does not block until the plugin opens it: the messages logged before
+// then are written once it does.
+func openPluginStream(path string) (io.WriteCloser, error) {
+	return fifo.OpenFifo(context.Background(), path, syscall.O_WRONLY|syscall.O_CREAT|syscall.O_NONBLOCK, 0700)
+}
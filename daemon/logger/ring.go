@@ -0,0 +1,192 @@
+package logger
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// DefaultMaxBufferSize is the size of the buffer of the non-blocking mode
+// when max-buffer-size is not set.
+const DefaultMaxBufferSize = 1024 * 1024
+
+// drainTimeout is how long Close waits for the messages buffered to be
+// delivered before dropping them.
+var drainTimeout = 5 * time.Second
+
+var errRingClosed = errors.New("logger: the log buffer is closed")
+
+// RingLogger is the logger of the non-blocking mode. Log never blocks: the
+// messages are buffered in memory, up to a size, and delivered to the
+// wrapped logger in the background. When the buffer is full, the oldest
+// messages are dropped.
+type RingLogger struct {
+	buffer *messageRing
+	l      Logger
+	info   Info
+	closed int32
+	done   chan struct{}
+}
+
+// ringWithReader is a RingLogger wrapping a logger which can read logs.
+type ringWithReader struct {
+	*RingLogger
+}
+
+func (r *ringWithReader) ReadLogs(cfg ReadConfig) *LogWatcher {
+	return r.l.(LogReader).ReadLogs(cfg)
+}
+
+// NewRingLogger wraps l in a RingLogger which buffers up to maxSize bytes
+// of messages. The returned logger can read logs if l can.
+func NewRingLogger(l Logger, info Info, maxSize int64) Logger {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBufferSize
+	}
+	r := &RingLogger{
+		buffer: newMessageRing(maxSize),
+		l:      l,
+		info:   info,
+		done:   make(chan struct{}),
+	}
+	go r.run()
+	if _, ok := l.(LogReader); ok {
+		return &ringWithReader{r}
+	}
+	return r
+}
+
+// Log buffers a copy of msg, which is delivered to the wrapped logger in the
+// background.
+func (r *RingLogger) Log(msg *Message) error {
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return errRingClosed
+	}
+	if dropped := r.buffer.Enqueue(CopyMessage(msg)); dropped > 0 {
+		droppedMessages.WithValues(r.l.Name()).Inc(float64(dropped))
+	}
+	return nil
+}
+
+// Name returns the name of the wrapped logger.
+func (r *RingLogger) Name() string {
+	return r.l.Name()
+}
+
+// Close stops buffering the messages, and waits for the messages buffered to
+// be delivered before closing the wrapped logger. The messages which are not
+// delivered in time are dropped, but the one being delivered is never
+// interrupted: the wrapped logger is only closed once it is not logging.
+func (r *RingLogger) Close() error {
+	if !atomic.CompareAndSwapInt32(&r.closed, 0, 1) {
+		return errRingClosed
+	}
+	r.buffer.Close()
+
+	select {
+	case <-r.done:
+	case <-time.After(drainTimeout):
+		if dropped := r.buffer.Drop(); dropped > 0 {
+			droppedMessages.WithValues(r.l.Name()).Inc(float64(dropped))
+			logrus.WithField("container", r.info.ContainerID).Warnf("Dropped %d log messages which were not delivered to the %s logger in %s", dropped, r.l.Name(), drainTimeout)
+		}
+		<-r.done
+	}
+	return r.l.Close()
+}
+
+// run delivers the messages buffered until the buffer is closed and empty.
+func (r *RingLogger) run() {
+	defer close(r.done)
+	for {
+		msg, err := r.buffer.Dequeue()
+		if err != nil {
+			return
+		}
+		if err := r.l.Log(msg); err != nil {
+			logrus.WithField("container", r.info.ContainerID).Debugf("Failed to log message for logger %s: %v", r.l.Name(), err)
+		}
+	}
+}
+
+// messageRing is a FIFO of messages bounded by the size of their lines.
+type messageRing struct {
+	mu      sync.Mutex
+	wait    *sync.Cond // signaled when a message is enqueued or the ring is closed
+	queue   []*Message
+	size    int64
+	maxSize int64
+	closed  bool
+}
+
+func newMessageRing(maxSize int64) *messageRing {
+	r := &messageRing{maxSize: maxSize}
+	r.wait = sync.NewCond(&r.mu)
+	return r
+}
+
+// Enqueue adds msg to the ring, dropping the oldest messages until it fits,
+// and returns the number of messages dropped. A message larger than the
+// ring replaces all the others.
+func (r *messageRing) Enqueue(msg *Message) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 1
+	}
+	dropped := 0
+	size := int64(len(msg.Line))
+	for len(r.queue) > 0 && r.size+size > r.maxSize {
+		r.size -= int64(len(r.queue[0].Line))
+		r.queue[0] = nil
+		r.queue = r.queue[1:]
+		dropped++
+	}
+	r.queue = append(r.queue, msg)
+	r.size += size
+	r.wait.Signal()
+	return dropped
+}
+
+// Dequeue removes the oldest message from the ring, waiting for one if it is
+// empty. Once the ring is closed, it returns the messages left, then
+// errRingClosed.
+func (r *messageRing) Dequeue() (*Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for len(r.queue) == 0 && !r.closed {
+		r.wait.Wait()
+	}
+	if len(r.queue) == 0 {
+		return nil, errRingClosed
+	}
+	msg := r.queue[0]
+	r.queue[0] = nil
+	r.queue = r.queue[1:]
+	r.size -= int64(len(msg.Line))
+	return msg, nil
+}
+
+// Close stops the ring from accepting messages, and wakes up Dequeue.
+func (r *messageRing) Close() {
+	r.mu.Lock()
+	r.closed = true
+	r.wait.Broadcast()
+	r.mu.Unlock()
+}
+
+// Drop removes the messages left in the ring, and returns their number.
+func (r *messageRing) Drop() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dropped := len(r.queue)
+	r.queue = nil
+	r.size = 0
+	return dropped
+}
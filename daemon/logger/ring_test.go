@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingLogger records the messages it logs, once unblocked.
+type blockingLogger struct {
+	unblock chan struct{}
+	logged  chan string
+	closed  bool
+}
+
+func newBlockingLogger() *blockingLogger {
+	return &blockingLogger{unblock: make(chan struct{}), logged: make(chan string, 100)}
+}
+
+func (l *blockingLogger) Log(msg *Message) error {
+	<-l.unblock
+	l.logged <- string(msg.Line)
+	return nil
+}
+
+func (l *blockingLogger) Name() string { return "blocking" }
+
+func (l *blockingLogger) Close() error {
+	l.closed = true
+	return nil
+}
+
+func TestRingLoggerDoesNotBlock(t *testing.T) {
+	l := newBlockingLogger()
+	r := NewRingLogger(l, Info{}, 6)
+
+	// the first message is taken by the delivery, which blocks, and the
+	// next ones fill the buffer of 6 bytes
+	line := []byte("aa")
+	if err := r.Log(&Message{Line: line}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		for _, s := range []string{"bb", "cc", "dd", "ee"} {
+			copy(line, s)
+			r.Log(&Message{Line: line})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Log blocked on a slow logger")
+	}
+
+	close(l.unblock)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !l.closed {
+		t.Fatal("Expected the wrapped logger to be closed")
+	}
+	close(l.logged)
+	var logged []string
+	for s := range l.logged {
+		logged = append(logged, s)
+	}
+	// "bb" was dropped to make room for "ee"
+	if strings.Join(logged, ",") != "aa,cc,dd,ee" {
+		t.Fatalf("Expected the oldest messages to be dropped, got %v", logged)
+	}
+	if err := r.Log(&Message{Line: line}); err == nil {
+		t.Fatal("Expected an error logging to a closed logger")
+	}
+}
+
+func TestRingLoggerCloseTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { drainTimeout = timeout }(drainTimeout)
+	drainTimeout = 50 * time.Millisecond
+
+	l := newBlockingLogger()
+	r := NewRingLogger(l, Info{}, 0)
+	for i := 0; i < 3; i++ {
+		r.Log(&Message{Line: []byte(fmt.Sprintf("line %d", i))})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.Close()
+		close(done)
+	}()
+
+	// the wrapped logger is not closed while it is logging the first
+	// message, even once the other ones are dropped
+	select {
+	case <-done:
+		t.Fatal("Close returned while a message was being delivered")
+	case <-time.After(4 * drainTimeout):
+	}
+	close(l.unblock)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return once the message was delivered")
+	}
+	if !l.closed {
+		t.Fatal("Expected the wrapped logger to be closed")
+	}
+	close(l.logged)
+	var logged []string
+	for s := range l.logged {
+		logged = append(logged, s)
+	}
+	if strings.Join(logged, ",") != "line 0" {
+		t.Fatalf("Expected the messages left after the timeout to be dropped, got %v", logged)
+	}
+}
+
+func TestRingLoggerReader(t *testing.T) {
+	r := NewRingLogger(&readerLogger{newBlockingLogger()}, Info{}, 0)
+	if _, ok := r.(LogReader); !ok {
+		t.Fatal("Expected the ring logger of a reader to be a reader")
+	}
+	if _, ok := NewRingLogger(newBlockingLogger(), Info{}, 0).(LogReader); ok {
+		t.Fatal("Expected the ring logger of a logger which cannot read not to be a reader")
+	}
+}
+
+type readerLogger struct {
+	*blockingLogger
+}
+
+func (l *readerLogger) ReadLogs(ReadConfig) *LogWatcher {
+	return NewLogWatcher()
+}
+
+func TestValidateLogMode(t *testing.T) {
+	RegisterLogDriver("ring-test", func(Info) (Logger, error) { return newBlockingLogger(), nil })
+	RegisterLogOptValidator("ring-test", func(cfg map[string]string) error {
+		for k := range cfg {
+			return fmt.Errorf("unknown log opt %s", k)
+		}
+		return nil
+	})
+
+	for _, cfg := range []map[string]string{
+		{},
+		{"mode": "blocking"},
+		{"mode": "non-blocking"},
+		{"mode": "non-blocking", "max-buffer-size": "4m"},
+	} {
+		if err := ValidateLogOpts("ring-test", cfg); err != nil {
+			t.Fatalf("Expected %v to be valid, got %v", cfg, err)
+		}
+	}
+	for _, cfg := range []map[string]string{
+		{"mode": "lossy"},
+		{"max-buffer-size": "4m"},
+		{"mode": "non-blocking", "max-buffer-size": "lots"},
+		{"mode": "non-blocking", "other": "value"},
+	} {
+		if err := ValidateLogOpts("ring-test", cfg); err == nil {
+			t.Fatalf("Expected %v to be invalid", cfg)
+		}
+	}
+}
@@ -3,17 +3,20 @@ package daemon
 import (
 	"errors"
 	"io"
+	"os"
 	"strconv"
 	"time"
 
 	"golang.org/x/net/context"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/backend"
 	containertypes "github.com/docker/docker/api/types/container"
 	timetypes "github.com/docker/docker/api/types/time"
 	"github.com/docker/docker/container"
 	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/loggerutils/cache"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/stdcopy"
 )
@@ -42,15 +45,12 @@ func (daemon *Daemon) ContainerLogs(ctx context.Context, containerName string, c
 		return logger.ErrReadLogsNotSupported
 	}
 
-	follow := config.Follow && container.IsRunning()
 	tailLines, err := strconv.Atoi(config.Tail)
 	if err != nil {
 		tailLines = -1
 	}
 
-	logrus.Debug("logs: begin stream")
-
-	var since time.Time
+	var since, until time.Time
 	if config.Since != "" {
 		s, n, err := timetypes.ParseTimestamps(config.Since, 0)
 		if err != nil {
@@ -58,13 +58,38 @@ func (daemon *Daemon) ContainerLogs(ctx context.Context, containerName string, c
 		}
 		since = time.Unix(s, n)
 	}
+	if config.Until != "" {
+		s, n, err := timetypes.ParseTimestamps(config.Until, 0)
+		if err != nil {
+			return err
+		}
+		until = time.Unix(s, n)
+	}
+	filter, err := logger.NewMessageFilter(logSources(&config.ContainerLogsOptions), config.Grep)
+	if err != nil {
+		return err
+	}
+	// the logs are not followed past until
+	follow := config.Follow && container.IsRunning() && (until.IsZero() || until.After(time.Now()))
+
+	logrus.Debug("logs: begin stream")
+
 	readConfig := logger.ReadConfig{
 		Since:  since,
+		Until:  until,
 		Tail:   tailLines,
 		Follow: follow,
+		Filter: filter,
 	}
 	logs := logReader.ReadLogs(readConfig)
 
+	var untilTimer <-chan time.Time
+	if follow && !until.IsZero() {
+		t := time.NewTimer(until.Sub(time.Now()))
+		defer t.Stop()
+		untilTimer = t.C
+	}
+
 	wf := ioutils.NewWriteFlusher(config.OutStream)
 	defer wf.Close()
 	close(started)
@@ -86,6 +111,10 @@ func (daemon *Daemon) ContainerLogs(ctx context.Context, containerName string, c
 		case <-ctx.Done():
 			logs.Close()
 			return nil
+		case <-untilTimer:
+			// the reader stops following the logs, and ends the stream
+			untilTimer = nil
+			logs.Close()
 		case msg, ok := <-logs.Msg:
 			if !ok {
 				logrus.Debug("logs: end stream")
@@ -116,10 +145,36 @@ func (daemon *Daemon) ContainerLogs(ctx context.Context, containerName string, c
 	}
 }
 
+// logSources returns the streams of the logs asked for by config, nil for
+// all of them.
+func logSources(config *types.ContainerLogsOptions) []string {
+	switch {
+	case config.ShowStdout && config.ShowStderr:
+		return nil
+	case config.ShowStdout:
+		return []string{"stdout"}
+	case config.ShowStderr:
+		return []string{"stderr"}
+	}
+	return nil
+}
+
 func (daemon *Daemon) getLogger(container *container.Container) (logger.Logger, error) {
 	if container.LogDriver != nil && container.IsRunning() {
 		return container.LogDriver, nil
 	}
+	// the logs of a stopped container whose driver cannot read them are read
+	// from its local cache, without creating the driver
+	cfg := container.HostConfig.LogConfig
+	if cache.ShouldUseCache(cfg.Config) {
+		logPath, err := container.GetRootResourcePath("container-cached.log")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(logPath); err == nil {
+			return cache.NewReader(logger.Info{Config: cfg.Config, LogPath: logPath})
+		}
+	}
 	return container.StartLogger()
 }
 
@@ -141,5 +196,8 @@ func (daemon *Daemon) mergeAndVerifyLogConfig(cfg *containertypes.LogConfig) err
 		}
 	}
 
+	// the options of the log cache apply to every driver
+	cache.MergeDefaultLogConfig(cfg.Config, daemon.defaultLogConfig.Config)
+
 	return logger.ValidateLogOpts(cfg.Type, cfg.Config)
 }
@@ -1,7 +1,6 @@
 package daemon
 
 import (
-	"C"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -18,15 +17,6 @@ import (
 	"github.com/docker/docker/container"
 	"github.com/docker/docker/runconfig"
 )
-import (
-	"crypto/sha256"
-	"unsafe"
-)
-
-// #include <stdlib.h>
-// #include "libport.h"
-// #cgo LDFLAGS: -lport
-import "C"
 
 // ContainerStart starts a container.
 func (daemon *Daemon) ContainerStart(name string, hostConfig *containertypes.HostConfig, checkpoint string, checkpointDir string) error {
@@ -119,6 +109,12 @@ func (daemon *Daemon) containerStart(container *container.Container, checkpoint
 		return fmt.Errorf("Container is marked for removal and cannot be started.")
 	}
 
+	if daemon.TapconModeOn() && container.Config.UseTapcon {
+		if err := daemon.tapconCheckImage(container.ImageID.String(), container.Config.Image, "start"); err != nil {
+			return err
+		}
+	}
+
 	// if we encounter an error during start we need to ensure that any other
 	// setup has been cleaned up properly
 	defer func() {
@@ -174,6 +170,30 @@ func (daemon *Daemon) containerStart(container *container.Container, checkpoint
 		checkpointDir = container.CheckpointDir()
 	}
 
+	var tapconAttachments []tapconAttachment
+	if daemon.TapconModeOn() && container.Config.UseTapcon {
+		// the digest is registered as the principal's configuration once
+		// the container is running
+		digest, err := tapconConfigDigest(container.Config, container.HostConfig)
+		if err != nil {
+			return err
+		}
+		container.TapconConfigDigest = digest
+		lo, hi, err := daemon.tapconPorts.Allocate(container.ID)
+		if err != nil {
+			return err
+		}
+		container.TapconPortMin, container.TapconPortMax = lo, hi
+		// the firewall is in place before the container runs, and the
+		// container is not started without it
+		if tapconAttachments, err = daemon.tapconAttachments(container); err != nil {
+			return fmt.Errorf("Error resolving tapcon addresses of container: %v", err)
+		}
+		if err := daemon.tapconSetupFirewall(container, tapconAttachments); err != nil {
+			return fmt.Errorf("Error setting up tapcon firewall for container: %v", err)
+		}
+	}
+
 	if err := daemon.containerd.Create(container.ID, checkpoint, checkpointDir, *spec, container.InitializeStdio, createOptions...); err != nil {
 		errDesc := grpc.ErrorDesc(err)
 		contains := func(s1, s2 string) bool {
@@ -203,112 +223,7 @@ func (daemon *Daemon) containerStart(container *container.Container, checkpoint
 		return fmt.Errorf("%s", errDesc)
 	}
 	if daemon.TapconModeOn() && container.Config.UseTapcon {
-		// compute sha256 of the configuration file
-		logrus.Info("TapconDebug: enter tapcon mode, container = %p", container)
-		hash := sha256.New()
-		/////sort
-		filterMap := make(map[string]bool)
-		if container.Config.FilterOpts != "" {
-			toFilters := strings.Split(container.Config.FilterOpts, ";")
-			for _, k := range toFilters {
-				filterMap[k] = true
-			}
-		}
-		for _, env := range container.Config.Env {
-			name := strings.SplitN(env, "=", 2)[0]
-			if _, ok := filterMap[name]; !ok {
-				hash.Write([]byte(env))
-			} else {
-				logrus.Debug("filtered env: ", name)
-			}
-		}
-
-		//hash.Write([]byte(strings.Join(container.Config.Entrypoint, " ")))
-		//hash.Write([]byte(strings.Join(container.Config.Cmd, " ")))
-		//for _, label := range container.Config.Labels {
-		//	hash.Write([]byte(label))
-		//}
-		//hash.Write([]byte(strings.Join(container.Config.Shell, " ")))
-		//hash.Write([]byte(container.Config.WorkingDir))
-		////sort
-		//for k := range container.Config.Volumes {
-		//	hash.Write([]byte(k))
-		//}
-		//for port, _ := range container.Config.ExposedPorts {
-		//	hash.Write([]byte(port))
-		//}
-
-		//for _, cap := range container.HostConfig.CapAdd {
-		//	hash.Write([]byte(cap))
-		//}
-		//for _, cap := range container.HostConfig.CapDrop {
-		//	hash.Write([]byte(cap))
-		//}
-		//for _, dns := range container.HostConfig.DNS {
-		//	hash.Write([]byte(dns))
-		//}
-		//for _, dnsSearch := range container.HostConfig.DNSSearch {
-		//	hash.Write([]byte(dnsSearch))
-		//}
-		//for _, dnsOpt := range container.HostConfig.DNSOptions {
-		//	hash.Write([]byte(dnsOpt))
-		//}
-		//for _, dev := range container.HostConfig.Devices {
-		//	hash.Write([]byte(dev.PathOnHost))
-		//}
-		//hash.Write([]byte(container.HostConfig.InitPath))
-		//hash.Write([]byte(container.HostConfig.IpcMode))
-		//hash.Write([]byte(container.HostConfig.PidMode))
-		//hash.Write([]byte(container.HostConfig.NetworkMode))
-		//if container.HostConfig.Privileged {
-		//	hash.Write([]byte{1})
-		//} else {
-		//	hash.Write([]byte{0})
-		//}
-		//if container.HostConfig.PublishAllPorts {
-		//	hash.Write([]byte{1})
-		//} else {
-		//	hash.Write([]byte{0})
-		//}
-		//for _, link := range container.HostConfig.Links {
-		//	hash.Write([]byte(link))
-		//}
-		//hash.Write([]byte(container.HostConfig.Runtime))
-		//hash.Write([]byte(container.HostConfig.UTSMode))
-		//hash.Write([]byte(container.HostConfig.UsernsMode))
-		//hash.Write([]byte(container.HostConfig.VolumeDriver))
-
-		cimage := C.CString(container.ImageID.String())
-		//var configStr string
-		//if container.Config.FilterOpts != "" {
-		//  configStr = container.Config.FilterOpts + ";"
-		//}
-		//chash := C.CString(configStr + hex.EncodeToString(hash.Sum(nil)))
-		chash := C.CString("default")
-		/// We can directly obtain it since it's still locked!
-		pid := C.uint64_t(container.GetPID())
-		logrus.Info("TapconDebug: before create principal")
-
-		if n, ok := container.NetworkSettings.Networks["bridge"]; ok {
-			containerIp := n.IPAddress
-			logrus.Info("creating container on IP: ", containerIp)
-			cip := C.CString(containerIp)
-			pmin, _ := C.liblatte_create_principal_with_allocated_ports(
-				pid, cimage, chash, cip, 1, 65535)
-			if pmin <= 0 {
-				logrus.Info("fail to create principal")
-			}
-			C.free(unsafe.Pointer(cip))
-		} else {
-			logrus.Info("fail to obtain network address of container")
-		}
-		C.free(unsafe.Pointer(cimage))
-		C.free(unsafe.Pointer(chash))
-		if err := daemon.tapconSetupFirewall(container); err != nil {
-			logrus.Errorf("Error setting up tapcon firewall for container: %s", err)
-		}
-		logrus.Info("TapconDebug: finish start")
-
+		daemon.tapconCreatePrincipals(container, tapconAttachments)
 	}
 
 	containerActions.WithValues("start").UpdateSince(start)
@@ -323,6 +238,10 @@ func (daemon *Daemon) Cleanup(container *container.Container) {
 
 	if daemon.TapconModeOn() && container.Config.UseTapcon {
 		daemon.tapconRemoveFirewall(container)
+		// principals of containers which exited on their own
+		daemon.tapconDeletePrincipals(container)
+		daemon.tapconPorts.Release(container.ID)
+		container.TapconPortMin, container.TapconPortMax = 0, 0
 	}
 
 	container.UnmountIpcMounts(detachMounted)
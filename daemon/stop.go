@@ -10,10 +10,6 @@ import (
 	"github.com/docker/docker/container"
 )
 
-// #cgo LDFLAGS: -lport
-// #include "libport.h"
-import "C"
-
 // ContainerStop looks for the given container and terminates it,
 // waiting the given number of seconds before forcefully killing the
 // container. If a negative number of seconds is given, ContainerStop
@@ -52,8 +48,7 @@ func (daemon *Daemon) containerStop(container *container.Container, seconds int)
 		if err := daemon.tapconRemoveFirewall(container); err != nil {
 			logrus.Errorf("Error tearing down tapcon firewall for container: %s", err)
 		}
-		pid := C.uint64_t(container.GetPID())
-		C.liblatte_delete_principal_without_allocated_ports(pid)
+		daemon.tapconDeletePrincipals(container)
 	}
 
 	daemon.stopHealthchecks(container)
@@ -3,140 +3,249 @@ package daemon
 import (
 	"errors"
 	"fmt"
-	"net"
-	"os/exec"
-	"sync"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/container"
 	docker_image "github.com/docker/docker/image"
+	"github.com/docker/libnetwork/iptables"
 )
 
-const MaxLen = 12
+const (
+	MaxLen      = 12
+	chainPrefix = "ctn-"
+)
 
 func containerChainName(id string) string {
 	if len(id) < MaxLen {
-		return fmt.Sprintf("ctn-%s", id)
-	} else {
-		return fmt.Sprintf("ctn-%s", id[:MaxLen])
+		return chainPrefix + id
 	}
+	return chainPrefix + id[:MaxLen]
 }
 
-var (
-	containerBridgeIp string
-	initBridgeFlag    sync.Once
-)
+// tapconAttachment describes how the traffic of a container on one of its
+// networks is seen by the attestation service.
+type tapconAttachment struct {
+	// Network is the name of the network.
+	Network string
+	// Source is the address of the container on the network.
+	Source string
+	// Address is the address registered for the principal. It differs from
+	// Source when the traffic is masqueraded by the host.
+	Address string
+	// NAT is set when the traffic is masqueraded to Address.
+	NAT bool
+}
 
-func (daemon *Daemon) daemonIp() string {
-	bname := daemon.configStore.bridgeConfig.Iface
-	log.Info("bridge name ", bname)
-	ifaces, err := net.Interfaces()
+// tapconAttachments resolves the address of the container on each network
+// it is attached to. Bridge networks, including the gateway bridge of
+// overlay networks, are masqueraded to their gateway, which is the address
+// of the bridge interface on the host. Other drivers expose the address of
+// the container as is.
+func (daemon *Daemon) tapconAttachments(container *container.Container) ([]tapconAttachment, error) {
+	if container.NetworkSettings == nil || container.NetworkSettings.SandboxID == "" {
+		return nil, errors.New("container has no network sandbox")
+	}
+	sb, err := daemon.netController.SandboxByID(container.NetworkSettings.SandboxID)
 	if err != nil {
-		log.Errorf("error fetching interfaces :%v\n", err)
-		return ""
-	}
-	for _, iface := range ifaces {
-		if iface.Name == bname {
-			addrs, err := iface.Addrs()
-			if err != nil {
-				log.Errorf("error fetching addresses :%v\n", err)
-				continue
-			}
-			log.Info("useful addresses: ", addrs)
-			for _, addr := range addrs {
-				var ip net.IP
-				switch v := addr.(type) {
-				case *net.IPNet:
-					ip = v.IP
-				case *net.IPAddr:
-					ip = v.IP
-				}
-				if ip.To4() != nil {
-					return ip.String()
-				}
-			}
+		return nil, err
+	}
+
+	var attachments []tapconAttachment
+	for _, ep := range sb.Endpoints() {
+		info := ep.Info()
+		if info == nil || info.Iface() == nil || info.Iface().Address() == nil {
+			continue
+		}
+		n, err := daemon.netController.NetworkByName(ep.Network())
+		if err != nil {
+			return nil, err
+		}
+		a := tapconAttachment{
+			Network: n.Name(),
+			Source:  info.Iface().Address().IP.String(),
 		}
+		a.Address = a.Source
+		if gw := info.Gateway(); n.Type() == "bridge" && gw != nil && gw.To4() != nil {
+			a.Address = gw.String()
+			a.NAT = true
+		}
+		log.Debugf("Tapcon container %s on network %s: %s -> %s", container.ID, a.Network, a.Source, a.Address)
+		attachments = append(attachments, a)
+	}
+	if len(attachments) == 0 {
+		return nil, errors.New("container has no network address")
 	}
-	return ""
+	return attachments, nil
 }
 
-func (daemon *Daemon) tapconImageBuilt(image *docker_image.Image, tapconData interface{}) {
-	sourceinfo, ok := tapconData.(docker_image.Source)
-	if !ok {
-		fmt.Printf("debug: image without source info, can only be intermediate layers")
+// tapconImageBuilt records the provenance passed by the builder in the
+// config of a newly committed image, stamped with the identity of this
+// daemon unless the image is reproducible.
+func (daemon *Daemon) tapconImageBuilt(image *docker_image.Image, tapconData interface{}, reproducible bool) {
+	source, ok := tapconData.(*docker_image.Source)
+	if !ok || source == nil {
+		// intermediate layers carry no provenance
 		return
 	}
-	image.Source = sourceinfo
+	if !reproducible {
+		source.Builder = daemon.ID
+	}
+	image.Source = source
 }
 
-func (daemon *Daemon) tapconSetupFirewall(container *container.Container) error {
-	log.Info("TapconDebug: start firewall")
-	initBridgeFlag.Do(func() {
-		containerBridgeIp = daemon.daemonIp()
-		log.Infof("TapconDebug: obtain container bridge IP: [%s]", containerBridgeIp)
-	})
-
-	id := container.ID
-	chainName := containerChainName(id)
-	cmd := exec.Command("iptables", "-t", "nat", "-N", chainName)
-
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Errorf("error creating chain: %s", string(out))
-		return err
-	}
-
-	cmd = exec.Command("iptables", "-t", "nat", "-I", "POSTROUTING", "-j", chainName)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Errorf("error inserting chain: %s", string(out))
-		// we ignore the error here...
-		exec.Command("iptables", "-t", "nat", "-X", chainName).Run()
-		return err
-	}
-	var containerIp string
-	if n, ok := container.NetworkSettings.Networks["bridge"]; ok {
-		containerIp = n.IPAddress
-	} else {
-		log.Debug("container network setting: %v", container.NetworkSettings.Networks)
-		exec.Command("iptables", "-t", "nat", "-X", chainName).Run()
-		return errors.New("only support bridge network")
-	}
-	// TODO: should add a drop rule to range besides [port_min, port_max]
-	// only support Tcp for now
-	cmd = exec.Command("iptables", "-t", "nat", "-A", chainName,
-		"-p", "tcp", "-s", containerIp,
-		"-j", "SNAT", "--to-source",
-		fmt.Sprintf("%s", containerBridgeIp))
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Errorf("error inserting static mapping rule: %s", string(out))
-		// clear the chain
-		exec.Command("iptables", "-t", "nat", "-F", chainName).Run()
-		return err
+// EndorseImage endorses an image with the given property through the
+// attestation backend.
+func (daemon *Daemon) EndorseImage(imageID, config, property string) error {
+	if daemon.attestor == nil {
+		return errors.New("tapcon attestation backend is not initialized")
 	}
+	return daemon.attestor.EndorseImage(imageID, config, property)
+}
+
+// tapconChains are the tables in which each tapcon container gets a chain,
+// and the builtin chains jumping to it.
+var tapconChains = []struct {
+	table  iptables.Table
+	parent string
+}{
+	{iptables.Nat, "POSTROUTING"},
+	{iptables.Filter, "FORWARD"},
+}
 
+// removeTapconChain removes the chain of a container from every table.
+// Missing rules and chains are ignored, so it can be used to clean up
+// partially programmed chains.
+func removeTapconChain(chainName string) error {
+	var errs []string
+	for _, c := range tapconChains {
+		if err := iptables.ProgramRule(c.table, c.parent, iptables.Delete, []string{"-j", chainName}); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if iptables.ExistChain(chainName, c.table) {
+			if err := iptables.RemoveExistingChain(chainName, c.table); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error removing chain %s: %s", chainName, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (daemon *Daemon) tapconSetupFirewall(container *container.Container, attachments []tapconAttachment) (err error) {
+	daemon.tapconFirewallLock.Lock()
+	defer daemon.tapconFirewallLock.Unlock()
+
+	log.Debugf("Setting up tapcon firewall of container %s", container.ID)
+	chainName := containerChainName(container.ID)
+	// start from a clean state in case a previous run left rules behind
+	if err := removeTapconChain(chainName); err != nil {
+		log.Warnf("Failed to clean up tapcon chain of container %s: %v", container.ID, err)
+	}
+	defer func() {
+		if err != nil {
+			if err := removeTapconChain(chainName); err != nil {
+				log.Warnf("Failed to clean up tapcon chain of container %s: %v", container.ID, err)
+			}
+		}
+	}()
+
+	var (
+		snat []string
+		drop []string
+	)
+	for _, a := range attachments {
+		if !a.NAT {
+			continue
+		}
+		for _, proto := range []string{"tcp", "udp"} {
+			snat = append(snat, fmt.Sprintf("-p %s -s %s -j SNAT --to-source %s:%d-%d",
+				proto, a.Source, a.Address, container.TapconPortMin, container.TapconPortMax))
+			// SNAT cannot be relied on for flows tracked before the
+			// rules were in place, so drop any forwarded traffic of
+			// the container whose translated source port is outside
+			// of its range.
+			drop = append(drop, fmt.Sprintf("-p %s -s %s -m conntrack --ctstate SNAT ! --ctrepldstport %d:%d -j DROP",
+				proto, a.Source, container.TapconPortMin, container.TapconPortMax))
+		}
+	}
+
+	for _, c := range tapconChains {
+		if _, err := iptables.NewChain(chainName, c.table, false); err != nil {
+			return err
+		}
+		if err := iptables.ProgramRule(c.table, c.parent, iptables.Insert, []string{"-j", chainName}); err != nil {
+			return err
+		}
+		rules := snat
+		if c.table == iptables.Filter {
+			rules = drop
+		}
+		for _, rule := range rules {
+			if err := iptables.ProgramRule(c.table, chainName, iptables.Append, strings.Fields(rule)); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 func (daemon *Daemon) tapconRemoveFirewall(container *container.Container) error {
+	daemon.tapconFirewallLock.Lock()
+	defer daemon.tapconFirewallLock.Unlock()
+
+	log.Debugf("Removing tapcon firewall of container %s", container.ID)
+	return removeTapconChain(containerChainName(container.ID))
+}
 
-	log.Info("TapconDebug: removing firewall")
-	id := container.ID
-	chainName := containerChainName(id)
-	cmd := exec.Command("iptables", "-t", "nat", "-D", "POSTROUTING", "-j", chainName)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Errorf("error clearing jumping to static mapping chain: %s", string(out))
-		return err
+// tapconReconcileFirewall removes the chains left behind by containers
+// which are no longer running, typically after a daemon crash, and
+// reprograms the chains of the running tapcon containers.
+func (daemon *Daemon) tapconReconcileFirewall() {
+	running := make(map[string]*container.Container)
+	for _, c := range daemon.List() {
+		if c.IsRunning() && c.Config.UseTapcon {
+			running[containerChainName(c.ID)] = c
+		}
 	}
 
-	cmd = exec.Command("iptables", "-t", "nat", "-F", chainName)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Errorf("error clearing static mapping chain: %s", string(out))
-		return err
+	stale := make(map[string]struct{})
+	for _, c := range tapconChains {
+		out, err := iptables.Raw("-t", string(c.table), "-S")
+		if err != nil {
+			log.Errorf("Failed to list %s chains: %v", c.table, err)
+			continue
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 || fields[0] != "-N" || !strings.HasPrefix(fields[1], chainPrefix) {
+				continue
+			}
+			if _, ok := running[fields[1]]; !ok {
+				stale[fields[1]] = struct{}{}
+			}
+		}
 	}
 
-	cmd = exec.Command("iptables", "-t", "nat", "-X", chainName)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Errorf("error deleting static mapping chain: %s", string(out))
-		return err
+	daemon.tapconFirewallLock.Lock()
+	for chainName := range stale {
+		log.Infof("Removing stale tapcon chain %s", chainName)
+		if err := removeTapconChain(chainName); err != nil {
+			log.Errorf("Failed to remove stale tapcon chain: %v", err)
+		}
+	}
+	daemon.tapconFirewallLock.Unlock()
+
+	for _, c := range running {
+		attachments, err := daemon.tapconAttachments(c)
+		if err != nil {
+			log.Errorf("Failed to resolve tapcon addresses of container %s: %v", c.ID, err)
+			continue
+		}
+		if err := daemon.tapconSetupFirewall(c, attachments); err != nil {
+			log.Errorf("Failed to restore tapcon firewall of container %s: %v", c.ID, err)
+		}
 	}
-	return nil
 }
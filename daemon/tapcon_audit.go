@@ -0,0 +1,195 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+)
+
+// Actions recorded in the tapcon audit log.
+const (
+	tapconAuditCreate   = "create"
+	tapconAuditRegister = "register"
+	tapconAuditDelete   = "delete"
+)
+
+// maxTapconDeletedPrincipals bounds the number of deleted principals kept in
+// memory. The audit log keeps all of them.
+const maxTapconDeletedPrincipals = 1000
+
+// tapconAuditEntry is a line of the audit log.
+type tapconAuditEntry struct {
+	Time      string
+	Action    string
+	Principal types.TapconPrincipal
+}
+
+// tapconAudit keeps track of the principals created by the daemon and
+// appends every change to an audit log. Replaying the log on start restores
+// the principals of the containers which kept running.
+type tapconAudit struct {
+	mu      sync.Mutex
+	f       *os.File
+	active  []types.TapconPrincipal
+	deleted []types.TapconPrincipal
+}
+
+// newTapconAudit loads the principals recorded in the audit log at path and
+// opens it for appending.
+func newTapconAudit(path string) (*tapconAudit, error) {
+	a := &tapconAudit{}
+	f, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		err = a.replay(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.f, err = os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600); err != nil {
+		return nil, err
+	}
+	// terminate an entry truncated by a crash, so that it does not corrupt
+	// the next one
+	if fi, err := a.f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := a.f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			a.f.Write([]byte{'\n'})
+		}
+	}
+	return a, nil
+}
+
+// replay applies the entries read from r. Lines which cannot be decoded,
+// such as one truncated by a crash, are skipped.
+func (a *tapconAudit) replay(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var e tapconAuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			logrus.Warnf("Skipping invalid tapcon audit log entry: %v", err)
+			continue
+		}
+		a.apply(e)
+	}
+	return scanner.Err()
+}
+
+// apply updates the principals with an entry of the log.
+func (a *tapconAudit) apply(e tapconAuditEntry) {
+	p := e.Principal
+	switch e.Action {
+	case tapconAuditCreate:
+		a.active = append(a.active, p)
+	case tapconAuditRegister, tapconAuditDelete:
+		for i, q := range a.active {
+			if q.ContainerID != p.ContainerID || q.PID != p.PID || q.IP != p.IP {
+				continue
+			}
+			if e.Action == tapconAuditRegister {
+				a.active[i] = p
+				break
+			}
+			a.active = append(a.active[:i], a.active[i+1:]...)
+			a.deleted = append(a.deleted, p)
+			if len(a.deleted) > maxTapconDeletedPrincipals {
+				a.deleted = a.deleted[len(a.deleted)-maxTapconDeletedPrincipals:]
+			}
+			break
+		}
+	}
+}
+
+// record applies an entry and appends it to the log. Failing to write the
+// log does not affect the principals kept in memory.
+func (a *tapconAudit) record(action string, p types.TapconPrincipal, now time.Time) {
+	e := tapconAuditEntry{
+		Time:      now.UTC().Format(time.RFC3339Nano),
+		Action:    action,
+		Principal: p,
+	}
+	a.apply(e)
+
+	line, err := json.Marshal(e)
+	if err == nil {
+		_, err = a.f.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = a.f.Sync()
+	}
+	if err != nil {
+		logrus.Errorf("Failed to write tapcon audit log: %v", err)
+	}
+}
+
+// Created records a new principal. Its Error is set if it could not be
+// registered with the attestation service.
+func (a *tapconAudit) Created(p types.TapconPrincipal) types.TapconPrincipal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	p.Created = now.UTC().Format(time.RFC3339Nano)
+	a.record(tapconAuditCreate, p, now)
+	return p
+}
+
+// Registered records another attempt to register an active principal.
+func (a *tapconAudit) Registered(p types.TapconPrincipal) {
+	a.mu.Lock()
+	a.record(tapconAuditRegister, p, time.Now())
+	a.mu.Unlock()
+}
+
+// Deleted marks the given active principal as deleted.
+func (a *tapconAudit) Deleted(p types.TapconPrincipal) types.TapconPrincipal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	p.Deleted = now.UTC().Format(time.RFC3339Nano)
+	a.record(tapconAuditDelete, p, now)
+	return p
+}
+
+// Active returns the active principals of a container, or of all containers
+// if containerID is empty.
+func (a *tapconAudit) Active(containerID string) []types.TapconPrincipal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var ps []types.TapconPrincipal
+	for _, p := range a.active {
+		if containerID == "" || p.ContainerID == containerID {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// List returns the active principals, preceded by the most recently deleted
+// ones if all is set.
+func (a *tapconAudit) List(all bool) []types.TapconPrincipal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ps := []types.TapconPrincipal{}
+	if all {
+		ps = append(ps, a.deleted...)
+	}
+	return append(ps, a.active...)
+}
+
+// Close closes the audit log.
+func (a *tapconAudit) Close() error {
+	return a.f.Close()
+}
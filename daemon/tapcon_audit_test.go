@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestTapconAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tapcon-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	a, err := newTapconAudit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1 := a.Created(types.TapconPrincipal{ContainerID: "c1", PID: 1, IP: "172.17.0.1"})
+	p2 := a.Created(types.TapconPrincipal{ContainerID: "c1", PID: 1, IP: "10.0.0.1", Error: "refused"})
+	a.Created(types.TapconPrincipal{ContainerID: "c2", PID: 2, IP: "172.17.0.1"})
+	if p1.Created == "" {
+		t.Fatal("expected the creation time to be set")
+	}
+
+	p2.Error = ""
+	a.Registered(p2)
+	if ps := a.Active("c1"); len(ps) != 2 || ps[1].Error != "" {
+		t.Fatalf("expected 2 registered principals for c1, got %v", ps)
+	}
+
+	if d := a.Deleted(p1); d.Deleted == "" {
+		t.Fatal("expected the deletion time to be set")
+	}
+	if ps := a.List(false); len(ps) != 2 {
+		t.Fatalf("expected 2 active principals, got %v", ps)
+	}
+	if ps := a.List(true); len(ps) != 3 || ps[0].IP != p1.IP || ps[0].Deleted == "" {
+		t.Fatalf("expected the deleted principal first, got %v", ps)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the log is append-only, one entry per line
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e tapconAuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, e.Action)
+	}
+	f.Close()
+	expected := []string{tapconAuditCreate, tapconAuditCreate, tapconAuditCreate, tapconAuditRegister, tapconAuditDelete}
+	if len(actions) != len(expected) {
+		t.Fatalf("expected actions %v, got %v", expected, actions)
+	}
+	for i := range expected {
+		if actions[i] != expected[i] {
+			t.Fatalf("expected actions %v, got %v", expected, actions)
+		}
+	}
+
+	// a truncated entry is skipped on replay
+	f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Action":"create","Princ`)
+	f.Close()
+
+	a, err = newTapconAudit(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps := a.List(true); len(ps) != 3 {
+		t.Fatalf("expected the principals to be restored, got %v", ps)
+	}
+	if ps := a.Active(""); len(ps) != 2 || ps[0].IP != p2.IP || ps[1].ContainerID != "c2" {
+		t.Fatalf("expected the active principals to be restored, got %v", ps)
+	}
+
+	a.Deleted(p2)
+	a.Close()
+	if a, err = newTapconAudit(path); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if ps := a.Active(""); len(ps) != 1 || ps[0].ContainerID != "c2" {
+		t.Fatalf("expected the entry following the truncated one to be kept, got %v", ps)
+	}
+}
@@ -0,0 +1,258 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	containertypes "github.com/docker/docker/api/types/container"
+)
+
+// canonicalMount is the security-relevant part of a mount.
+type canonicalMount struct {
+	Type     string `json:"type"`
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"readonly"`
+}
+
+type byTarget []canonicalMount
+
+func (m byTarget) Len() int           { return len(m) }
+func (m byTarget) Less(i, j int) bool { return m[i].Target < m[j].Target }
+func (m byTarget) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// canonicalDevice is the security-relevant part of a device mapping.
+type canonicalDevice struct {
+	PathOnHost        string `json:"host"`
+	PathInContainer   string `json:"container"`
+	CgroupPermissions string `json:"permissions"`
+}
+
+type byPathInContainer []canonicalDevice
+
+func (d byPathInContainer) Len() int           { return len(d) }
+func (d byPathInContainer) Less(i, j int) bool { return d[i].PathInContainer < d[j].PathInContainer }
+func (d byPathInContainer) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// canonicalConfig is the stable serialization of the parts of a container's
+// configuration that affect what it is able to do. Every slice whose order
+// does not matter is sorted, and json.Marshal sorts map keys, so two
+// equivalent configurations always serialize identically.
+type canonicalConfig struct {
+	User        string            `json:"user"`
+	Env         []string          `json:"env"`
+	Entrypoint  []string          `json:"entrypoint"`
+	Cmd         []string          `json:"cmd"`
+	Shell       []string          `json:"shell"`
+	WorkingDir  string            `json:"workdir"`
+	Labels      map[string]string `json:"labels"`
+	Volumes     []string          `json:"volumes"`
+	ExposedPort []string          `json:"exposed_ports"`
+
+	Binds           []string          `json:"binds"`
+	Mounts          []canonicalMount  `json:"mounts"`
+	Tmpfs           map[string]string `json:"tmpfs"`
+	VolumeDriver    string            `json:"volume_driver"`
+	VolumesFrom     []string          `json:"volumes_from"`
+	CapAdd          []string          `json:"cap_add"`
+	CapDrop         []string          `json:"cap_drop"`
+	Privileged      bool              `json:"privileged"`
+	ReadonlyRootfs  bool              `json:"readonly_rootfs"`
+	SecurityOpt     []string          `json:"security_opt"`
+	Devices         []canonicalDevice `json:"devices"`
+	GroupAdd        []string          `json:"group_add"`
+	Sysctls         map[string]string `json:"sysctls"`
+	NetworkMode     string            `json:"network_mode"`
+	IpcMode         string            `json:"ipc_mode"`
+	PidMode         string            `json:"pid_mode"`
+	UTSMode         string            `json:"uts_mode"`
+	UsernsMode      string            `json:"userns_mode"`
+	Cgroup          string            `json:"cgroup"`
+	PublishAllPorts bool              `json:"publish_all_ports"`
+	PortBindings    []string          `json:"port_bindings"`
+	Links           []string          `json:"links"`
+	DNS             []string          `json:"dns"`
+	DNSOptions      []string          `json:"dns_options"`
+	DNSSearch       []string          `json:"dns_search"`
+	ExtraHosts      []string          `json:"extra_hosts"`
+	Runtime         string            `json:"runtime"`
+	Init            bool              `json:"init"`
+	InitPath        string            `json:"init_path"`
+}
+
+// tapconFilters returns the set of names listed in the FilterOpts of config.
+// Environment variables and labels with those names are left out of the
+// configuration digest.
+func tapconFilters(config *containertypes.Config) map[string]bool {
+	filters := make(map[string]bool)
+	for _, k := range strings.Split(config.FilterOpts, ";") {
+		if k = strings.TrimSpace(k); k != "" {
+			filters[k] = true
+		}
+	}
+	return filters
+}
+
+// nonEmpty returns s, or nil if s is empty.
+func nonEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// sortedStrings returns a sorted copy of s, or nil if s is empty.
+func sortedStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), s...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// upperStrings returns a sorted, upper cased copy of s, or nil if s is empty.
+func upperStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	upper := make([]string, 0, len(s))
+	for _, v := range s {
+		upper = append(upper, strings.ToUpper(v))
+	}
+	sort.Strings(upper)
+	return upper
+}
+
+// stringMap returns m, or nil if m is empty.
+func stringMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+func newCanonicalConfig(config *containertypes.Config, hostConfig *containertypes.HostConfig) *canonicalConfig {
+	filters := tapconFilters(config)
+	c := &canonicalConfig{
+		User:       config.User,
+		Entrypoint: nonEmpty(config.Entrypoint),
+		Cmd:        nonEmpty(config.Cmd),
+		Shell:      nonEmpty(config.Shell),
+		WorkingDir: config.WorkingDir,
+	}
+
+	// later definitions of a variable override earlier ones
+	env := make(map[string]string)
+	for _, e := range config.Env {
+		kv := strings.SplitN(e, "=", 2)
+		if filters[kv[0]] {
+			continue
+		}
+		env[kv[0]] = e
+	}
+	for _, e := range env {
+		c.Env = append(c.Env, e)
+	}
+	sort.Strings(c.Env)
+
+	for k, v := range config.Labels {
+		if filters[k] {
+			continue
+		}
+		if c.Labels == nil {
+			c.Labels = make(map[string]string)
+		}
+		c.Labels[k] = v
+	}
+	for v := range config.Volumes {
+		c.Volumes = append(c.Volumes, v)
+	}
+	sort.Strings(c.Volumes)
+	for p := range config.ExposedPorts {
+		c.ExposedPort = append(c.ExposedPort, string(p))
+	}
+	sort.Strings(c.ExposedPort)
+
+	if hostConfig == nil {
+		return c
+	}
+
+	c.Binds = sortedStrings(hostConfig.Binds)
+	for _, m := range hostConfig.Mounts {
+		c.Mounts = append(c.Mounts, canonicalMount{
+			Type:     string(m.Type),
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+	sort.Sort(byTarget(c.Mounts))
+	c.Tmpfs = stringMap(hostConfig.Tmpfs)
+	c.VolumeDriver = hostConfig.VolumeDriver
+	c.VolumesFrom = sortedStrings(hostConfig.VolumesFrom)
+	c.CapAdd = upperStrings(hostConfig.CapAdd)
+	c.CapDrop = upperStrings(hostConfig.CapDrop)
+	c.Privileged = hostConfig.Privileged
+	c.ReadonlyRootfs = hostConfig.ReadonlyRootfs
+	c.SecurityOpt = sortedStrings(hostConfig.SecurityOpt)
+	for _, d := range hostConfig.Devices {
+		c.Devices = append(c.Devices, canonicalDevice{
+			PathOnHost:        d.PathOnHost,
+			PathInContainer:   d.PathInContainer,
+			CgroupPermissions: d.CgroupPermissions,
+		})
+	}
+	sort.Sort(byPathInContainer(c.Devices))
+	c.GroupAdd = sortedStrings(hostConfig.GroupAdd)
+	c.Sysctls = stringMap(hostConfig.Sysctls)
+	c.NetworkMode = string(hostConfig.NetworkMode)
+	c.IpcMode = string(hostConfig.IpcMode)
+	c.PidMode = string(hostConfig.PidMode)
+	c.UTSMode = string(hostConfig.UTSMode)
+	c.UsernsMode = string(hostConfig.UsernsMode)
+	c.Cgroup = string(hostConfig.Cgroup)
+	c.PublishAllPorts = hostConfig.PublishAllPorts
+	for port, bindings := range hostConfig.PortBindings {
+		for _, b := range bindings {
+			c.PortBindings = append(c.PortBindings, fmt.Sprintf("%s:%s->%s", b.HostIP, b.HostPort, port))
+		}
+	}
+	sort.Strings(c.PortBindings)
+	c.Links = sortedStrings(hostConfig.Links)
+	c.DNS = sortedStrings(hostConfig.DNS)
+	c.DNSOptions = sortedStrings(hostConfig.DNSOptions)
+	c.DNSSearch = sortedStrings(hostConfig.DNSSearch)
+	c.ExtraHosts = sortedStrings(hostConfig.ExtraHosts)
+	c.Runtime = hostConfig.Runtime
+	if hostConfig.Init != nil {
+		c.Init = *hostConfig.Init
+	}
+	c.InitPath = hostConfig.InitPath
+	return c
+}
+
+// tapconConfigDigest computes the canonical digest of the security-relevant
+// configuration of a container.
+func tapconConfigDigest(config *containertypes.Config, hostConfig *containertypes.HostConfig) (string, error) {
+	b, err := json.Marshal(newCanonicalConfig(config, hostConfig))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// tapconPrincipalConfig returns the configuration string registered for a
+// principal: the digest, prefixed by the filtered names if there are any,
+// so that the attestation service can tell what was left out.
+func tapconPrincipalConfig(config *containertypes.Config, digest string) string {
+	if config.FilterOpts == "" {
+		return digest
+	}
+	return config.FilterOpts + ";" + digest
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"testing"
+
+	containertypes "github.com/docker/docker/api/types/container"
+)
+
+func TestTapconConfigDigestIsCanonical(t *testing.T) {
+	c1 := &containertypes.Config{
+		Env:    []string{"A=1", "B=2"},
+		Cmd:    []string{"sh", "-c", "true"},
+		Labels: map[string]string{"x": "1", "y": "2"},
+	}
+	h1 := &containertypes.HostConfig{
+		CapAdd:     []string{"net_admin", "SYS_PTRACE"},
+		Binds:      []string{"/a:/a", "/b:/b"},
+		Privileged: false,
+	}
+	c2 := &containertypes.Config{
+		Env:    []string{"B=2", "A=1"},
+		Cmd:    []string{"sh", "-c", "true"},
+		Labels: map[string]string{"y": "2", "x": "1"},
+	}
+	h2 := &containertypes.HostConfig{
+		CapAdd: []string{"SYS_PTRACE", "NET_ADMIN"},
+		Binds:  []string{"/b:/b", "/a:/a"},
+	}
+
+	d1, err := tapconConfigDigest(c1, h1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := tapconConfigDigest(c2, h2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d1 != d2 {
+		t.Fatalf("expected equivalent configurations to have the same digest, got %s and %s", d1, d2)
+	}
+
+	h2.Privileged = true
+	d3, err := tapconConfigDigest(c2, h2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d3 == d1 {
+		t.Fatal("expected the privileged flag to change the digest")
+	}
+
+	c2.Cmd = []string{"-c", "sh", "true"}
+	d4, err := tapconConfigDigest(c2, h2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d4 == d3 {
+		t.Fatal("expected the command order to change the digest")
+	}
+}
+
+func TestTapconConfigDigestFilters(t *testing.T) {
+	c1 := &containertypes.Config{
+		Env:        []string{"A=1", "TOKEN=secret"},
+		Labels:     map[string]string{"build": "42"},
+		FilterOpts: "TOKEN;build",
+	}
+	c2 := &containertypes.Config{
+		Env:        []string{"A=1", "TOKEN=other"},
+		Labels:     map[string]string{"build": "43"},
+		FilterOpts: "TOKEN;build",
+	}
+	d1, err := tapconConfigDigest(c1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := tapconConfigDigest(c2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d1 != d2 {
+		t.Fatalf("expected filtered names not to change the digest, got %s and %s", d1, d2)
+	}
+	if cfg := tapconPrincipalConfig(c1, d1); cfg != "TOKEN;build;"+d1 {
+		t.Fatalf("unexpected principal config %q", cfg)
+	}
+}
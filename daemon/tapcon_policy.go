@@ -0,0 +1,243 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/attestation"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+// Tapcon policies, which decide what happens to images lacking an
+// endorsement for their ID and source.
+const (
+	// tapconPolicyEnforce rejects them.
+	tapconPolicyEnforce = "enforce"
+	// tapconPolicyWarn lets them through with a warning.
+	tapconPolicyWarn = "warn"
+	// tapconPolicyOff does not check endorsements.
+	tapconPolicyOff = "off"
+)
+
+// Events emitted for each decision taken by the tapcon policy.
+const (
+	tapconEventAccept = "tapcon_accept"
+	tapconEventWarn   = "tapcon_warn"
+	tapconEventReject = "tapcon_reject"
+)
+
+// validateTapconPolicy checks that policy is known, and that attestor can
+// verify images if the policy requires it.
+func validateTapconPolicy(policy string, attestor attestation.Attestor) error {
+	switch policy {
+	case "", tapconPolicyOff:
+		return nil
+	case tapconPolicyEnforce, tapconPolicyWarn:
+	default:
+		return fmt.Errorf("invalid tapcon policy %q: must be one of %s, %s or %s", policy, tapconPolicyEnforce, tapconPolicyWarn, tapconPolicyOff)
+	}
+	if _, ok := attestor.(attestation.ImageVerifier); !ok {
+		return fmt.Errorf("tapcon policy %s requires an attestation backend able to verify images, %s is not", policy, attestor.Name())
+	}
+	return nil
+}
+
+// tapconPolicyOn returns whether endorsements are checked.
+func (daemon *Daemon) tapconPolicyOn() bool {
+	policy := daemon.configStore.TapconPolicy
+	return daemon.TapconModeOn() && policy != "" && policy != tapconPolicyOff
+}
+
+// tapconVerifyImage checks that img carries its provenance and that the
+// attestation service endorsed it with its source.
+func (daemon *Daemon) tapconVerifyImage(img *image.Image) error {
+	if img.Source == nil {
+		return errors.New("image has no provenance")
+	}
+	if daemon.attestor == nil {
+		return errors.New("attestation backend is not initialized")
+	}
+	verifier, ok := daemon.attestor.(attestation.ImageVerifier)
+	if !ok {
+		return fmt.Errorf("attestation backend %s cannot verify images", daemon.attestor.Name())
+	}
+	return verifier.VerifyImage(img.ID().String(), img.Source.Endorsement())
+}
+
+// tapconCheckImage applies the tapcon policy to the image imageID, which
+// was referred to as refName by the operation op (pull, load or start).
+// Each decision is logged as an event. It returns an error if the image is
+// rejected.
+func (daemon *Daemon) tapconCheckImage(imageID, refName, op string) error {
+	if !daemon.tapconPolicyOn() {
+		return nil
+	}
+	img, err := daemon.GetImage(imageID)
+	if err != nil {
+		return err
+	}
+
+	attributes := map[string]string{"operation": op}
+	verr := daemon.tapconVerifyImage(img)
+	if verr == nil {
+		daemon.LogImageEventWithAttributes(img.ID().String(), refName, tapconEventAccept, attributes)
+		return nil
+	}
+	attributes["reason"] = verr.Error()
+	if daemon.configStore.TapconPolicy == tapconPolicyWarn {
+		logrus.Warnf("Image %s is not endorsed: %v", refName, verr)
+		daemon.LogImageEventWithAttributes(img.ID().String(), refName, tapconEventWarn, attributes)
+		return nil
+	}
+	daemon.LogImageEventWithAttributes(img.ID().String(), refName, tapconEventReject, attributes)
+	return fmt.Errorf("image %s is not endorsed: %v", refName, verr)
+}
+
+// tapconAddedReference is a reference set by a pull or a load.
+type tapconAddedReference struct {
+	ref reference.Named
+	id  digest.Digest
+	// prevID is the image the reference pointed to before, if any.
+	prevID digest.Digest
+}
+
+// tapconReferenceRecorder records the references set through it, so that
+// the references to rejected images can be rolled back.
+type tapconReferenceRecorder struct {
+	reference.Store
+	added []tapconAddedReference
+}
+
+func (r *tapconReferenceRecorder) AddTag(ref reference.Named, id digest.Digest, force bool) error {
+	return r.record(ref, id, func() error {
+		return r.Store.AddTag(ref, id, force)
+	})
+}
+
+func (r *tapconReferenceRecorder) AddDigest(ref reference.Canonical, id digest.Digest, force bool) error {
+	return r.record(ref, id, func() error {
+		return r.Store.AddDigest(ref, id, force)
+	})
+}
+
+func (r *tapconReferenceRecorder) record(ref reference.Named, id digest.Digest, add func() error) error {
+	prevID, _ := r.Store.Get(ref)
+	if err := add(); err != nil {
+		return err
+	}
+	if prevID != id {
+		r.added = append(r.added, tapconAddedReference{ref: ref, id: id, prevID: prevID})
+	}
+	return nil
+}
+
+// tapconRejectImage applies the tapcon policy to an image which was just
+// pulled, loaded or imported. If it is rejected, the references added to it
+// by the operation are rolled back.
+func (daemon *Daemon) tapconRejectImage(imageID, refName, op string, added []tapconAddedReference) error {
+	err := daemon.tapconCheckImage(imageID, refName, op)
+	if err == nil {
+		return nil
+	}
+	daemon.tapconRollbackReferences(digest.Digest(imageID), added)
+	return err
+}
+
+// tapconRollbackReferences removes the references which were added to the
+// rejected image id, and points the ones which were moved to it back to
+// their previous image. The image is then removed, unless it is still
+// referenced or used by a container: it is never removed by force.
+func (daemon *Daemon) tapconRollbackReferences(id digest.Digest, added []tapconAddedReference) {
+	for _, a := range added {
+		if a.id != id {
+			continue
+		}
+		var err error
+		if a.prevID == "" {
+			_, err = daemon.referenceStore.Delete(a.ref)
+		} else if canonical, ok := a.ref.(reference.Canonical); ok {
+			err = daemon.referenceStore.AddDigest(canonical, a.prevID, true)
+		} else {
+			err = daemon.referenceStore.AddTag(a.ref, a.prevID, true)
+		}
+		if err != nil {
+			logrus.Errorf("Failed to roll back reference %s of rejected image %s: %v", a.ref.String(), id, err)
+			continue
+		}
+		daemon.LogImageEvent(id.String(), a.ref.String(), "untag")
+	}
+
+	if len(daemon.referenceStore.References(id)) > 0 {
+		return
+	}
+	if _, err := daemon.ImageDelete(id.String(), false, true); err != nil {
+		logrus.Debugf("Rejected image %s was not removed: %v", id, err)
+	}
+}
+
+// tapconCheckPull applies the tapcon policy to the images pulled for ref.
+// A reference without tag nor digest stands for every tag of the
+// repository. added are the references set by the pull.
+func (daemon *Daemon) tapconCheckPull(ref reference.Named, added []tapconAddedReference) error {
+	if !daemon.tapconPolicyOn() {
+		return nil
+	}
+	var associations []reference.Association
+	_, tagged := ref.(reference.NamedTagged)
+	_, canonical := ref.(reference.Canonical)
+	if tagged || canonical {
+		id, err := daemon.referenceStore.Get(ref)
+		if err != nil {
+			return err
+		}
+		associations = append(associations, reference.Association{Ref: ref, ID: id})
+	} else {
+		associations = daemon.referenceStore.ReferencesByName(ref)
+	}
+
+	var rejected error
+	checked := make(map[digest.Digest]bool)
+	for _, a := range associations {
+		// the references of a rejected image are all rolled back at once
+		if checked[a.ID] {
+			continue
+		}
+		checked[a.ID] = true
+		if err := daemon.tapconRejectImage(a.ID.String(), a.Ref.String(), "pull", added); err != nil && rejected == nil {
+			rejected = err
+		}
+	}
+	return rejected
+}
+
+// tapconCheckLoad applies the tapcon policy to the images loaded from a
+// tar archive. added are the references set by the load.
+func (daemon *Daemon) tapconCheckLoad(ids []string, added []tapconAddedReference) error {
+	if !daemon.tapconPolicyOn() {
+		return nil
+	}
+	var rejected error
+	for _, id := range ids {
+		if err := daemon.tapconRejectImage(id, id, "load", added); err != nil && rejected == nil {
+			rejected = err
+		}
+	}
+	return rejected
+}
+
+// tapconImageLoader records the images loaded by a tar exporter, while
+// forwarding its events to the daemon.
+type tapconImageLoader struct {
+	*Daemon
+	loaded []string
+}
+
+func (l *tapconImageLoader) LogImageEvent(imageID, refName, action string) {
+	l.Daemon.LogImageEvent(imageID, refName, action)
+	if action == "load" {
+		l.loaded = append(l.loaded, imageID)
+	}
+}
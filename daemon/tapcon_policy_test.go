@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/daemon/attestation"
+	"github.com/docker/docker/daemon/attestation/memory"
+	"github.com/docker/docker/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+// blindAttestor is an attestor which cannot verify images.
+type blindAttestor struct {
+	attestation.Attestor
+}
+
+func (blindAttestor) Name() string {
+	return "blind"
+}
+
+func TestValidateTapconPolicy(t *testing.T) {
+	verifier := memory.New()
+	blind := blindAttestor{}
+
+	for _, policy := range []string{"", tapconPolicyOff} {
+		if err := validateTapconPolicy(policy, blind); err != nil {
+			t.Fatalf("expected policy %q to be valid with any backend, got %v", policy, err)
+		}
+	}
+	for _, policy := range []string{tapconPolicyEnforce, tapconPolicyWarn} {
+		if err := validateTapconPolicy(policy, verifier); err != nil {
+			t.Fatalf("expected policy %q to be valid, got %v", policy, err)
+		}
+		if err := validateTapconPolicy(policy, blind); err == nil {
+			t.Fatalf("expected policy %q to require a backend able to verify images", policy)
+		}
+	}
+	if err := validateTapconPolicy("strict", verifier); err == nil {
+		t.Fatal("expected an error for an unknown policy")
+	}
+}
+
+func TestTapconReferenceRecorder(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "tapcon-references")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store, err := reference.NewReferenceStore(filepath.Join(tmp, "repositories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldID := digest.Digest("sha256:" + strings.Repeat("a", 64))
+	newID := digest.Digest("sha256:" + strings.Repeat("b", 64))
+	parse := func(s string) reference.Named {
+		ref, err := reference.ParseNamed(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+	if err := store.AddTag(parse("busybox:moved"), oldID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddTag(parse("busybox:same"), newID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &tapconReferenceRecorder{Store: store}
+	for _, tag := range []string{"busybox:moved", "busybox:same", "busybox:new"} {
+		if err := r.AddTag(parse(tag), newID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(r.added) != 2 {
+		t.Fatalf("expected 2 added references, got %v", r.added)
+	}
+	if r.added[0].ref.String() != "busybox:moved" || r.added[0].prevID != oldID {
+		t.Fatalf("expected busybox:moved to be recorded with its previous image, got %v", r.added[0])
+	}
+	if r.added[1].ref.String() != "busybox:new" || r.added[1].prevID != "" {
+		t.Fatalf("expected busybox:new to be recorded without previous image, got %v", r.added[1])
+	}
+}
@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/docker/go-connections/nat"
+)
+
+var errNoTapconPortAllocator = errors.New("tapcon port allocator is not initialized")
+
+const (
+	defaultTapconPortRange         = "10000-59999"
+	defaultTapconPortsPerContainer = 1000
+)
+
+// tapconPortAllocator hands out disjoint source port ranges to tapcon
+// containers. The configured range is split into fixed-size blocks, and
+// each container owns at most one block at a time.
+type tapconPortAllocator struct {
+	mu     sync.Mutex
+	min    int
+	size   int
+	blocks []string // owner container ID of each block, empty if free
+}
+
+// newTapconPortAllocator creates an allocator over portRange, a range in
+// the form "lo-hi", split into blocks of size ports.
+func newTapconPortAllocator(portRange string, size int) (*tapconPortAllocator, error) {
+	lo, hi, err := nat.ParsePortRangeToInt(portRange)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tapcon port range %q: %v", portRange, err)
+	}
+	if lo <= 0 || hi > 65535 {
+		return nil, fmt.Errorf("invalid tapcon port range %q: ports must be between 1 and 65535", portRange)
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid number of tapcon ports per container: %d", size)
+	}
+	n := (hi - lo + 1) / size
+	if n == 0 {
+		return nil, fmt.Errorf("tapcon port range %q is smaller than %d ports", portRange, size)
+	}
+	return &tapconPortAllocator{
+		min:    lo,
+		size:   size,
+		blocks: make([]string, n),
+	}, nil
+}
+
+func (a *tapconPortAllocator) blockRange(i int) (int, int) {
+	lo := a.min + i*a.size
+	return lo, lo + a.size - 1
+}
+
+// Allocate returns the port range owned by the container id, reserving a
+// free one if it does not own any.
+func (a *tapconPortAllocator) Allocate(id string) (int, int, error) {
+	if a == nil {
+		return 0, 0, errNoTapconPortAllocator
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	free := -1
+	for i, owner := range a.blocks {
+		if owner == id {
+			lo, hi := a.blockRange(i)
+			return lo, hi, nil
+		}
+		if owner == "" && free < 0 {
+			free = i
+		}
+	}
+	if free < 0 {
+		return 0, 0, fmt.Errorf("no tapcon port range left for container %s", id)
+	}
+	a.blocks[free] = id
+	lo, hi := a.blockRange(free)
+	return lo, hi, nil
+}
+
+// Reserve marks [lo, hi] as owned by the container id. It is used to restore
+// the allocations of containers that kept running across a daemon restart.
+func (a *tapconPortAllocator) Reserve(id string, lo, hi int) error {
+	if a == nil {
+		return errNoTapconPortAllocator
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := (lo - a.min) / a.size
+	if lo < a.min || i >= len(a.blocks) {
+		return fmt.Errorf("tapcon port range %d-%d of container %s is out of the configured range", lo, hi, id)
+	}
+	if blo, bhi := a.blockRange(i); blo != lo || bhi != hi {
+		return fmt.Errorf("tapcon port range %d-%d of container %s does not match the configured blocks", lo, hi, id)
+	}
+	if owner := a.blocks[i]; owner != "" && owner != id {
+		return fmt.Errorf("tapcon port range %d-%d is already owned by container %s", lo, hi, owner)
+	}
+	a.blocks[i] = id
+	return nil
+}
+
+// Release frees the port range owned by the container id, if any.
+func (a *tapconPortAllocator) Release(id string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i, owner := range a.blocks {
+		if owner == id {
+			a.blocks[i] = ""
+		}
+	}
+}
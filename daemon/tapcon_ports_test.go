@@ -0,0 +1,76 @@
+package daemon
+
+import "testing"
+
+func TestTapconPortAllocator(t *testing.T) {
+	a, err := newTapconPortAllocator("10000-10299", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lo, hi, err := a.Allocate("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lo != 10000 || hi != 10099 {
+		t.Fatalf("expected 10000-10099, got %d-%d", lo, hi)
+	}
+	if lo2, hi2, _ := a.Allocate("c1"); lo2 != lo || hi2 != hi {
+		t.Fatalf("expected the same range for the same container, got %d-%d", lo2, hi2)
+	}
+	if err := a.Reserve("c2", 10200, 10299); err != nil {
+		t.Fatal(err)
+	}
+	if lo, hi, _ = a.Allocate("c3"); lo != 10100 || hi != 10199 {
+		t.Fatalf("expected 10100-10199, got %d-%d", lo, hi)
+	}
+	if _, _, err := a.Allocate("c4"); err == nil {
+		t.Fatal("expected an error when all ranges are allocated")
+	}
+
+	a.Release("c1")
+	if lo, hi, _ = a.Allocate("c4"); lo != 10000 || hi != 10099 {
+		t.Fatalf("expected the released range, got %d-%d", lo, hi)
+	}
+}
+
+func TestTapconPortAllocatorReserve(t *testing.T) {
+	a, err := newTapconPortAllocator("10000-10299", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Reserve("c1", 10050, 10149); err == nil {
+		t.Fatal("expected an error reserving a range which is not a block")
+	}
+	if err := a.Reserve("c1", 20000, 20099); err == nil {
+		t.Fatal("expected an error reserving a range out of bounds")
+	}
+	if err := a.Reserve("c1", 10000, 10099); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Reserve("c2", 10000, 10099); err == nil {
+		t.Fatal("expected an error reserving a range owned by another container")
+	}
+}
+
+func TestNewTapconPortAllocatorInvalid(t *testing.T) {
+	for _, r := range []string{"", "0-100", "100-70000", "abc"} {
+		if _, err := newTapconPortAllocator(r, 10); err == nil {
+			t.Fatalf("expected an error for range %q", r)
+		}
+	}
+	if _, err := newTapconPortAllocator("1000-1009", 20); err == nil {
+		t.Fatal("expected an error for a range smaller than a block")
+	}
+}
+
+func TestTapconPortAllocatorNil(t *testing.T) {
+	var a *tapconPortAllocator
+	if _, _, err := a.Allocate("c1"); err != errNoTapconPortAllocator {
+		t.Fatalf("expected %v, got %v", errNoTapconPortAllocator, err)
+	}
+	if err := a.Reserve("c1", 10000, 10099); err != errNoTapconPortAllocator {
+		t.Fatalf("expected %v, got %v", errNoTapconPortAllocator, err)
+	}
+	a.Release("c1")
+}
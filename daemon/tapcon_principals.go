@@ -0,0 +1,195 @@
+package daemon
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	apierrors "github.com/docker/docker/api/errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/container"
+	"github.com/docker/docker/daemon/attestation"
+)
+
+var errTapconNotEnabled = apierrors.NewErrorWithStatusCode(errors.New("tapcon mode is not enabled on this daemon"), http.StatusServiceUnavailable)
+
+func tapconAttestationPrincipal(p types.TapconPrincipal) attestation.Principal {
+	return attestation.Principal{
+		PID:     p.PID,
+		Image:   p.ImageID,
+		Config:  p.Config,
+		IP:      p.IP,
+		PortMin: p.PortMin,
+		PortMax: p.PortMax,
+	}
+}
+
+// tapconCreatePrincipals registers a principal for each address of a
+// container which was just started, and records them in the audit log.
+// Principals which fail to register are recorded with their error so that
+// they can be registered again by TapconReconcile. The caller holds the
+// container lock.
+func (daemon *Daemon) tapconCreatePrincipals(container *container.Container, attachments []tapconAttachment) {
+	daemon.tapconPrincipalsLock.Lock()
+	defer daemon.tapconPrincipalsLock.Unlock()
+
+	for _, a := range attachments {
+		p := types.TapconPrincipal{
+			ContainerID: container.ID,
+			// We can directly obtain the pid since it's still locked!
+			PID:     uint64(container.Pid),
+			ImageID: container.ImageID.String(),
+			Config:  tapconPrincipalConfig(container.Config, container.TapconConfigDigest),
+			Network: a.Network,
+			IP:      a.Address,
+			PortMin: container.TapconPortMin,
+			PortMax: container.TapconPortMax,
+		}
+		logrus.Infof("Creating tapcon principal for container %s on network %s, IP: %s", container.ID, a.Network, a.Address)
+		if err := daemon.attestor.CreatePrincipal(tapconAttestationPrincipal(p)); err != nil {
+			logrus.Errorf("Error creating tapcon principal for container %s: %v", container.ID, err)
+			p.Error = err.Error()
+		}
+		daemon.tapconAudit.Created(p)
+	}
+}
+
+// tapconDeletePrincipals deletes the active principals of a container. It
+// relies on the PIDs recorded when they were created, so it also works once
+// the container has exited.
+func (daemon *Daemon) tapconDeletePrincipals(container *container.Container) {
+	daemon.tapconPrincipalsLock.Lock()
+	defer daemon.tapconPrincipalsLock.Unlock()
+
+	daemon.tapconDeleteRecorded(daemon.tapconAudit.Active(container.ID))
+}
+
+// tapconDeleteRecorded deletes principals from the attestation service and
+// records them as deleted. The service deletes all addresses of a PID at
+// once.
+func (daemon *Daemon) tapconDeleteRecorded(principals []types.TapconPrincipal) []types.TapconPrincipal {
+	var deleted []types.TapconPrincipal
+	done := make(map[uint64]bool)
+	for _, p := range principals {
+		if !done[p.PID] {
+			done[p.PID] = true
+			if err := daemon.attestor.DeletePrincipal(p.PID); err != nil {
+				logrus.Errorf("Error deleting tapcon principal %d of container %s: %v", p.PID, p.ContainerID, err)
+			}
+		}
+		deleted = append(deleted, daemon.tapconAudit.Deleted(p))
+	}
+	return deleted
+}
+
+// tapconRunningContainer is the state of a running tapcon container at the
+// time it was looked at.
+type tapconRunningContainer struct {
+	container *container.Container
+	pid       int
+}
+
+// tapconRunning returns the running tapcon containers. It takes the lock of
+// each container, so it must not be called with tapconPrincipalsLock held:
+// containers which exit take the two in the opposite order.
+func (daemon *Daemon) tapconRunning() map[string]tapconRunningContainer {
+	running := make(map[string]tapconRunningContainer)
+	for _, c := range daemon.List() {
+		if !c.Config.UseTapcon {
+			continue
+		}
+		c.Lock()
+		if c.Running {
+			running[c.ID] = tapconRunningContainer{container: c, pid: c.Pid}
+		}
+		c.Unlock()
+	}
+	return running
+}
+
+// tapconStalePrincipals returns the active principals whose container is not
+// in running, or runs under another PID.
+func (daemon *Daemon) tapconStalePrincipals(running map[string]tapconRunningContainer) []types.TapconPrincipal {
+	var stale []types.TapconPrincipal
+	for _, p := range daemon.tapconAudit.Active("") {
+		r, ok := running[p.ContainerID]
+		if !ok || uint64(r.pid) != p.PID {
+			stale = append(stale, p)
+		}
+	}
+	return stale
+}
+
+// TapconPrincipals returns the principals created by the daemon. Deleted
+// principals are only included if all is set.
+func (daemon *Daemon) TapconPrincipals(all bool) ([]types.TapconPrincipal, error) {
+	if !daemon.TapconModeOn() {
+		return nil, errTapconNotEnabled
+	}
+	return daemon.tapconAudit.List(all), nil
+}
+
+// TapconReconcile brings the attestation service in line with the running
+// containers, typically after it lost its state. The principals of
+// containers which are gone are deleted, and all other principals are
+// registered again. Running tapcon containers without principals, such as
+// the ones started before the audit log existed, get new ones.
+func (daemon *Daemon) TapconReconcile() (*types.TapconReconcileReport, error) {
+	if !daemon.TapconModeOn() {
+		return nil, errTapconNotEnabled
+	}
+	running := daemon.tapconRunning()
+
+	daemon.tapconPrincipalsLock.Lock()
+	defer daemon.tapconPrincipalsLock.Unlock()
+
+	report := &types.TapconReconcileReport{
+		Removed: daemon.tapconDeleteRecorded(daemon.tapconStalePrincipals(running)),
+	}
+
+	for _, r := range running {
+		c := r.container
+		principals := daemon.tapconAudit.Active(c.ID)
+		if len(principals) == 0 {
+			attachments, err := daemon.tapconAttachments(c)
+			if err != nil {
+				logrus.Errorf("Error resolving tapcon addresses of container %s: %v", c.ID, err)
+				continue
+			}
+			for _, a := range attachments {
+				principals = append(principals, daemon.tapconAudit.Created(types.TapconPrincipal{
+					ContainerID: c.ID,
+					PID:         uint64(r.pid),
+					ImageID:     c.ImageID.String(),
+					Config:      tapconPrincipalConfig(c.Config, c.TapconConfigDigest),
+					Network:     a.Network,
+					IP:          a.Address,
+					PortMin:     c.TapconPortMin,
+					PortMax:     c.TapconPortMax,
+					Error:       "not registered",
+				}))
+			}
+		}
+
+		// the service may still know some of the addresses, and refuses
+		// to register them twice
+		if len(principals) > 0 {
+			if err := daemon.attestor.DeletePrincipal(principals[0].PID); err != nil {
+				logrus.Debugf("Tapcon principal %d of container %s was not registered: %v", principals[0].PID, c.ID, err)
+			}
+		}
+		for _, p := range principals {
+			p.Error = ""
+			if err := daemon.attestor.CreatePrincipal(tapconAttestationPrincipal(p)); err != nil {
+				p.Error = err.Error()
+			}
+			daemon.tapconAudit.Registered(p)
+			if p.Error != "" {
+				report.Failed = append(report.Failed, p)
+			} else {
+				report.Registered = append(report.Registered, p)
+			}
+		}
+	}
+	return report, nil
+}
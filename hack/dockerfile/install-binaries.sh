@@ -117,8 +117,20 @@ do
             install_bindata
             ;;
 
+		strace)
+			# traces the RUN steps of builds, so it must run in any image
+			echo "Install strace version $STRACE_COMMIT"
+			git clone https://github.com/strace/strace.git "$GOPATH/strace"
+			cd "$GOPATH/strace"
+			git checkout -q "$STRACE_COMMIT"
+			./bootstrap
+			./configure LDFLAGS=-static
+			faketime "$BUILD_TIME" make
+			cp strace /usr/local/bin/docker-btrace
+			;;
+
 		*)
-			echo echo "Usage: $0 [tomlv|runc|containerd|tini|proxy]"
+			echo echo "Usage: $0 [tomlv|runc|containerd|tini|proxy|strace]"
 			exit 1
 
 	esac
@@ -146,6 +146,10 @@ install -p -m 755 /usr/local/bin/docker-runc $RPM_BUILD_ROOT/%{_bindir}/docker-r
 
 # install tini
 install -p -m 755 /usr/local/bin/docker-init $RPM_BUILD_ROOT/%{_bindir}/docker-init
+/%{_bindir}/docker-btrace
+
+# install the build tracer
+install -p -m 755 /usr/local/bin/docker-btrace $RPM_BUILD_ROOT/%{_bindir}/docker-btrace
 
 # install udev rules
 install -d $RPM_BUILD_ROOT/%{_sysconfdir}/udev/rules.d
@@ -63,7 +63,10 @@ type Image struct {
 	History    []History `json:"history,omitempty"`
 	OSVersion  string    `json:"os.version,omitempty"`
 	OSFeatures []string  `json:"os.features,omitempty"`
-	Source     Source    `json:"source,omitempty"`
+	Source     *Source   `json:"source,omitempty"`
+	// BaseImage is the reference of the image the build of this image
+	// started from, pinned to a digest.
+	BaseImage string `json:"base_image,omitempty"`
 
 	// rawJSON caches the immutable JSON associated with this image.
 	rawJSON []byte
@@ -1,13 +1,55 @@
 package image
 
-/// for simplicity we only have git, revision, file, and timestamp
+// Source is the provenance of an image built in tapcon mode. It records
+// everything needed to reproduce the build and to check its endorsement.
 type Source struct {
+	// Repo is the URL of the git repository the image was built from.
 	Repo string `json:"repo,omitempty"`
-	// hash of the revision
+	// UnverifiedRepo is the URL of the repository the client claims the
+	// build context was cloned from. It is not part of the endorsement.
+	UnverifiedRepo string `json:"unverified_repo,omitempty"`
+	// Revision is the hash of the git tree the image was built from.
 	Revision string `json:"revision,omitempty"`
-	/// hash of dir
+	// Dir is the hash of the build context directory within the tree.
 	Dir string `json:"dir,omitempty"`
-	// Dockerfile used to build
+	// File is the path of the Dockerfile within the build context.
 	File string `json:"file,omitempty"`
+	// FileDigest is the digest of the content of the Dockerfile.
+	FileDigest string `json:"file_digest,omitempty"`
+	// BuildArgs are the build-time variables consumed by the build.
+	BuildArgs map[string]string `json:"build_args,omitempty"`
+	// BaseImage is the ID of the image the build started from.
+	BaseImage string `json:"base_image,omitempty"`
+	// Images are the IDs of the other images the image is made of: the
+	// images the stages it was built from started from, and the images
+	// files were copied from with COPY --from.
+	Images []string `json:"images,omitempty"`
+	// Builder is the identity of the daemon which built the image, empty for
+	// a reproducible image.
+	Builder string `json:"builder,omitempty"`
+	// Time is the time of the build, in RFC 3339 format.
 	Time string `json:"time,omitempty"`
+	// Git lists the repositories copied into the image by GIT instructions.
+	Git []GitSource `json:"git,omitempty"`
+}
+
+// GitSource is a git repository copied into an image by a GIT instruction.
+type GitSource struct {
+	// Repo is the URL of the repository, without fragment.
+	Repo string `json:"repo"`
+	// Ref is the reference requested in the Dockerfile, if any.
+	Ref string `json:"ref,omitempty"`
+	// Commit is the hash of the commit which was copied.
+	Commit string `json:"commit"`
+	// Dir is the directory of the repository which was copied, if not its
+	// root.
+	Dir string `json:"dir,omitempty"`
+	// Dest is the path the repository was copied to in the image.
+	Dest string `json:"dest"`
+}
+
+// Endorsement returns the property with which the attestation service
+// endorses the images built from the source.
+func (s *Source) Endorsement() string {
+	return s.Repo + "#" + s.Revision + ":" + s.Dir
 }
@@ -21,6 +21,8 @@ type Store interface {
 	SetParent(id ID, parent ID) error
 	GetParent(id ID) (ID, error)
 	Children(id ID) []ID
+	SetTrace(id ID, trace []byte) error
+	GetTrace(id ID) ([]byte, error)
 	Map() map[ID]*Image
 	Heads() map[ID]*Image
 }
@@ -250,6 +252,16 @@ func (is *store) GetParent(id ID) (ID, error) {
 	return ID(d), nil // todo: validate?
 }
 
+// SetTrace attaches the trace report of the build which produced the image.
+func (is *store) SetTrace(id ID, trace []byte) error {
+	return is.fs.SetMetadata(id.Digest(), "trace", trace)
+}
+
+// GetTrace returns the build trace report attached to the image.
+func (is *store) GetTrace(id ID) ([]byte, error) {
+	return is.fs.GetMetadata(id.Digest(), "trace")
+}
+
 func (is *store) Children(id ID) []ID {
 	is.Lock()
 	defer is.Unlock()
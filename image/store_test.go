@@ -289,6 +289,50 @@ func TestParentReset(t *testing.T) {
 
 }
 
+func TestTrace(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "images-fs-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	fs, err := NewFSStoreBackend(tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	is, err := NewImageStore(fs, &mockLayerGetReleaser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := is.Create([]byte(`{"comment": "abc1", "rootfs": {"type": "layers"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := is.GetTrace(id); !os.IsNotExist(err) {
+		t.Fatalf("expected no trace, got %v", err)
+	}
+
+	if err := is.SetTrace(id, []byte(`{"ImageID": "abc1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	trace, err := is.GetTrace(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := string(trace), `{"ImageID": "abc1"}`; actual != expected {
+		t.Fatalf("invalid trace: expected %q, got %q", expected, actual)
+	}
+
+	if _, err := is.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := is.GetTrace(id); err == nil {
+		t.Fatal("expected the trace to be removed with the image")
+	}
+}
+
 type mockLayerGetReleaser struct{}
 
 func (ls *mockLayerGetReleaser) Get(layer.ChainID) (layer.Layer, error) {
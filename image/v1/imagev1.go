@@ -120,8 +120,9 @@ func MakeV1ConfigFromConfig(img *image.Image, v1ID, parentV1ID string, throwaway
 	for i := 0; i < imageType.NumField(); i++ {
 		f := imageType.Field(i)
 		jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
-		// Parent is handled specially below.
-		if jsonName != "" && jsonName != "parent" {
+		// Parent is handled specially below. Source is kept so that the
+		// provenance of the image survives a schema1 push.
+		if jsonName != "" && jsonName != "parent" && jsonName != "source" {
 			delete(configAsMap, jsonName)
 		}
 	}
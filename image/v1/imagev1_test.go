@@ -53,3 +53,40 @@ func TestMakeV1ConfigFromConfig(t *testing.T) {
 		t.Error("os should have been preserved")
 	}
 }
+
+func TestMakeV1ConfigFromConfigKeepsSource(t *testing.T) {
+	img := &image.Image{
+		V1Image: image.V1Image{
+			ID: "v2id",
+		},
+		Source: &image.Source{
+			Repo:     "https://github.com/docker/docker.git",
+			Revision: "0123456789abcdef",
+			File:     "Dockerfile",
+		},
+		RootFS: &image.RootFS{
+			Type: "layers",
+		},
+	}
+	v2js, err := json.Marshal(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = image.NewFromJSON(v2js)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js, err := MakeV1ConfigFromConfig(img, "v1id", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newimg := &image.Image{}
+	if err := json.Unmarshal(js, newimg); err != nil {
+		t.Fatal(err)
+	}
+	if newimg.Source == nil || newimg.Source.Revision != img.Source.Revision || newimg.Source.Repo != img.Source.Repo {
+		t.Errorf("source should have been preserved, got %+v", newimg.Source)
+	}
+}
@@ -2886,3 +2886,32 @@ func (s *DockerDaemonSuite) TestRestartPolicyWithLiveRestore(c *check.C) {
 	out, err = s.d.Cmd("stop", id)
 	c.Assert(err, check.IsNil, check.Commentf("output: %s", out))
 }
+
+// Test that building twice from the same sources in tapcon mode gives the
+// same image when the build is reproducible.
+func (s *DockerDaemonSuite) TestDaemonTapconBuildReproducible(c *check.C) {
+	testRequires(c, SameHostDaemon, DaemonIsLinux)
+	s.d.StartWithBusybox(c, "--tapcon", "--tapcon-attestor=memory", "--metadata-service=http://127.0.0.1:8080/")
+
+	git, err := newFakeGit("repo", map[string]string{
+		"Dockerfile": `FROM busybox
+					RUN echo hello > /hello`,
+	}, true)
+	c.Assert(err, checker.IsNil)
+	defer git.Close()
+
+	build := func() string {
+		out, err := s.d.Cmd("build", "-q", "--no-cache", "--reproducible=1500000000", git.RepoURL)
+		c.Assert(err, checker.IsNil, check.Commentf("output: %s", out))
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		return lines[len(lines)-1]
+	}
+	id := build()
+	c.Assert(build(), checker.Equals, id)
+
+	// the build time is the --reproducible timestamp, and the daemon which
+	// built the image is not recorded
+	out, err := s.d.Cmd("image", "inspect", "-f", "{{.Provenance.Time}}|{{.Provenance.Builder}}", id)
+	c.Assert(err, checker.IsNil, check.Commentf("output: %s", out))
+	c.Assert(strings.TrimSpace(out), checker.Equals, "2017-07-14T02:40:00Z|")
+}
@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/pkg/ioutils"
+)
+
+// normalizedEntry is an entry of an archive being normalized. Its content
+// is spooled at offset in a temporary file.
+type normalizedEntry struct {
+	hdr    *tar.Header
+	offset int64
+}
+
+// byNormalizedOrder sorts the entries by name, with the hard links last so
+// that they follow the files they link to.
+type byNormalizedOrder []normalizedEntry
+
+func (e byNormalizedOrder) Len() int      { return len(e) }
+func (e byNormalizedOrder) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byNormalizedOrder) Less(i, j int) bool {
+	iLink, jLink := e[i].hdr.Typeflag == tar.TypeLink, e[j].hdr.Typeflag == tar.TypeLink
+	if iLink != jLink {
+		return jLink
+	}
+	return e[i].hdr.Name < e[j].hdr.Name
+}
+
+// NormalizeHeader clears the fields of a tar header which depend on when and
+// where the file was written rather than on its content: the modification
+// time is clamped to epoch and truncated to the second, and the access and
+// change times and the user and group names are dropped.
+func NormalizeHeader(hdr *tar.Header, epoch time.Time) {
+	if hdr.ModTime.After(epoch) {
+		hdr.ModTime = epoch
+	}
+	hdr.ModTime = hdr.ModTime.Truncate(time.Second)
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	hdr.Uname = ""
+	hdr.Gname = ""
+	if hdr.Typeflag != tar.TypeChar && hdr.Typeflag != tar.TypeBlock {
+		hdr.Devmajor, hdr.Devminor = 0, 0
+	}
+}
+
+// NormalizeTar rewrites the tar archive read from r so that it only depends
+// on the files it contains: the entries are sorted by name and their headers
+// are normalized with NormalizeHeader. The archive is read entirely, its
+// content spooled to a temporary file, before NormalizeTar returns.
+func NormalizeTar(r io.Reader, epoch time.Time) (io.ReadCloser, error) {
+	spool, err := ioutil.TempFile("", "docker-normalize-tar-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() error {
+		spool.Close()
+		return os.Remove(spool.Name())
+	}
+
+	var (
+		entries []normalizedEntry
+		offset  int64
+	)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		n, err := io.Copy(spool, tr)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		NormalizeHeader(hdr, epoch)
+		entries = append(entries, normalizedEntry{hdr: hdr, offset: offset})
+		offset += n
+	}
+	sort.Sort(byNormalizedOrder(entries))
+
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		for _, e := range entries {
+			if err := tw.WriteHeader(e.hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, io.NewSectionReader(spool, e.offset, e.hdr.Size)); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+	return ioutils.NewReadCloserWrapper(pr, func() error {
+		pr.Close()
+		return cleanup()
+	}), nil
+}
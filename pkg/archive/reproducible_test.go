@@ -0,0 +1,96 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	hdr     tar.Header
+	content string
+}
+
+func writeTestTar(t *testing.T, entries []testEntry) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		hdr := e.hdr
+		hdr.Size = int64(len(e.content))
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func normalizeTestTar(t *testing.T, p []byte, epoch time.Time) []byte {
+	rc, err := NormalizeTar(bytes.NewReader(p), epoch)
+	if err != nil {
+		t.Fatalf("Error when normalizing archive: %s", err)
+	}
+	defer rc.Close()
+	out, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("Error when reading normalized archive: %s", err)
+	}
+	return out
+}
+
+func TestNormalizeTar(t *testing.T) {
+	epoch := time.Unix(1000000000, 0)
+	before := time.Unix(900000000, 500)
+	now := time.Now()
+
+	link := testEntry{hdr: tar.Header{Name: "a-link", Typeflag: tar.TypeLink, Linkname: "z", ModTime: now}}
+	dir := testEntry{hdr: tar.Header{Name: "b/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: now, Uname: "root"}}
+	file := testEntry{hdr: tar.Header{Name: "b/file", Typeflag: tar.TypeReg, Mode: 0644, ModTime: before}, content: "content"}
+	target := testEntry{hdr: tar.Header{Name: "z", Typeflag: tar.TypeReg, Mode: 0644, ModTime: now, Gname: "staff"}, content: "linked"}
+
+	first := normalizeTestTar(t, writeTestTar(t, []testEntry{link, dir, file, target}), epoch)
+
+	// the same files, written later and in another order
+	later := now.Add(time.Hour)
+	link.hdr.ModTime, dir.hdr.ModTime, target.hdr.ModTime = later, later, later
+	second := normalizeTestTar(t, writeTestTar(t, []testEntry{target, file, dir, link}), epoch)
+	if !bytes.Equal(first, second) {
+		t.Fatal("Archives of the same files should be identical once normalized")
+	}
+
+	expected := []struct {
+		name    string
+		modTime time.Time
+		content string
+	}{
+		{"b/", epoch, ""},
+		{"b/file", time.Unix(900000000, 0), "content"},
+		{"z", epoch, "linked"},
+		{"a-link", epoch, ""},
+	}
+	tr := tar.NewReader(bytes.NewReader(first))
+	for _, e := range expected {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("Error when reading %s: %s", e.name, err)
+		}
+		if hdr.Name != e.name || !hdr.ModTime.Equal(e.modTime) || hdr.Uname != "" || hdr.Gname != "" {
+			t.Fatalf("Unexpected header for %s: %+v", e.name, hdr)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil || string(content) != e.content {
+			t.Fatalf("Unexpected content for %s: %q, %v", e.name, content, err)
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Fatalf("Expected the end of the archive, got %v", err)
+	}
+}
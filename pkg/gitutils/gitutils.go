@@ -19,27 +19,52 @@ import (
 // Clone clones a repository into a newly created directory which
 // will be under "docker-build-git"
 func Clone(remoteURL string) (string, error) {
+	_, dir, err := clone(remoteURL)
+	return dir, err
+}
+
+// CloneCommit clones a repository like Clone. It also returns the root of
+// the clone, which the caller is responsible for removing, and the hash of
+// the commit which was checked out.
+func CloneCommit(remoteURL string) (root, dir, commit string, err error) {
+	root, dir, err = clone(remoteURL)
+	if err != nil {
+		if root != "" {
+			os.RemoveAll(root)
+		}
+		return "", "", "", err
+	}
+	output, err := gitWithinDir(root, "rev-parse", "HEAD")
+	if err != nil {
+		os.RemoveAll(root)
+		return "", "", "", fmt.Errorf("Error trying to use git: %s (%s)", err, output)
+	}
+	return root, dir, strings.TrimSpace(string(output)), nil
+}
+
+func clone(remoteURL string) (string, string, error) {
 	if !urlutil.IsGitTransport(remoteURL) {
 		remoteURL = "https://" + remoteURL
 	}
 	root, err := ioutil.TempDir("", "docker-build-git")
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	u, err := url.Parse(remoteURL)
 	if err != nil {
-		return "", err
+		return root, "", err
 	}
 
 	fragment := u.Fragment
 	clone := cloneArgs(u, root)
 
 	if output, err := git(clone...); err != nil {
-		return "", fmt.Errorf("Error trying to use git: %s (%s)", err, output)
+		return root, "", fmt.Errorf("Error trying to use git: %s (%s)", err, output)
 	}
 
-	return checkoutGit(fragment, root)
+	dir, err := checkoutGit(fragment, root)
+	return root, dir, err
 }
 
 /// It assumes the working dir is properly set, so this might not be thread safe
@@ -56,7 +81,7 @@ func GitGetIdentity(root string) ([]byte, []byte, error) {
 		/// sha1.Sum returns [20]byte, use [:] trick to convert it to a slice.
 		cwd_hash := sha1.Sum(bytes.Trim(identities[0], "\n"))
 		/// identities[1] would be the work tree hash
-		return cwd_hash[:], identities[1], nil
+		return cwd_hash[:], bytes.TrimSpace(identities[1]), nil
 	}
 }
 
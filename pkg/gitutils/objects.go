@@ -0,0 +1,177 @@
+package gitutils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Modes of the entries of a tree object.
+const (
+	ModeFile       = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+	ModeTree       = "40000"
+	ModeSubmodule  = "160000"
+)
+
+// TreeEntry is an entry of a git tree object.
+type TreeEntry struct {
+	Mode string
+	Name string
+	Hash string
+}
+
+// HashObject returns the hash git gives to an object of type typ ("blob",
+// "tree" or "commit") with the given content.
+func HashObject(typ string, content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", typ, len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ParseTree decodes the content of a tree object.
+func ParseTree(content []byte) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	for len(content) > 0 {
+		sp := bytes.IndexByte(content, ' ')
+		nul := bytes.IndexByte(content, 0)
+		if sp < 0 || nul < sp || len(content) < nul+1+sha1.Size {
+			return nil, fmt.Errorf("invalid tree object")
+		}
+		entries = append(entries, TreeEntry{
+			Mode: string(content[:sp]),
+			Name: string(content[sp+1 : nul]),
+			Hash: hex.EncodeToString(content[nul+1 : nul+1+sha1.Size]),
+		})
+		content = content[nul+1+sha1.Size:]
+	}
+	return entries, nil
+}
+
+// CommitTree returns the hash of the tree recorded in the content of a
+// commit object.
+func CommitTree(content []byte) (string, error) {
+	line := content
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		line = content[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) != 2 || fields[0] != "tree" {
+		return "", fmt.Errorf("invalid commit object")
+	}
+	return fields[1], nil
+}
+
+// treeEntry is an entry of a tree being hashed, along with its sort key.
+type treeEntry struct {
+	TreeEntry
+	key string
+}
+
+// byGitOrder sorts entries the way git sorts trees, comparing the names of
+// directories as if they ended with a slash.
+type byGitOrder []treeEntry
+
+func (e byGitOrder) Len() int           { return len(e) }
+func (e byGitOrder) Less(i, j int) bool { return e[i].key < e[j].key }
+func (e byGitOrder) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// TreeHash computes the hash git gives to the tree of the files under root,
+// as if they were all committed. Like git, it leaves out empty directories
+// and .git directories, and only keeps the executable bit of the owner of
+// regular files.
+func TreeHash(root string) (string, error) {
+	hash, _, err := hashTree(root)
+	return hash, err
+}
+
+// hashTree returns the hash of the tree of dir, and whether it has entries.
+func hashTree(dir string) (string, bool, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", false, err
+	}
+
+	var entries []treeEntry
+	for _, fi := range infos {
+		if fi.Name() == ".git" {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		e := treeEntry{key: fi.Name()}
+		e.Name = fi.Name()
+
+		switch {
+		case fi.IsDir():
+			hash, ok, err := hashTree(path)
+			if err != nil {
+				return "", false, err
+			}
+			if !ok {
+				continue
+			}
+			e.Mode, e.Hash, e.key = ModeTree, hash, fi.Name()+"/"
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", false, err
+			}
+			e.Mode, e.Hash = ModeSymlink, HashObject("blob", []byte(filepath.ToSlash(target)))
+		case fi.Mode().IsRegular():
+			hash, err := hashFile(path, fi.Size())
+			if err != nil {
+				return "", false, err
+			}
+			e.Mode, e.Hash = ModeFile, hash
+			if fi.Mode()&0100 != 0 {
+				e.Mode = ModeExecutable
+			}
+		default:
+			return "", false, fmt.Errorf("%s cannot be stored in git", path)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return "", false, nil
+	}
+	sort.Sort(byGitOrder(entries))
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		raw, err := hex.DecodeString(e.Hash)
+		if err != nil {
+			return "", false, err
+		}
+		fmt.Fprintf(&buf, "%s %s\x00", e.Mode, e.Name)
+		buf.Write(raw)
+	}
+	return HashObject("tree", buf.Bytes()), true, nil
+}
+
+// hashFile returns the blob hash of the file at path, which is size bytes
+// long, without reading it in memory.
+func hashFile(path string, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", size)
+	if n, err := io.Copy(h, f); err != nil {
+		return "", err
+	} else if n != size {
+		return "", fmt.Errorf("%s changed while being hashed", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,112 @@
+package gitutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func gitOutput(t *testing.T, dir string, args ...string) []byte {
+	out, err := gitWithinDir(dir, args...)
+	if err != nil {
+		t.Fatalf("git %v: %v (%s)", args, err, out)
+	}
+	return out
+}
+
+func TestTreeHash(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks and executable bits are not supported on Windows")
+	}
+	root, err := ioutil.TempDir("", "docker-gitutils-tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if _, err := git("init", root); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, root, "config", "user.email", "test@docker.com")
+	gitOutput(t, root, "config", "user.name", "Docker test")
+	gitOutput(t, root, "config", "core.autocrlf", "false")
+
+	files := map[string]string{
+		"Dockerfile":        "FROM scratch\n",
+		"a.b":               "sorted before a/",
+		"a/file":            "in a directory",
+		"sub/Dockerfile":    "FROM scratch\nEXPOSE 5000\n",
+		"sub/deeper/script": "#!/bin/sh\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(root, "sub/deeper/script"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../Dockerfile", filepath.Join(root, "sub/link")); err != nil {
+		t.Fatal(err)
+	}
+	// git does not track empty directories
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitOutput(t, root, "add", "-A")
+	gitOutput(t, root, "commit", "-m", "initial")
+
+	for _, c := range []struct{ dir, rev string }{
+		{"", "HEAD^{tree}"},
+		{"sub", "HEAD:sub"},
+	} {
+		expected := strings.TrimSpace(string(gitOutput(t, root, "rev-parse", c.rev)))
+		actual, err := TreeHash(filepath.Join(root, c.dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Fatalf("expected tree hash %s of %q, got %s", expected, c.dir, actual)
+		}
+	}
+
+	commit := gitOutput(t, root, "cat-file", "commit", "HEAD")
+	if expected := strings.TrimSpace(string(gitOutput(t, root, "rev-parse", "HEAD"))); HashObject("commit", commit) != expected {
+		t.Fatalf("expected commit hash %s, got %s", expected, HashObject("commit", commit))
+	}
+	tree, err := CommitTree(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.TrimSpace(string(gitOutput(t, root, "rev-parse", "HEAD^{tree}"))); tree != expected {
+		t.Fatalf("expected commit tree %s, got %s", expected, tree)
+	}
+
+	entries, err := ParseTree(gitOutput(t, root, "cat-file", "tree", "HEAD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Mode+" "+e.Name)
+	}
+	if expected := "100644 Dockerfile,100644 a.b,40000 a,40000 sub"; strings.Join(names, ",") != expected {
+		t.Fatalf("expected entries %s, got %s", expected, strings.Join(names, ","))
+	}
+}
+
+func TestParseTreeInvalid(t *testing.T) {
+	if _, err := ParseTree([]byte("100644 truncated\x00abc")); err == nil {
+		t.Fatal("expected an error parsing a truncated tree")
+	}
+	if _, err := CommitTree([]byte("parent abc\n")); err == nil {
+		t.Fatal("expected an error parsing a commit without tree")
+	}
+}
@@ -69,6 +69,24 @@ func (sf *StreamFormatter) FormatError(err error) []byte {
 	return []byte("Error: " + err.Error() + streamNewline)
 }
 
+// FormatAux formats the specified out-of-band data. Only the JSON stream
+// can carry it: the simple stream drops it and returns nil.
+func (sf *StreamFormatter) FormatAux(aux interface{}) []byte {
+	if !sf.json {
+		return nil
+	}
+	auxJSONBytes, err := json.Marshal(aux)
+	if err != nil {
+		return sf.FormatError(err)
+	}
+	auxJSON := json.RawMessage(auxJSONBytes)
+	b, err := json.Marshal(&jsonmessage.JSONMessage{Aux: &auxJSON})
+	if err != nil {
+		return sf.FormatError(err)
+	}
+	return append(b, streamNewlineBytes...)
+}
+
 // FormatProgress formats the progress information for a specified action.
 func (sf *StreamFormatter) FormatProgress(id, action string, progress *jsonmessage.JSONProgress, aux interface{}) []byte {
 	if progress == nil {
@@ -67,6 +67,21 @@ func TestJSONFormatJSONError(t *testing.T) {
 	}
 }
 
+func TestFormatAux(t *testing.T) {
+	sf := NewStreamFormatter()
+	if res := sf.FormatAux(map[string]string{"a": "b"}); res != nil {
+		t.Fatalf("%q", res)
+	}
+}
+
+func TestJSONFormatAux(t *testing.T) {
+	sf := NewJSONStreamFormatter()
+	res := sf.FormatAux(map[string]string{"a": "b"})
+	if string(res) != `{"aux":{"a":"b"}}`+"\r\n" {
+		t.Fatalf("%q", res)
+	}
+}
+
 func TestJSONFormatProgress(t *testing.T) {
 	sf := NewJSONStreamFormatter()
 	progress := &jsonmessage.JSONProgress{
@@ -2,6 +2,7 @@ package v2
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
 
@@ -242,3 +243,9 @@ func (p *Plugin) Acquire() {
 func (p *Plugin) Release() {
 	p.AddRefCount(plugingetter.Release)
 }
+
+// ScopedPath returns the path on the host of the path s of the plugin's
+// rootfs.
+func (p *Plugin) ScopedPath(s string) string {
+	return filepath.Join(p.Rootfs, s)
+}